@@ -1,6 +1,9 @@
 package events
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Event struct {
 	ID   string `json:"id"  db:"id"`
@@ -14,3 +17,13 @@ type UserEvent struct {
 	UserID   string          `json:"user_id" db:"user_id"`
 	Metadata json.RawMessage `json:"metadata" db:"metadata"`
 }
+
+type UserEventKey struct {
+	Key            string    `json:"key" db:"key"`
+	FirstCreatedAt time.Time `json:"firstCreatedAt" db:"first_created_at"`
+}
+
+type EventOccurrence struct {
+	UserID    string    `json:"userId" db:"user_id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
@@ -3,7 +3,9 @@ package events
 import (
 	"encoding/json"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"milestone_core/shared/sql"
+	"time"
 )
 
 type Service struct {
@@ -47,6 +49,22 @@ func (s Service) GetEvents(workspaceId string) ([]Event, error) {
 	return events, err
 }
 
+// GetEventOccurrences returns the occurrences of the event for the given users since the given time
+func (s Service) GetEventOccurrences(workspaceId string, eventKey string, userIds []string, since time.Time) ([]EventOccurrence, error) {
+	if len(userIds) == 0 {
+		return make([]EventOccurrence, 0), nil
+	}
+
+	occurrences, err := sql.FetchMultiple[EventOccurrence](s.DbConnection, "SELECT ue.user_id, ue.created_at FROM game_engine.user_events ue JOIN game_engine.event e ON e.id = ue.event_id WHERE ue.workspace_id = $1 AND e.key = $2 AND e.deleted_at IS NULL AND ue.user_id = ANY($3) AND ue.created_at >= $4", workspaceId, eventKey, pq.Array(userIds), since)
+	return occurrences, err
+}
+
+// GetUserEventKeys returns the keys of the events tracked for the user with the time each was first tracked
+func (s Service) GetUserEventKeys(workspaceId string, userId string) ([]UserEventKey, error) {
+	keys, err := sql.FetchMultiple[UserEventKey](s.DbConnection, "SELECT e.key, MIN(ue.created_at) AS first_created_at FROM game_engine.user_events ue JOIN game_engine.event e ON e.id = ue.event_id WHERE ue.workspace_id = $1 AND ue.user_id = $2 AND e.deleted_at IS NULL GROUP BY e.key", workspaceId, userId)
+	return keys, err
+}
+
 func (s Service) UpdateEvent(workspaceId string, id string, event *Event) error {
 	if event.Key == "" || event.Name == "" {
 		return Errors.InvalidEventError
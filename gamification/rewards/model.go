@@ -1,6 +1,9 @@
 package rewards
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Reward struct {
 	ID          string           `json:"id"  db:"id"`
@@ -73,3 +76,10 @@ func (l LevelRewardOptions) IsRewardOptions() bool {
 func (p PointsRewardOptions) IsRewardOptions() bool {
 	return true
 }
+
+type ReceivedReward struct {
+	Key        string     `json:"key" db:"key"`
+	Name       string     `json:"name" db:"name"`
+	Type       RewardType `json:"type" db:"type"`
+	ReceivedAt time.Time  `json:"receivedAt" db:"received_at"`
+}
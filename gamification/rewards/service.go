@@ -84,6 +84,12 @@ func (s Service) KeyToId(workspaceId string, key string) (*string, error) {
 	return id, err
 }
 
+// GetReceivedRewards returns the rewards the user received, the latest first
+func (s Service) GetReceivedRewards(workspaceId string, userId string) ([]ReceivedReward, error) {
+	rewards, err := sql.FetchMultiple[ReceivedReward](s.DbConnection, "SELECT r.key, r.name, r.type, urr.created_at AS received_at FROM game_engine.user_received_rewards urr JOIN game_engine.reward r ON r.id = urr.reward_id WHERE r.workspace_id = $1 AND urr.user_id = $2 AND r.deleted_at IS NULL ORDER BY urr.created_at DESC", workspaceId, userId)
+	return rewards, err
+}
+
 func (s Service) GetRewardById(workspaceId string, id string) (*Reward, error) {
 	reward, err := sql.FetchOne[Reward](s.DbConnection, "SELECT id, key, name, type, metadata, options FROM game_engine.reward WHERE workspace_id = $1 AND id = $2 AND deleted_at IS NULL", workspaceId, id)
 	if err != nil {
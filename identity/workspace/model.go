@@ -5,4 +5,5 @@ type Workspace struct {
 	Name        string `json:"name" db:"name"`
 	BaseURL     string `json:"baseUrl"  db:"base_url"`
 	InviteToken string `json:"inviteToken"  db:"invite_token"`
+	Timezone    string `json:"timezone" db:"timezone"`
 }
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"github.com/jmoiron/sqlx"
 	"milestone_core/identity/users"
+	"time"
 )
 
 type Service struct {
@@ -15,7 +16,7 @@ type Service struct {
 
 func (s Service) Get(workspaceId string) (*Workspace, error) {
 	var workspace Workspace
-	err := s.DbConnection.Get(&workspace, "SELECT w.id as id, w.name as name, w.base_url as base_url, coalesce(w.invite_token, '') as invite_token FROM identity.workspace w WHERE w.id = $1", workspaceId)
+	err := s.DbConnection.Get(&workspace, "SELECT w.id as id, w.name as name, w.base_url as base_url, coalesce(w.invite_token, '') as invite_token, w.timezone as timezone FROM identity.workspace w WHERE w.id = $1", workspaceId)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
@@ -29,7 +30,7 @@ func (s Service) Get(workspaceId string) (*Workspace, error) {
 func (s Service) FetchAllForUser(userId string) ([]Workspace, error) {
 	var workspaces []Workspace
 	err := s.DbConnection.Select(&workspaces, `
-		SELECT w.id as id, w.name as name, w.base_url as base_url, coalesce(w.invite_token, '') as invite_token
+		SELECT w.id as id, w.name as name, w.base_url as base_url, coalesce(w.invite_token, '') as invite_token, w.timezone as timezone
 		FROM identity.workspace w
 		JOIN identity.workspace_user wu ON w.id = wu.workspace_id 
 		WHERE wu.user_id = $1
@@ -39,6 +40,13 @@ func (s Service) FetchAllForUser(userId string) ([]Workspace, error) {
 	return workspaces, err
 }
 
+func (s Service) IsMember(workspaceId string, userId string) (bool, error) {
+	var count int
+	err := s.DbConnection.Get(&count, "SELECT COUNT(*) FROM identity.workspace_user WHERE workspace_id = $1 AND user_id = $2", workspaceId, userId)
+
+	return count > 0, err
+}
+
 func (s Service) GetUsers(workspaceId string) (*WorkspaceUsers, error) {
 	workspaceActiveUsers, err := s.UsersService.GetWorkspaceUsers(workspaceId)
 	if err != nil {
@@ -64,7 +72,7 @@ func (s Service) GetUsers(workspaceId string) (*WorkspaceUsers, error) {
 
 func (s Service) GetByUserId(userId string) (*Workspace, error) {
 	var workspace Workspace
-	err := s.DbConnection.Get(&workspace, "SELECT w.id as id, w.name as name, w.base_url as base_url, coalesce(w.invite_token, '') as invite_token FROM identity.workspace w JOIN identity.workspace_user wu ON w.id = wu.workspace_id WHERE wu.user_id = $1", userId)
+	err := s.DbConnection.Get(&workspace, "SELECT w.id as id, w.name as name, w.base_url as base_url, coalesce(w.invite_token, '') as invite_token, w.timezone as timezone FROM identity.workspace w JOIN identity.workspace_user wu ON w.id = wu.workspace_id WHERE wu.user_id = $1", userId)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
@@ -95,10 +103,28 @@ func (s Service) CreateForUser(workspace Workspace, userId string) error {
 }
 
 func (s Service) Update(id string, workspace Workspace) error {
-	_, err := s.DbConnection.Exec("UPDATE identity.workspace SET name = $1, base_url = $2 WHERE id = $3", workspace.Name, workspace.BaseURL, id)
+	// Clients that do not send a timezone keep the current one
+	if _, err := time.LoadLocation(workspace.Timezone); workspace.Timezone != "" && err != nil {
+		return errors.New("invalid timezone: " + workspace.Timezone)
+	}
+
+	_, err := s.DbConnection.Exec("UPDATE identity.workspace SET name = $1, base_url = $2, timezone = COALESCE(NULLIF($3, ''), timezone) WHERE id = $4", workspace.Name, workspace.BaseURL, workspace.Timezone, id)
 	return err
 }
 
+// GetLocation returns the timezone of the workspace, flow schedules are evaluated in it
+func (s Service) GetLocation(workspaceId string) (*time.Location, error) {
+	workspace, err := s.Get(workspaceId)
+	if err != nil {
+		return nil, err
+	}
+	if workspace == nil {
+		return nil, errors.New("workspace not found")
+	}
+
+	return time.LoadLocation(workspace.Timezone)
+}
+
 func (s Service) InviteUsers(workspaceId string, userEmails []string) error {
 	workspace, err := s.Get(workspaceId)
 	if err != nil {
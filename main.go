@@ -12,6 +12,7 @@ import (
 	"log"
 	"milestone_core/gamification/events"
 	"milestone_core/gamification/rewards"
+	"milestone_core/gamification/wallets"
 	"milestone_core/identity/apiclient"
 	"milestone_core/identity/authorization"
 	"milestone_core/identity/users"
@@ -20,12 +21,17 @@ import (
 	"milestone_core/public/enrolledusers"
 	"milestone_core/shared/awsinternal"
 	"milestone_core/shared/rest"
+	"milestone_core/tours/audit"
 	"milestone_core/tours/branching"
+	"milestone_core/tours/checklists"
 	"milestone_core/tours/flows"
 	"milestone_core/tours/helpers"
+	"milestone_core/tours/preview"
+	"milestone_core/tours/surveys"
 	"milestone_core/tours/tracker"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -52,41 +58,106 @@ func main() {
 
 	flowCollection := flowDbConnection.Collection("flows")
 	flowArchiveCollection := flowDbConnection.Collection("flows_archive")
+	flowPublishedCollection := flowDbConnection.Collection("flows_published")
+	flowRevisionsCollection := flowDbConnection.Collection("flow_revisions")
+	flowSettingsCollection := flowDbConnection.Collection("flow_settings")
 	usersCollection := flowDbConnection.Collection("enrolled_users")
 	branchingCollection := flowDbConnection.Collection("branching")
 	usersStateCollection := flowDbConnection.Collection("users_state")
 	helpersCollection := flowDbConnection.Collection("helpers")
 	trackerCollection := flowDbConnection.Collection("tracking_data")
+	checklistsCollection := flowDbConnection.Collection("checklists")
+	userChecklistsCollection := flowDbConnection.Collection("user_checklists")
+	surveyResponsesCollection := flowDbConnection.Collection("survey_responses")
+	flowTemplatesCollection := flowDbConnection.Collection("flow_templates")
+	changeLogCollection := flowDbConnection.Collection("change_log")
 
-	flowService := flows.Service{Collection: flowCollection, ArchiveCollection: flowArchiveCollection}
+	auditLog := audit.Log{Collection: changeLogCollection}
+	previewTokens := preview.Tokens{Secret: []byte(os.Getenv("PREVIEW_TOKEN_SECRET"))}
+	flowService := flows.Service{
+		Collection:          flowCollection,
+		ArchiveCollection:   flowArchiveCollection,
+		PublishedCollection: flowPublishedCollection,
+		RevisionsCollection: flowRevisionsCollection,
+		AuditLog:            auditLog,
+	}
+	err = flowService.EnsureIndexes()
+	if err != nil {
+		log.Panic(err)
+		return
+	}
 	enrolledUsersService := enrolledusers.Service{Collection: usersCollection, UserStateCollection: usersStateCollection}
-	branchingService := flows.BranchingService{Collection: branchingCollection}
+	branchingService := flows.BranchingService{Collection: branchingCollection, AuditLog: auditLog}
 	apiClientService := apiclient.Service{DbConnection: postgresConnection}
 	usersService := users.Service{DbConnection: postgresConnection, CognitoClient: cognitoClient}
 	workspaceService := workspace.Service{DbConnection: postgresConnection, UsersService: usersService}
-	helpersService := helpers.Service{Collection: helpersCollection}
-	flowEnroller := flows.Enroller{Collection: flowCollection}
+	helpersService := helpers.Service{Collection: helpersCollection, AuditLog: auditLog}
+	flowSettingsService := flows.SettingsService{Collection: flowSettingsCollection}
+	flowTemplateService := flows.TemplateService{Collection: flowTemplatesCollection, FlowService: flowService}
+	flowEnroller := flows.Enroller{
+		Collection:          flowPublishedCollection,
+		DraftCollection:     flowCollection,
+		BranchingCollection: branchingCollection,
+		WorkspaceService:    workspaceService,
+		SettingsService:     flowSettingsService,
+	}
 	publicapiService := apigateway.Service{
 		ApiClientService:    apiClientService,
 		FlowEnroller:        flowEnroller,
 		EnrolledUserService: enrolledUsersService,
 		HelpersService:      helpersService,
+		WalletService:       wallets.NewWalletService(postgresConnection),
+		RewardsService:      rewards.Service{DbConnection: postgresConnection},
+		PreviewTokens:       previewTokens,
 	}
 	trackerService := tracker.Tracker{Collection: trackerCollection}
-	flowAnalyticsService := flows.Analytics{Tracker: trackerService}
-
+	eventsService := events.Service{DbConnection: postgresConnection}
+	flowAnalyticsService := flows.Analytics{
+		Tracker:                 trackerService,
+		UserStateCollection:     usersStateCollection,
+		EnrolledUsersCollection: usersCollection,
+		EventsService:           eventsService,
+	}
 	eventsResource := events.Resource{
-		EventsService: events.Service{
-			DbConnection: postgresConnection,
-		},
+		EventsService: eventsService,
+	}
+	experimentService := flows.ExperimentService{
+		BranchingService:        branchingService,
+		UserStateCollection:     usersStateCollection,
+		EnrolledUsersCollection: usersCollection,
+		Tracker:                 trackerService,
+		EventsService:           eventsService,
+	}
+	checklistService := checklists.Service{
+		Collection:          checklistsCollection,
+		UserCollection:      userChecklistsCollection,
+		EnrolledUserService: enrolledUsersService,
+		EventsService:       eventsService,
+		Tracker:             trackerService,
+	}
+	err = checklistService.EnsureIndexes()
+	if err != nil {
+		log.Panic(err)
+		return
+	}
+	surveyService := surveys.Service{
+		Collection:          surveyResponsesCollection,
+		FlowService:         flowService,
+		FlowEnroller:        flowEnroller,
+		EnrolledUserService: enrolledUsersService,
+		PreviewTokens:       previewTokens,
 	}
 	rewardsResource := rewards.Resource{Service: rewards.Service{DbConnection: postgresConnection}}
 
+	flowScheduler := flows.Scheduler{FlowService: flowService, Interval: time.Minute}
+	go flowScheduler.Start(context.Background())
+
 	r := chi.NewRouter()
 	corsMiddleware := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"}, // Adjust this based on your specific requirements
 		AllowedMethods: []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{"*"},
+		ExposedHeaders: []string{"ETag"},
 		MaxAge:         300, // Maximum value not ignored by any of major browsers
 	})
 
@@ -108,16 +179,34 @@ func main() {
 		w.Write([]byte("."))
 	})
 
-	r.Mount("/enrolled-users", enrolledusers.UsersResource{UsersService: enrolledUsersService}.Routes())
+	r.Mount("/enrolled-users", enrolledusers.UsersResource{
+		UsersService: enrolledUsersService,
+		FlowEnroller: flowEnroller,
+	}.Routes())
 	r.Mount("/flows", flows.FlowsResource{
-		FlowService: flowService,
-		Analytics:   flowAnalyticsService,
+		FlowService:      flowService,
+		Analytics:        flowAnalyticsService,
+		SettingsService:  flowSettingsService,
+		TemplateService:  flowTemplateService,
+		TransferService:  flows.TransferService{FlowService: flowService, BranchingService: branchingService},
+		WorkspaceService: workspaceService,
+		PreviewTokens:    previewTokens,
 	}.Routes())
 	r.Mount("/helpers", helpers.Resource{
 		Service: helpersService,
 	}.Routes())
+	r.Mount("/activity", audit.Resource{
+		Log: auditLog,
+	}.Routes())
+	r.Mount("/checklists", checklists.Resource{
+		Service: checklistService,
+	}.Routes())
+	r.Mount("/surveys", surveys.Resource{
+		Service: surveyService,
+	}.Routes())
 	r.Mount("/branching", branching.BranchingResource{
-		BranchingService: branchingService,
+		BranchingService:  branchingService,
+		ExperimentService: experimentService,
 	}.Routes())
 	r.Mount("/workspaces", workspace.Resource{
 		Service: workspaceService,
@@ -132,7 +221,9 @@ func main() {
 			ApiClientService:    apiClientService,
 			EnrolledUserService: enrolledUsersService,
 		},
-		Tracker: trackerService,
+		Tracker:          trackerService,
+		ChecklistService: checklistService,
+		SurveyService:    surveyService,
 	}.Routes())
 
 	r.Mount("/events", eventsResource.Routes())
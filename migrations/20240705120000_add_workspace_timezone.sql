@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE identity.workspace
+    ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE identity.workspace
+    DROP COLUMN timezone;
+-- +goose StatementEnd
@@ -1,6 +1,7 @@
 package apigateway
 
 import (
+	"milestone_core/tours/flows"
 	"milestone_core/tours/tracker"
 )
 
@@ -10,8 +11,23 @@ type TrackEventsRequest struct {
 }
 
 type FlowStateUpdateRequest struct {
-	FlowID        string `json:"flowId"`
-	CurrentStepID string `json:"currentStepId"`
-	Finished      bool   `json:"finished"`
-	Skipped       bool   `json:"skipped"`
+	FlowID            string `json:"flowId"`
+	CurrentStepID     string `json:"currentStepId"`
+	Finished          bool   `json:"finished"`
+	Skipped           bool   `json:"skipped"`
+	BranchingOptionID string `json:"branchingOptionId,omitempty"`
+}
+
+type FlowStateUpdateResponse struct {
+	NextStep *flows.Step `json:"nextStep,omitempty"`
+}
+
+type EnrollInFlowRequest struct {
+	Context *flows.ClientContext `json:"context,omitempty"`
+}
+
+// EnrollInFlowResponse has the flow of every slot, it is sent to SDKs that ask for slots with the slots query
+// parameter. Older SDKs get the modal flow alone.
+type EnrollInFlowResponse struct {
+	Flows map[flows.FlowSlot]*flows.Flow `json:"flows"`
 }
@@ -0,0 +1,59 @@
+package apigateway
+
+import (
+	"database/sql"
+	"errors"
+	"milestone_core/gamification/rewards"
+	"milestone_core/public/enrolledusers"
+	"milestone_core/tours/personalization"
+)
+
+// personalizationValues collects the template values of the user. Wallet and rewards are only loaded when the
+// content uses them, content rendered without a user only shows the default values.
+func (s Service) personalizationValues(workspaceId string, user *enrolledusers.EnrolledUser, namespaces map[string]bool) (personalization.Values, error) {
+	if user == nil {
+		return personalization.Values{}, nil
+	}
+
+	values := user.PersonalizationValues()
+	if namespaces[personalization.NamespaceWallet] && s.WalletService != nil {
+		balance := 0
+		wallet, err := s.WalletService.GetWallet(workspaceId, user.ExternalId)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if wallet != nil {
+			balance = wallet.CurrentBalance
+		}
+		values["wallet.balance"] = balance
+	}
+
+	if namespaces[personalization.NamespaceRewards] {
+		receivedRewards, err := s.RewardsService.GetReceivedRewards(workspaceId, user.ExternalId)
+		if err != nil {
+			return nil, err
+		}
+		badges := 0
+		for _, reward := range receivedRewards {
+			if reward.Type == rewards.RewardTypeBadge {
+				badges++
+			}
+		}
+		values["rewards.count"] = len(receivedRewards)
+		values["rewards.badges"] = badges
+		if len(receivedRewards) > 0 {
+			values["rewards.latest"] = receivedRewards[0].Name
+		}
+	}
+
+	return values, nil
+}
+
+// getOptionalUser returns the user when the SDK identified one, unknown users are treated as anonymous
+func (s Service) getOptionalUser(workspaceId string, externalUserId string) (*enrolledusers.EnrolledUser, error) {
+	if externalUserId == "" {
+		return nil, nil
+	}
+
+	return s.EnrolledUserService.Get(workspaceId, externalUserId)
+}
@@ -4,8 +4,13 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/go-chi/chi/v5"
+	"io"
 	"milestone_core/public/enrolledusers"
 	"milestone_core/shared/server"
+	"milestone_core/tours/checklists"
+	"milestone_core/tours/flows"
+	"milestone_core/tours/preview"
+	"milestone_core/tours/surveys"
 	"milestone_core/tours/tracker"
 	"net/http"
 )
@@ -14,6 +19,8 @@ type PublicApiResource struct {
 	Service          Service
 	Tracker          tracker.Tracker
 	UserStateService UserStateService
+	ChecklistService checklists.Service
+	SurveyService    surveys.Service
 }
 
 func (rs PublicApiResource) Routes() chi.Router {
@@ -30,6 +37,9 @@ func (rs PublicApiResource) Routes() chi.Router {
 	r.Get("/{externalUserId}/state", rs.GetUserState)
 	r.Post("/{externalUserId}/flows/enroll", rs.EnrollInFlow)
 	r.Post("/{externalUserId}/flows/state", rs.UpdateFlowState)
+	r.Get("/{externalUserId}/checklists", rs.GetChecklists)
+	r.Post("/{externalUserId}/checklists/{checklistId}/items/{itemId}/complete", rs.CompleteChecklistItem)
+	r.Post("/{externalUserId}/surveys/responses", rs.SubmitSurveyResponse)
 
 	// Tracker
 	r.Post("/track", rs.Track)
@@ -51,7 +61,7 @@ func (rs PublicApiResource) ValidateToken(w http.ResponseWriter, r *http.Request
 func (rs PublicApiResource) Get(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	token := server.GetTokenFromPublicApiClientContext(r.Context())
-	resFlow, err := rs.Service.GetFlow(token, id)
+	resFlow, err := rs.Service.GetFlow(token, id, r.URL.Query().Get("locale"), r.URL.Query().Get("externalUserId"), r.Header.Get(preview.Header))
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
@@ -99,6 +109,22 @@ func (rs PublicApiResource) Track(w http.ResponseWriter, r *http.Request) {
 	}
 
 	workspaceId := server.GetWorkspaceIdFromPublicApiClientContext(r.Context())
+	previewClaims, err := rs.Service.PreviewClaims(workspaceId, r.Header.Get(preview.Header))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+	// Events of preview sessions are stored for debugging but tagged, so they stay out of the analytics
+	for i := range body.Events {
+		body.Events[i].Preview = previewClaims != nil
+		if previewClaims != nil {
+			if body.Events[i].Metadata == nil {
+				body.Events[i].Metadata = make(map[string]string)
+			}
+			body.Events[i].Metadata["previewSessionId"] = previewClaims.SessionID
+		}
+	}
+
 	err = rs.Tracker.TrackEvents(workspaceId, body.ExternalUserID, body.Events)
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
@@ -110,7 +136,7 @@ func (rs PublicApiResource) Track(w http.ResponseWriter, r *http.Request) {
 
 func (rs PublicApiResource) GetHelpers(w http.ResponseWriter, r *http.Request) {
 	token := server.GetTokenFromPublicApiClientContext(r.Context())
-	helpers, err := rs.Service.GetHelpers(token)
+	helpers, err := rs.Service.GetHelpers(token, r.URL.Query().Get("locale"), r.URL.Query().Get("externalUserId"))
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
@@ -122,13 +148,25 @@ func (rs PublicApiResource) GetHelpers(w http.ResponseWriter, r *http.Request) {
 func (rs PublicApiResource) EnrollInFlow(w http.ResponseWriter, r *http.Request) {
 	workspaceId := server.GetWorkspaceIdFromPublicApiClientContext(r.Context())
 	externalUserId := chi.URLParam(r, "externalUserId")
-	flow, err := rs.Service.EnrollInFlow(workspaceId, externalUserId)
+	var body EnrollInFlowRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil && !errors.Is(err, io.EOF) {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	slotFlows, err := rs.Service.EnrollInFlow(workspaceId, externalUserId, body.Context, r.Header.Get(preview.Header))
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
 	}
 
-	server.SendJson(w, flow)
+	if r.URL.Query().Get("slots") != "true" {
+		server.SendJson(w, slotFlows[flows.FlowSlotModal])
+		return
+	}
+
+	server.SendJson(w, EnrollInFlowResponse{Flows: slotFlows})
 }
 
 func (rs PublicApiResource) UpdateFlowState(w http.ResponseWriter, r *http.Request) {
@@ -141,7 +179,54 @@ func (rs PublicApiResource) UpdateFlowState(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = rs.Service.UpdateFlowState(workspaceId, externalUserId, body)
+	response, err := rs.Service.UpdateFlowState(workspaceId, externalUserId, body, r.Header.Get(preview.Header))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, response)
+}
+
+func (rs PublicApiResource) GetChecklists(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromPublicApiClientContext(r.Context())
+	externalUserId := chi.URLParam(r, "externalUserId")
+
+	progress, err := rs.ChecklistService.GetProgress(workspaceId, externalUserId)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, progress)
+}
+
+func (rs PublicApiResource) CompleteChecklistItem(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromPublicApiClientContext(r.Context())
+	externalUserId := chi.URLParam(r, "externalUserId")
+	checklistId := chi.URLParam(r, "checklistId")
+	itemId := chi.URLParam(r, "itemId")
+
+	err := rs.ChecklistService.CompleteItem(workspaceId, externalUserId, checklistId, itemId)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, "ok")
+}
+
+func (rs PublicApiResource) SubmitSurveyResponse(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromPublicApiClientContext(r.Context())
+	externalUserId := chi.URLParam(r, "externalUserId")
+	var body surveys.SubmitRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	err = rs.SurveyService.Submit(workspaceId, externalUserId, body, r.Header.Get(preview.Header))
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
@@ -2,10 +2,13 @@ package apigateway
 
 import (
 	"errors"
+	"milestone_core/gamification/rewards"
+	"milestone_core/gamification/wallets"
 	"milestone_core/identity/apiclient"
 	"milestone_core/public/enrolledusers"
 	"milestone_core/tours/flows"
 	"milestone_core/tours/helpers"
+	"milestone_core/tours/preview"
 	"time"
 )
 
@@ -14,6 +17,9 @@ type Service struct {
 	FlowEnroller        flows.Enroller
 	EnrolledUserService enrolledusers.Service
 	HelpersService      helpers.Service
+	WalletService       *wallets.WalletService
+	RewardsService      rewards.Service
+	PreviewTokens       preview.Tokens
 }
 
 func (s Service) ValidateToken(token string) error {
@@ -25,23 +31,61 @@ func (s Service) ValidateToken(token string) error {
 	return nil
 }
 
-func (s Service) GetFlow(token string, id string) (*flows.Flow, error) {
+// GetFlow returns the live flow in the locale, or in the locale of the user when only the user is given. The
+// templates of the content are rendered for the user. With a preview token the draft of the flow is returned.
+func (s Service) GetFlow(token string, id string, locale string, externalUserId string, previewToken string) (*flows.Flow, error) {
 	apiClient, err := s.ApiClientService.GetByToken(token)
 	if err != nil {
 		return nil, err
 	}
 
-	resFlow, err := s.FlowEnroller.GetFlow(apiClient.WorkspaceID, flows.EnrollmentOpts{
-		CurrentEnrollmentId: id,
-	})
+	var resFlow *flows.Flow
+	if previewToken != "" {
+		resFlow, err = s.getPreviewFlow(apiClient.WorkspaceID, previewToken, id)
+	} else {
+		resFlow, err = s.FlowEnroller.GetFlow(apiClient.WorkspaceID, flows.EnrollmentOpts{
+			CurrentEnrollmentId: id,
+		})
+	}
+	if err != nil || resFlow == nil {
+		return nil, err
+	}
+
+	enrolledUser, err := s.getOptionalUser(apiClient.WorkspaceID, externalUserId)
 	if err != nil {
 		return nil, err
 	}
+	if locale == "" && enrolledUser != nil {
+		locale = enrolledUser.Locale
+	}
 
-	return resFlow, err
+	return s.renderFlow(apiClient.WorkspaceID, resFlow, locale, enrolledUser)
 }
 
-func (s Service) EnrollInFlow(workspaceId string, externalUserId string) (*flows.Flow, error) {
+// renderFlow localizes the flow and renders the templates of its content for the user
+func (s Service) renderFlow(workspaceId string, resFlow *flows.Flow, locale string, enrolledUser *enrolledusers.EnrolledUser) (*flows.Flow, error) {
+	localizedFlow := flows.LocalizeFlow(resFlow, locale)
+	values, err := s.personalizationValues(workspaceId, enrolledUser, flows.PersonalizationNamespaces(localizedFlow))
+	if err != nil {
+		return nil, err
+	}
+
+	return flows.PersonalizeFlow(localizedFlow, values), nil
+}
+
+// EnrollInFlow returns one flow per slot, the flow the user is currently in or the next eligible one. When the SDK
+// sends the client context, flows whose triggers do not match the current page are skipped. A preview token forces
+// the draft of its flow into its slot without enrolling the user in it.
+func (s Service) EnrollInFlow(workspaceId string, externalUserId string, clientContext *flows.ClientContext, previewToken string) (map[flows.FlowSlot]*flows.Flow, error) {
+	var previewFlow *flows.Flow
+	if previewToken != "" {
+		var err error
+		previewFlow, err = s.getPreviewFlow(workspaceId, previewToken, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	enrolledUser, err := s.EnrolledUserService.Get(workspaceId, externalUserId)
 	if err != nil {
 		return nil, err
@@ -55,29 +99,77 @@ func (s Service) EnrollInFlow(workspaceId string, externalUserId string) (*flows
 		return nil, err
 	}
 
-	resFlow, err := s.FlowEnroller.GetFlow(workspaceId, flows.EnrollmentOpts{
-		CurrentEnrollmentId: userState.FlowsData.CurrentFlowID,
-		FinishedIds:         userState.FlowsData.CompletedFlowsIds,
-		SkippedIds:          userState.FlowsData.SkippedFlowsIds,
-		SignUpTimestamp:     enrolledUser.SignUpTimestamp,
-		UserSegment:         enrolledUser.Segment,
-		UserId:              enrolledUser.ExternalId,
-	})
+	enrollmentOpts := enrolledusers.BuildEnrollmentOpts(*enrolledUser, userState, clientContext)
+	assignmentsCount := len(userState.FlowsData.RolloutAssignments) + len(userState.FlowsData.ExperimentAssignments)
+
+	slotFlows, err := s.FlowEnroller.GetFlows(workspaceId, enrollmentOpts)
 	if err != nil {
 		return nil, err
 	}
-	if resFlow == nil {
-		return nil, nil
+	stateChanged := len(userState.FlowsData.RolloutAssignments)+len(userState.FlowsData.ExperimentAssignments) != assignmentsCount
+
+	// Slots whose flow was unpublished are freed, so the next eligible flow can take them
+	for slot := range enrollmentOpts.CurrentEnrollments {
+		if _, ok := slotFlows[slot]; !ok {
+			userState.FlowsData.SetCurrentFlow(slot, "")
+			stateChanged = true
+		}
+	}
+
+	for slot, resFlow := range slotFlows {
+		flowId := resFlow.ID.Hex()
+		if enrollmentOpts.CurrentEnrollments[slot] == flowId || (previewFlow != nil && previewFlow.Type.Slot() == slot) {
+			continue
+		}
+
+		userState.FlowsData.SetProgress(flowId, func(progress *enrolledusers.FlowProgress) {
+			progress.Slot = slot
+			if progress.EnrolledAt == 0 {
+				progress.EnrolledAt = time.Now().Unix()
+			}
+		})
+		userState.FlowsData.SetCurrentFlow(slot, flowId)
+		stateChanged = true
+	}
+
+	if previewFlow != nil {
+		slotFlows[previewFlow.Type.Slot()] = previewFlow
 	}
 
-	if resFlow.ID.Hex() == userState.FlowsData.CurrentFlowID {
-		return resFlow, nil
+	if stateChanged {
+		err = s.EnrolledUserService.PutState(workspaceId, enrolledUser.ID.Hex(), *userState)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	userState.FlowsData.CurrentFlowID = resFlow.ID.Hex()
-	err = s.EnrolledUserService.PutState(workspaceId, enrolledUser.ID.Hex(), *userState)
+	for slot, resFlow := range slotFlows {
+		slotFlows[slot], err = s.renderFlow(workspaceId, resFlow, enrolledUser.Locale, enrolledUser)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	return resFlow, err
+	return slotFlows, nil
+}
+
+// getPreviewFlow returns the draft of the flow the preview token was minted for, a given flow id has to match it
+func (s Service) getPreviewFlow(workspaceId string, previewToken string, flowId string) (*flows.Flow, error) {
+	claims, err := s.PreviewTokens.VerifyFor(previewToken, workspaceId, flowId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.FlowEnroller.GetPreviewFlow(workspaceId, claims.FlowID)
+}
+
+// PreviewClaims verifies the preview token of a request, requests without one return no claims
+func (s Service) PreviewClaims(workspaceId string, previewToken string) (*preview.Claims, error) {
+	if previewToken == "" {
+		return nil, nil
+	}
+
+	return s.PreviewTokens.VerifyFor(previewToken, workspaceId, "")
 }
 
 func (s Service) EnrollUser(token string, newUser enrolledusers.EnrolledUser) error {
@@ -91,7 +183,13 @@ func (s Service) EnrollUser(token string, newUser enrolledusers.EnrolledUser) er
 		return err
 	}
 	if existingUser != nil {
-		return nil
+		if newUser.Locale != "" && newUser.Locale != existingUser.Locale {
+			err = s.EnrolledUserService.UpdateLocale(apiClient.WorkspaceID, newUser.ExternalId, newUser.Locale)
+			if err != nil {
+				return err
+			}
+		}
+		return s.EnrolledUserService.UpdateAttributes(apiClient.WorkspaceID, newUser.ExternalId, newUser.Attributes)
 	}
 
 	newUser.WorkspaceId = apiClient.WorkspaceID
@@ -103,7 +201,7 @@ func (s Service) EnrollUser(token string, newUser enrolledusers.EnrolledUser) er
 	return nil
 }
 
-func (s Service) GetHelpers(token string) ([]helpers.Helper, error) {
+func (s Service) GetHelpers(token string, locale string, externalUserId string) ([]helpers.Helper, error) {
 	apiClient, err := s.ApiClientService.GetByToken(token)
 	if err != nil {
 		return nil, err
@@ -114,10 +212,35 @@ func (s Service) GetHelpers(token string) ([]helpers.Helper, error) {
 		return nil, err
 	}
 
+	enrolledUser, err := s.getOptionalUser(apiClient.WorkspaceID, externalUserId)
+	if err != nil {
+		return nil, err
+	}
+	if locale == "" && enrolledUser != nil {
+		locale = enrolledUser.Locale
+	}
+	for i := range resHelpers {
+		resHelpers[i] = helpers.LocalizeHelper(resHelpers[i], locale)
+	}
+
+	values, err := s.personalizationValues(apiClient.WorkspaceID, enrolledUser, helpers.PersonalizationNamespaces(resHelpers))
+	if err != nil {
+		return nil, err
+	}
+	for i := range resHelpers {
+		resHelpers[i] = helpers.PersonalizeHelper(resHelpers[i], values)
+	}
+
 	return resHelpers, nil
 }
 
-func (s Service) UpdateFlowState(workspaceId string, externalUserId string, payload FlowStateUpdateRequest) error {
+// UpdateFlowState records the progress of the user in the flow. With a preview token only the next step is
+// resolved, the state of the tester is left untouched.
+func (s Service) UpdateFlowState(workspaceId string, externalUserId string, payload FlowStateUpdateRequest, previewToken string) (*FlowStateUpdateResponse, error) {
+	if previewToken != "" {
+		return s.previewFlowState(workspaceId, externalUserId, payload, previewToken)
+	}
+
 	skippedFlowId := ""
 	skippedTimestamp := int64(0)
 	finishedFlowId := ""
@@ -133,30 +256,62 @@ func (s Service) UpdateFlowState(workspaceId string, externalUserId string, payl
 		skippedTimestamp = currentTimestamp
 	}
 
+	response := &FlowStateUpdateResponse{}
 	if skippedFlowId == "" && finishedFlowId == "" && payload.CurrentStepID == "" {
-		return nil
+		return response, nil
 	}
 
 	enrolledUser, err := s.EnrolledUserService.Get(workspaceId, externalUserId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if enrolledUser == nil {
-		return errors.New("user not found")
+		return nil, errors.New("user not found")
 	}
 
 	currentState, err := s.EnrolledUserService.GetState(workspaceId, enrolledUser.ID.Hex())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	currentState.FlowsData.CurrentStepID = payload.CurrentStepID
+	currentStepId := payload.CurrentStepID
+	if payload.BranchingOptionID != "" {
+		nextStep, err := s.chooseBranchingOption(workspaceId, currentState, payload, enrolledUser)
+		if err != nil {
+			return nil, err
+		}
+		if nextStep != nil {
+			currentStepId = nextStep.StepID
+		}
+		response.NextStep = nextStep
+	} else if choice := currentState.FlowsData.BranchChoice(payload.FlowID, payload.CurrentStepID); choice != "" {
+		// A user coming back to a branching step continues on the path they chose before
+		payload.BranchingOptionID = choice
+		nextStep, err := s.resumeBranchingChoice(workspaceId, payload, enrolledUser)
+		if err != nil {
+			return nil, err
+		}
+		if nextStep != nil {
+			currentStepId = nextStep.StepID
+		}
+		response.NextStep = nextStep
+	}
+
+	if payload.FlowID == currentState.FlowsData.CurrentFlowID || payload.FlowID == "" {
+		currentState.FlowsData.CurrentStepID = currentStepId
+	}
+	if payload.FlowID != "" && currentStepId != "" {
+		currentState.FlowsData.SetProgress(payload.FlowID, func(progress *enrolledusers.FlowProgress) {
+			progress.CurrentStepID = currentStepId
+		})
+	}
 
 	if skippedFlowId != "" {
 		currentState.FlowsData.SkippedFlowsIds = s.getUniqueValuesFromArr(append(currentState.FlowsData.SkippedFlowsIds, skippedFlowId))
-		if currentState.FlowsData.CurrentFlowID == skippedFlowId {
-			currentState.FlowsData.CurrentFlowID = ""
-		}
+		currentState.FlowsData.ClearCurrentFlow(skippedFlowId)
+		currentState.FlowsData.SetProgress(skippedFlowId, func(progress *enrolledusers.FlowProgress) {
+			progress.SkippedAt = skippedTimestamp
+		})
 		if skippedTimestamp > currentState.FlowsData.LastSubmittedFlowTimestamp {
 			currentState.FlowsData.LastSubmittedFlowTimestamp = skippedTimestamp
 			currentState.FlowsData.LastSubmittedFlowID = skippedFlowId
@@ -164,9 +319,10 @@ func (s Service) UpdateFlowState(workspaceId string, externalUserId string, payl
 	}
 	if finishedFlowId != "" {
 		currentState.FlowsData.CompletedFlowsIds = s.getUniqueValuesFromArr(append(currentState.FlowsData.CompletedFlowsIds, finishedFlowId))
-		if currentState.FlowsData.CurrentFlowID == finishedFlowId {
-			currentState.FlowsData.CurrentFlowID = ""
-		}
+		currentState.FlowsData.ClearCurrentFlow(finishedFlowId)
+		currentState.FlowsData.SetProgress(finishedFlowId, func(progress *enrolledusers.FlowProgress) {
+			progress.FinishedAt = finishedTimestamp
+		})
 		if finishedTimestamp > currentState.FlowsData.LastSubmittedFlowTimestamp {
 			currentState.FlowsData.LastSubmittedFlowTimestamp = finishedTimestamp
 			currentState.FlowsData.LastSubmittedFlowID = finishedFlowId
@@ -175,8 +331,104 @@ func (s Service) UpdateFlowState(workspaceId string, externalUserId string, payl
 	currentState.FlowsData.SkippedFlowsIds = s.excludeValuesFromArr(currentState.FlowsData.SkippedFlowsIds, currentState.FlowsData.CompletedFlowsIds)
 
 	err = s.EnrolledUserService.PutState(workspaceId, enrolledUser.ID.Hex(), *currentState)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// previewFlowState resolves the next step of a previewed draft without storing anything
+func (s Service) previewFlowState(workspaceId string, externalUserId string, payload FlowStateUpdateRequest, previewToken string) (*FlowStateUpdateResponse, error) {
+	previewFlow, err := s.getPreviewFlow(workspaceId, previewToken, payload.FlowID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &FlowStateUpdateResponse{}
+	if payload.BranchingOptionID == "" {
+		return response, nil
+	}
+
+	enrolledUser, err := s.getOptionalUser(workspaceId, externalUserId)
+	if err != nil {
+		return nil, err
+	}
+	response.NextStep, err = s.resolveNextStep(workspaceId, previewFlow, payload, enrolledUser)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// resolveNextStep renders the flow for the user and returns the step the chosen option routes to
+func (s Service) resolveNextStep(workspaceId string, resFlow *flows.Flow, payload FlowStateUpdateRequest, enrolledUser *enrolledusers.EnrolledUser) (*flows.Step, error) {
+	locale := ""
+	if enrolledUser != nil {
+		locale = enrolledUser.Locale
+	}
+	renderedFlow, err := s.renderFlow(workspaceId, resFlow, locale, enrolledUser)
+	if err != nil {
+		return nil, err
+	}
+
+	return flows.ResolveNextStep(renderedFlow, payload.CurrentStepID, payload.BranchingOptionID)
+}
+
+// resumeBranchingChoice resolves the step a stored choice routes to. A choice the flow no longer offers is
+// ignored and the user stays on the branching step to choose again.
+func (s Service) resumeBranchingChoice(workspaceId string, payload FlowStateUpdateRequest, enrolledUser *enrolledusers.EnrolledUser) (*flows.Step, error) {
+	resFlow, err := s.FlowEnroller.GetFlow(workspaceId, flows.EnrollmentOpts{
+		CurrentEnrollmentId: payload.FlowID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resFlow == nil {
+		return nil, nil
+	}
+
+	nextStep, err := s.resolveNextStep(workspaceId, resFlow, payload, enrolledUser)
+	if err != nil {
+		return nil, nil
+	}
+
+	return nextStep, nil
+}
+
+// chooseBranchingOption resolves the step the end user is routed to by the chosen option of a branching step
+// and remembers the choice, so the path stays consistent across sessions.
+func (s Service) chooseBranchingOption(workspaceId string, state *enrolledusers.UserState, payload FlowStateUpdateRequest, enrolledUser *enrolledusers.EnrolledUser) (*flows.Step, error) {
+	resFlow, err := s.FlowEnroller.GetFlow(workspaceId, flows.EnrollmentOpts{
+		CurrentEnrollmentId: payload.FlowID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resFlow == nil {
+		return nil, errors.New("flow not found")
+	}
+
+	nextStep, err := s.resolveNextStep(workspaceId, resFlow, payload, enrolledUser)
+	if err != nil {
+		return nil, err
+	}
+
+	choices := make([]enrolledusers.BranchChoice, 0, len(state.FlowsData.BranchChoices)+1)
+	for _, choice := range state.FlowsData.BranchChoices {
+		if choice.FlowID != payload.FlowID || choice.StepID != payload.CurrentStepID {
+			choices = append(choices, choice)
+		}
+	}
+	state.FlowsData.BranchChoices = append(choices, enrolledusers.BranchChoice{
+		FlowID:    payload.FlowID,
+		StepID:    payload.CurrentStepID,
+		OptionID:  payload.BranchingOptionID,
+		Timestamp: time.Now().Unix(),
+	})
 
-	return err
+	return nextStep, nil
 }
 
 func (s Service) getUniqueValuesFromArr(arr []string) []string {
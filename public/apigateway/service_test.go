@@ -37,7 +37,7 @@ func TestGroup(t *testing.T) {
 			Live:        true,
 		})
 
-		resFlow, err := service.GetFlow("token", newId.Hex())
+		resFlow, err := service.GetFlow("token", newId.Hex(), "", "", "")
 		if err != nil {
 			t.Fatalf("Error: %s", err)
 		}
@@ -51,10 +51,11 @@ func TestGroup(t *testing.T) {
 
 	t.Run("sanity test", func(t *testing.T) {
 
-		resFlow, err := service.EnrollInFlow("token", "userId")
+		slotFlows, err := service.EnrollInFlow("token", "userId", nil, "")
 		if err != nil {
 			t.Fatalf("Error: %s", err)
 		}
+		resFlow := slotFlows[flow2.FlowSlotModal]
 		if resFlow == nil {
 			t.Fatalf("Flow is nil")
 		}
@@ -0,0 +1,60 @@
+package enrolledusers
+
+import (
+	"errors"
+	"milestone_core/tours/personalization"
+	"strings"
+	"time"
+)
+
+// TargetingAttributes exposes the custom attributes of the user next to the built-in fields under "user",
+// which is what flow targeting expressions are evaluated against.
+func (u EnrolledUser) TargetingAttributes() map[string]any {
+	attributes := make(map[string]any, len(u.Attributes)+1)
+	for key, value := range u.Attributes {
+		attributes[key] = value
+	}
+
+	builtIn := map[string]any{
+		"externalId": u.ExternalId,
+		"email":      u.Email,
+		"name":       u.Name,
+		"segment":    u.Segment,
+		"locale":     u.Locale,
+		"created":    u.Created,
+	}
+	if u.SignUpTimestamp != 0 {
+		builtIn["signUpTimestamp"] = u.SignUpTimestamp
+		builtIn["daysSinceSignUp"] = int64(time.Since(time.Unix(u.SignUpTimestamp, 0)).Hours() / 24)
+	}
+	attributes["user"] = builtIn
+
+	return attributes
+}
+
+// PersonalizationValues exposes the built-in fields under "user" and the custom attributes under "attributes"
+// for the templates of flow and helper content
+func (u EnrolledUser) PersonalizationValues() personalization.Values {
+	values := make(personalization.Values)
+	for key, value := range u.TargetingAttributes() {
+		if builtIn, isBuiltIn := value.(map[string]any); isBuiltIn && key == personalization.NamespaceUser {
+			for field, fieldValue := range builtIn {
+				values[personalization.NamespaceUser+"."+field] = fieldValue
+			}
+			continue
+		}
+		values[personalization.NamespaceAttributes+"."+key] = value
+	}
+
+	return values
+}
+
+func validateAttributes(attributes map[string]any) error {
+	for key := range attributes {
+		if key == "" || key == "user" || strings.Contains(key, ".") || strings.HasPrefix(key, "$") {
+			return errors.New("invalid attribute name: " + key)
+		}
+	}
+
+	return nil
+}
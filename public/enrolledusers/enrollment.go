@@ -0,0 +1,31 @@
+package enrolledusers
+
+import (
+	"milestone_core/tours/flows"
+)
+
+// BuildEnrollmentOpts collects what the enroller needs to know about a user. The assignment maps of the state
+// are passed on, so new assignments made by the enroller end up in the state.
+func BuildEnrollmentOpts(user EnrolledUser, state *UserState, clientContext *flows.ClientContext) flows.EnrollmentOpts {
+	if state.FlowsData.RolloutAssignments == nil {
+		state.FlowsData.RolloutAssignments = make(map[string]flows.RolloutAssignment)
+	}
+	if state.FlowsData.ExperimentAssignments == nil {
+		state.FlowsData.ExperimentAssignments = make(map[string]flows.ExperimentAssignment)
+	}
+
+	return flows.EnrollmentOpts{
+		CurrentEnrollmentId:   state.FlowsData.CurrentFlowID,
+		CurrentEnrollments:    state.FlowsData.CurrentEnrollments(),
+		FinishedIds:           state.FlowsData.CompletedFlowsIds,
+		SkippedIds:            state.FlowsData.SkippedFlowsIds,
+		SignUpTimestamp:       user.SignUpTimestamp,
+		UserSegment:           user.Segment,
+		UserId:                user.ExternalId,
+		UserAttributes:        user.TargetingAttributes(),
+		ClientContext:         clientContext,
+		RolloutAssignments:    state.FlowsData.RolloutAssignments,
+		ExperimentAssignments: state.FlowsData.ExperimentAssignments,
+		History:               state.FlowsData.FlowHistories(),
+	}
+}
@@ -1,6 +1,9 @@
 package enrolledusers
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"milestone_core/tours/flows"
+)
 
 type EnrolledUser struct {
 	ID              primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
@@ -11,6 +14,8 @@ type EnrolledUser struct {
 	Name            string             `json:"name,omitempty" bson:"name,omitempty"`
 	SignUpTimestamp int64              `json:"signUpTimestamp,omitempty" bson:"signUpTimestamp,omitempty"`
 	Segment         string             `json:"segment,omitempty" bson:"segment,omitempty"`
+	Locale          string             `json:"locale,omitempty" bson:"locale,omitempty"`
+	Attributes      map[string]any     `json:"attributes,omitempty" bson:"attributes,omitempty"`
 }
 
 type UserState struct {
@@ -23,10 +28,35 @@ type UserState struct {
 }
 
 type FlowsData struct {
-	CompletedFlowsIds          []string `json:"completedFlowsIds" bson:"completedFlowsIds"`
-	SkippedFlowsIds            []string `json:"skippedFlowsIds" bson:"skippedFlowsIds"`
-	CurrentFlowID              string   `json:"currentFlowId" bson:"currentFlowId"`
-	CurrentStepID              string   `json:"currentStepId" bson:"currentStepId"`
-	LastSubmittedFlowID        string   `json:"lastSubmittedFlowId" bson:"lastSubmittedFlowId"`
-	LastSubmittedFlowTimestamp int64    `json:"lastSubmittedFlowTimestamp" bson:"lastSubmittedFlowTimestamp"`
+	CompletedFlowsIds          []string       `json:"completedFlowsIds" bson:"completedFlowsIds"`
+	SkippedFlowsIds            []string       `json:"skippedFlowsIds" bson:"skippedFlowsIds"`
+	CurrentFlowID              string         `json:"currentFlowId" bson:"currentFlowId"`
+	CurrentStepID              string         `json:"currentStepId" bson:"currentStepId"`
+	LastSubmittedFlowID        string         `json:"lastSubmittedFlowId" bson:"lastSubmittedFlowId"`
+	LastSubmittedFlowTimestamp int64          `json:"lastSubmittedFlowTimestamp" bson:"lastSubmittedFlowTimestamp"`
+	BranchChoices              []BranchChoice `json:"branchChoices,omitempty" bson:"branchChoices,omitempty"`
+
+	RolloutAssignments    map[string]flows.RolloutAssignment    `json:"rolloutAssignments,omitempty" bson:"rolloutAssignments,omitempty"`
+	ExperimentAssignments map[string]flows.ExperimentAssignment `json:"experimentAssignments,omitempty" bson:"experimentAssignments,omitempty"`
+
+	Progress map[string]FlowProgress `json:"progress,omitempty" bson:"progress,omitempty"`
+
+	// CurrentFlowIDs are the flows the user is in by slot. CurrentFlowID mirrors the modal slot for older SDKs.
+	CurrentFlowIDs map[flows.FlowSlot]string `json:"currentFlowIds,omitempty" bson:"currentFlowIds,omitempty"`
+}
+
+// FlowProgress keeps when the user started and submitted a flow and the step they are on
+type FlowProgress struct {
+	Slot          flows.FlowSlot `json:"slot,omitempty" bson:"slot,omitempty"`
+	CurrentStepID string         `json:"currentStepId,omitempty" bson:"currentStepId,omitempty"`
+	EnrolledAt    int64          `json:"enrolledAt,omitempty" bson:"enrolledAt,omitempty"`
+	FinishedAt    int64          `json:"finishedAt,omitempty" bson:"finishedAt,omitempty"`
+	SkippedAt     int64          `json:"skippedAt,omitempty" bson:"skippedAt,omitempty"`
+}
+
+type BranchChoice struct {
+	FlowID    string `json:"flowId" bson:"flowId"`
+	StepID    string `json:"stepId" bson:"stepId"`
+	OptionID  string `json:"optionId" bson:"optionId"`
+	Timestamp int64  `json:"timestamp" bson:"timestamp"`
 }
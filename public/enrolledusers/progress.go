@@ -0,0 +1,97 @@
+package enrolledusers
+
+import (
+	"milestone_core/tours/flows"
+	"slices"
+)
+
+// FlowHistories collects the timestamps the flow frequency caps are counted on by slot. Progress kept before
+// flows had types belongs to the modal slot. States written before the progress was kept only know the last
+// submitted flow, it is counted as a modal flow started at that time.
+func (d FlowsData) FlowHistories() map[flows.FlowSlot]flows.FlowHistory {
+	histories := make(map[flows.FlowSlot]flows.FlowHistory)
+	for _, progress := range d.Progress {
+		slot := progress.Slot
+		if slot == "" {
+			slot = flows.FlowSlotModal
+		}
+
+		history := histories[slot]
+		if progress.EnrolledAt != 0 {
+			history.EnrolledTimestamps = append(history.EnrolledTimestamps, progress.EnrolledAt)
+		}
+		if progress.SkippedAt > history.LastSkippedTimestamp {
+			history.LastSkippedTimestamp = progress.SkippedAt
+		}
+		histories[slot] = history
+	}
+
+	if len(d.Progress) == 0 && d.LastSubmittedFlowTimestamp != 0 {
+		history := flows.FlowHistory{EnrolledTimestamps: []int64{d.LastSubmittedFlowTimestamp}}
+		if slices.Contains(d.SkippedFlowsIds, d.LastSubmittedFlowID) {
+			history.LastSkippedTimestamp = d.LastSubmittedFlowTimestamp
+		}
+		histories[flows.FlowSlotModal] = history
+	}
+
+	return histories
+}
+
+// CurrentEnrollments returns the current flows by slot, states written before slots existed only have the
+// current flow, which is a modal one.
+func (d FlowsData) CurrentEnrollments() map[flows.FlowSlot]string {
+	currentEnrollments := make(map[flows.FlowSlot]string, len(d.CurrentFlowIDs))
+	for slot, flowId := range d.CurrentFlowIDs {
+		currentEnrollments[slot] = flowId
+	}
+	if len(d.CurrentFlowIDs) == 0 && d.CurrentFlowID != "" {
+		currentEnrollments[flows.FlowSlotModal] = d.CurrentFlowID
+	}
+
+	return currentEnrollments
+}
+
+// SetCurrentFlow sets the current flow of the slot, an empty flow id clears it
+func (d *FlowsData) SetCurrentFlow(slot flows.FlowSlot, flowId string) {
+	d.CurrentFlowIDs = d.CurrentEnrollments()
+	if flowId == "" {
+		delete(d.CurrentFlowIDs, slot)
+	} else {
+		d.CurrentFlowIDs[slot] = flowId
+	}
+
+	if slot == flows.FlowSlotModal {
+		d.CurrentFlowID = flowId
+	}
+}
+
+// ClearCurrentFlow empties the slot the flow is current in
+func (d *FlowsData) ClearCurrentFlow(flowId string) {
+	for slot, currentFlowId := range d.CurrentEnrollments() {
+		if currentFlowId == flowId {
+			d.SetCurrentFlow(slot, "")
+		}
+	}
+}
+
+// SetProgress applies the update to the progress of the flow, creating it when needed
+func (d *FlowsData) SetProgress(flowId string, update func(progress *FlowProgress)) {
+	if d.Progress == nil {
+		d.Progress = make(map[string]FlowProgress)
+	}
+
+	progress := d.Progress[flowId]
+	update(&progress)
+	d.Progress[flowId] = progress
+}
+
+// BranchChoice returns the option the user chose at the branching step of the flow, or an empty id
+func (d FlowsData) BranchChoice(flowId string, stepId string) string {
+	for _, choice := range d.BranchChoices {
+		if choice.FlowID == flowId && choice.StepID == stepId {
+			return choice.OptionID
+		}
+	}
+
+	return ""
+}
@@ -1,8 +1,10 @@
 package enrolledusers
 
 import (
+	"errors"
 	"math"
 	"milestone_core/shared/server"
+	"milestone_core/tours/flows"
 	"net/http"
 	"strconv"
 
@@ -11,6 +13,7 @@ import (
 
 type UsersResource struct {
 	UsersService Service
+	FlowEnroller flows.Enroller
 }
 
 func (rs UsersResource) Routes() chi.Router {
@@ -20,6 +23,8 @@ func (rs UsersResource) Routes() chi.Router {
 	r.Route("/{id}", func(r chi.Router) {
 		r.Delete("/", rs.Delete)
 		r.Post("/reset", rs.ResetState)
+		// The eligibility is looked up by the external id, the one customers know their users by
+		r.Get("/eligibility", rs.GetEligibility)
 	})
 
 	return r
@@ -96,3 +101,32 @@ func (rs UsersResource) ResetState(w http.ResponseWriter, r *http.Request) {
 
 	server.SendJson(w, nil)
 }
+
+func (rs UsersResource) GetEligibility(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	externalId := chi.URLParam(r, "id")
+
+	user, err := rs.UsersService.Get(workspaceId, externalId)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+	if user == nil {
+		server.SendBadRequestErrorJson(w, errors.New("user not found"))
+		return
+	}
+
+	state, err := rs.UsersService.GetState(workspaceId, user.ID.Hex())
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	report, err := rs.FlowEnroller.Explain(workspaceId, BuildEnrollmentOpts(*user, state, nil))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, report)
+}
@@ -6,6 +6,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"milestone_core/tours/translations"
 	"time"
 )
 
@@ -48,7 +49,13 @@ func (s Service) Get(workspace string, externalId string) (*EnrolledUser, error)
 }
 
 func (s Service) Create(user EnrolledUser) error {
+	err := validateAttributes(user.Attributes)
+	if err != nil {
+		return err
+	}
+
 	user.Created = time.Now().Unix()
+	user.Locale = translations.NormalizeLocale(user.Locale)
 	result, err := s.Collection.InsertOne(context.Background(), user)
 	if err != nil {
 		return err
@@ -66,6 +73,30 @@ func (s Service) Create(user EnrolledUser) error {
 	return err
 }
 
+// UpdateAttributes merges the given attributes into the ones already stored for the user
+func (s Service) UpdateAttributes(workspace string, externalId string, attributes map[string]any) error {
+	if len(attributes) == 0 {
+		return nil
+	}
+	err := validateAttributes(attributes)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{}
+	for key, value := range attributes {
+		update["attributes."+key] = value
+	}
+	_, err = s.Collection.UpdateOne(context.Background(), bson.M{"externalId": externalId, "workspaceId": workspace}, bson.M{"$set": update})
+
+	return err
+}
+
+func (s Service) UpdateLocale(workspace string, externalId string, locale string) error {
+	_, err := s.Collection.UpdateOne(context.Background(), bson.M{"externalId": externalId, "workspaceId": workspace}, bson.M{"$set": bson.M{"locale": translations.NormalizeLocale(locale)}})
+	return err
+}
+
 func (s Service) Delete(workspace string, id string) error {
 	primitiveId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
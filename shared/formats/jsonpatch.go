@@ -0,0 +1,124 @@
+package formats
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type PatchOperation struct {
+	Op       string `json:"op"`
+	Path     string `json:"path"`
+	Value    any    `json:"value,omitempty"`
+	OldValue any    `json:"oldValue,omitempty"`
+}
+
+const (
+	PatchOpAdd     = "add"
+	PatchOpRemove  = "remove"
+	PatchOpReplace = "replace"
+)
+
+// Diff returns the JSON patch (RFC 6902) that transforms before into after.
+// Both values are compared through their JSON representation, removed values are kept in OldValue.
+func Diff(before any, after any) ([]PatchOperation, error) {
+	normalizedBefore, err := normalizeJson(before)
+	if err != nil {
+		return nil, err
+	}
+	normalizedAfter, err := normalizeJson(after)
+	if err != nil {
+		return nil, err
+	}
+
+	operations := make([]PatchOperation, 0)
+	diffValues("", normalizedBefore, normalizedAfter, &operations)
+
+	return operations, nil
+}
+
+func normalizeJson(value any) (any, error) {
+	rawJson, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized any
+	err = json.Unmarshal(rawJson, &normalized)
+
+	return normalized, err
+}
+
+func diffValues(path string, before any, after any, operations *[]PatchOperation) {
+	switch beforeValue := before.(type) {
+	case map[string]any:
+		afterValue, ok := after.(map[string]any)
+		if !ok {
+			*operations = append(*operations, PatchOperation{Op: PatchOpReplace, Path: path, Value: after, OldValue: before})
+			return
+		}
+		diffObjects(path, beforeValue, afterValue, operations)
+	case []any:
+		afterValue, ok := after.([]any)
+		if !ok {
+			*operations = append(*operations, PatchOperation{Op: PatchOpReplace, Path: path, Value: after, OldValue: before})
+			return
+		}
+		diffArrays(path, beforeValue, afterValue, operations)
+	default:
+		if !reflect.DeepEqual(before, after) {
+			*operations = append(*operations, PatchOperation{Op: PatchOpReplace, Path: path, Value: after, OldValue: before})
+		}
+	}
+}
+
+func diffObjects(path string, before map[string]any, after map[string]any, operations *[]PatchOperation) {
+	keys := make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := path + "/" + escapePointerToken(key)
+		beforeValue, inBefore := before[key]
+		afterValue, inAfter := after[key]
+
+		switch {
+		case inBefore && !inAfter:
+			*operations = append(*operations, PatchOperation{Op: PatchOpRemove, Path: childPath, OldValue: beforeValue})
+		case !inBefore && inAfter:
+			*operations = append(*operations, PatchOperation{Op: PatchOpAdd, Path: childPath, Value: afterValue})
+		default:
+			diffValues(childPath, beforeValue, afterValue, operations)
+		}
+	}
+}
+
+func diffArrays(path string, before []any, after []any, operations *[]PatchOperation) {
+	commonLength := min(len(before), len(after))
+	for i := 0; i < commonLength; i++ {
+		diffValues(path+"/"+strconv.Itoa(i), before[i], after[i], operations)
+	}
+
+	for i := commonLength; i < len(after); i++ {
+		*operations = append(*operations, PatchOperation{Op: PatchOpAdd, Path: path + "/" + strconv.Itoa(i), Value: after[i]})
+	}
+
+	// Removals go from the end so the indexes of the remaining operations stay valid
+	for i := len(before) - 1; i >= commonLength; i-- {
+		*operations = append(*operations, PatchOperation{Op: PatchOpRemove, Path: path + "/" + strconv.Itoa(i), OldValue: before[i]})
+	}
+}
+
+func escapePointerToken(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	return strings.ReplaceAll(token, "/", "~1")
+}
@@ -0,0 +1,71 @@
+package formats
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	type item struct {
+		ID   string `json:"id"`
+		Name string `json:"name,omitempty"`
+	}
+	type document struct {
+		Name  string `json:"name"`
+		Items []item `json:"items"`
+	}
+
+	t.Run("equal documents produce no operations", func(t *testing.T) {
+		doc := document{Name: "a", Items: []item{{ID: "1"}}}
+		operations, err := Diff(doc, doc)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if len(operations) != 0 {
+			t.Fatalf("Expected no operations, got %v", operations)
+		}
+	})
+
+	t.Run("changed fields are replaced", func(t *testing.T) {
+		operations, err := Diff(document{Name: "a"}, document{Name: "b"})
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if len(operations) != 1 || operations[0].Op != PatchOpReplace || operations[0].Path != "/name" {
+			t.Fatalf("Unexpected operations: %v", operations)
+		}
+		if operations[0].Value != "b" || operations[0].OldValue != "a" {
+			t.Fatalf("Unexpected values: %v", operations[0])
+		}
+	})
+
+	t.Run("array items are added and removed from the end", func(t *testing.T) {
+		operations, err := Diff(
+			document{Items: []item{{ID: "1"}, {ID: "2"}, {ID: "3"}}},
+			document{Items: []item{{ID: "1"}}},
+		)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if len(operations) != 2 || operations[0].Path != "/items/2" || operations[1].Path != "/items/1" {
+			t.Fatalf("Unexpected operations: %v", operations)
+		}
+
+		operations, err = Diff(document{}, document{Items: []item{{ID: "1", Name: "new"}}})
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if len(operations) != 1 || operations[0].Op != PatchOpReplace || operations[0].Path != "/items" {
+			t.Fatalf("Unexpected operations: %v", operations)
+		}
+	})
+
+	t.Run("object keys are escaped", func(t *testing.T) {
+		operations, err := Diff(map[string]string{}, map[string]string{"a/b~c": "x"})
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if len(operations) != 1 || operations[0].Op != PatchOpAdd || operations[0].Path != "/a~1b~0c" {
+			t.Fatalf("Unexpected operations: %v", operations)
+		}
+	})
+}
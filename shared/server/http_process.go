@@ -28,6 +28,10 @@ func GetUserIdFromContext(ctx context.Context) string {
 	return userData.UserID
 }
 
+func GetUserFromContext(ctx context.Context) authorization.UserData {
+	return ctx.Value("user").(authorization.UserData)
+}
+
 func GetTokenFromPublicApiClientContext(ctx context.Context) string {
 	publicApiClientData := ctx.Value("user").(authorization.UserData)
 
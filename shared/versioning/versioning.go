@@ -0,0 +1,106 @@
+package versioning
+
+import (
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"milestone_core/shared/rest"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AnyVersion skips the version check, it is what "If-Match: *" and optional checks without a header resolve to
+const AnyVersion int64 = -1
+
+var ErrVersionRequired = errors.New("the If-Match header with the current version is required")
+
+// ConflictError is returned when a document was saved by someone else since the editor read it. Current is the
+// stored document, so the editor can merge the changes.
+type ConflictError struct {
+	Version int64
+	Current any
+}
+
+func (e *ConflictError) Error() string {
+	return "the document was changed in the meantime, the current version is " + strconv.FormatInt(e.Version, 10)
+}
+
+// Check compares the version the editor read with the stored version of the document
+func Check(expected int64, current int64, document any) error {
+	if expected == AnyVersion || expected == current {
+		return nil
+	}
+
+	return &ConflictError{Version: current, Current: document}
+}
+
+// Filter is the condition on the version field of a conditional write. Documents stored before versioning was
+// introduced have no version field and count as version 0.
+func Filter(version int64) any {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+
+	return version
+}
+
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+func SetETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", ETag(version))
+}
+
+// IfMatch returns the version of the If-Match header, writes that replace a document require it
+func IfMatch(r *http.Request) (int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return 0, ErrVersionRequired
+	}
+	if header == "*" {
+		return AnyVersion, nil
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+	if err != nil || version < 0 {
+		return 0, errors.New("invalid If-Match header: " + header)
+	}
+
+	return version, nil
+}
+
+// OptionalIfMatch returns the version of the If-Match header, or AnyVersion when there is none
+func OptionalIfMatch(r *http.Request) (int64, error) {
+	if r.Header.Get("If-Match") == "" {
+		return AnyVersion, nil
+	}
+
+	return IfMatch(r)
+}
+
+// SendError answers a missing version with 428 and a conflict with 409 and the current document. It returns
+// false for other errors, which are left to the caller.
+func SendError(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, ErrVersionRequired) {
+		rest.SendErrorResponse(w, err, http.StatusPreconditionRequired)
+		return true
+	}
+
+	var conflictError *ConflictError
+	if errors.As(err, &conflictError) {
+		SetETag(w, conflictError.Version)
+		rest.SendResponse(w, struct {
+			Error   string `json:"error"`
+			Version int64  `json:"version"`
+			Current any    `json:"current"`
+		}{
+			Error:   conflictError.Error(),
+			Version: conflictError.Version,
+			Current: conflictError.Current,
+		}, http.StatusConflict)
+		return true
+	}
+
+	return false
+}
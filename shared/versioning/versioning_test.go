@@ -0,0 +1,38 @@
+package versioning
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIfMatch(t *testing.T) {
+	t.Run("versions are read from strong, weak and wildcard tags", func(t *testing.T) {
+		for header, expected := range map[string]int64{`"3"`: 3, `W/"4"`: 4, "5": 5, "*": AnyVersion} {
+			request := httptest.NewRequest("PUT", "/", nil)
+			request.Header.Set("If-Match", header)
+			version, err := IfMatch(request)
+			if err != nil || version != expected {
+				t.Fatalf("Expected version %d for %s, got %d and %v", expected, header, version, err)
+			}
+		}
+	})
+
+	t.Run("the header is required unless optional", func(t *testing.T) {
+		request := httptest.NewRequest("PUT", "/", nil)
+		if _, err := IfMatch(request); err != ErrVersionRequired {
+			t.Fatalf("Expected the missing header to be required, got %v", err)
+		}
+		if version, err := OptionalIfMatch(request); err != nil || version != AnyVersion {
+			t.Fatalf("Expected any version without the header, got %d and %v", version, err)
+		}
+	})
+
+	t.Run("stale versions conflict", func(t *testing.T) {
+		if Check(2, 3, nil) == nil {
+			t.Fatalf("Expected a stale version to conflict")
+		}
+		if Check(3, 3, nil) != nil || Check(AnyVersion, 3, nil) != nil {
+			t.Fatalf("Expected the current and any version to pass")
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"milestone_core/shared/formats"
+)
+
+type EntityType string
+
+const (
+	EntityTypeFlow      EntityType = "flow"
+	EntityTypeHelper    EntityType = "helper"
+	EntityTypeBranching EntityType = "branching"
+)
+
+type Action string
+
+const (
+	ActionCreated     Action = "created"
+	ActionUpdated     Action = "updated"
+	ActionDeleted     Action = "deleted"
+	ActionArchived    Action = "archived"
+	ActionRestored    Action = "restored"
+	ActionPublished   Action = "published"
+	ActionUnpublished Action = "unpublished"
+	ActionRolledBack  Action = "rolled_back"
+)
+
+// Actor is the dashboard user behind a change, background jobs use a fixed user id without an email
+type Actor struct {
+	UserID string `json:"userId" bson:"userId"`
+	Email  string `json:"email,omitempty" bson:"email,omitempty"`
+}
+
+// Change is one entry of the append-only change log. Patch turns the entity before the change into the entity
+// after it, created entities are one replace of the root and deleted entities keep their content as old value.
+type Change struct {
+	ID          primitive.ObjectID       `json:"id" bson:"_id,omitempty"`
+	WorkspaceID string                   `json:"-" bson:"workspaceId"`
+	EntityType  EntityType               `json:"entityType" bson:"entityType"`
+	EntityID    string                   `json:"entityId" bson:"entityId"`
+	Action      Action                   `json:"action" bson:"action"`
+	Actor       Actor                    `json:"actor" bson:"actor"`
+	Timestamp   int64                    `json:"timestamp" bson:"timestamp"`
+	Patch       []formats.PatchOperation `json:"patch" bson:"patch"`
+}
+
+type ActivityFilter struct {
+	EntityType EntityType
+	EntityID   string
+	ActorID    string
+	Action     Action
+	// From and To limit the timestamps of the changes, zero means no limit
+	From int64
+	To   int64
+	// Before is the id of the last change of the previous page
+	Before string
+	Limit  int64
+}
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"milestone_core/shared/server"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type Resource struct {
+	Log Log
+}
+
+func (rs Resource) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", rs.Activity)
+	r.Get("/{entityType}/{entityId}", rs.History)
+
+	return r
+}
+
+// Activity lists the changes of the workspace, filtered by entityType, entityId, actorId, action, from and to
+func (rs Resource) Activity(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	filter, err := parseFilter(r.URL.Query())
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	changes, err := rs.Log.Activity(workspaceId, filter)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, changes)
+}
+
+func (rs Resource) History(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	filter, err := parseFilter(r.URL.Query())
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	entityType := EntityType(chi.URLParam(r, "entityType"))
+	changes, err := rs.Log.History(workspaceId, entityType, chi.URLParam(r, "entityId"), filter)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, changes)
+}
+
+func parseFilter(query url.Values) (ActivityFilter, error) {
+	filter := ActivityFilter{
+		EntityType: EntityType(query.Get("entityType")),
+		EntityID:   query.Get("entityId"),
+		ActorID:    query.Get("actorId"),
+		Action:     Action(query.Get("action")),
+		Before:     query.Get("before"),
+	}
+
+	numbers := map[string]*int64{"from": &filter.From, "to": &filter.To, "limit": &filter.Limit}
+	for name, target := range numbers {
+		if query.Get(name) == "" {
+			continue
+		}
+		value, err := strconv.ParseInt(query.Get(name), 10, 64)
+		if err != nil {
+			return filter, errors.New("invalid " + name + " parameter")
+		}
+		*target = value
+	}
+
+	return filter, nil
+}
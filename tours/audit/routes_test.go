@@ -0,0 +1,25 @@
+package audit
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	t.Run("query parameters become the filter", func(t *testing.T) {
+		query := url.Values{"entityType": {"flow"}, "actorId": {"user-1"}, "action": {"updated"}, "from": {"100"}, "limit": {"10"}}
+		filter, err := parseFilter(query)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if filter.EntityType != EntityTypeFlow || filter.ActorID != "user-1" || filter.Action != ActionUpdated || filter.From != 100 || filter.To != 0 || filter.Limit != 10 {
+			t.Fatalf("Unexpected filter %+v", filter)
+		}
+	})
+
+	t.Run("invalid numbers are rejected", func(t *testing.T) {
+		if _, err := parseFilter(url.Values{"to": {"yesterday"}}); err == nil {
+			t.Fatalf("Expected an invalid timestamp to be rejected")
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"milestone_core/shared/formats"
+	"milestone_core/shared/server"
+	"time"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 200
+)
+
+// Log stores the changes of the dashboard entities. Entries are only ever inserted.
+type Log struct {
+	Collection *mongo.Collection
+}
+
+// ActorFromContext returns the dashboard user of the request
+func ActorFromContext(ctx context.Context) Actor {
+	user := server.GetUserFromContext(ctx)
+
+	return Actor{UserID: user.UserID, Email: user.Email}
+}
+
+// Snapshot captures the JSON form of an entity before it is changed in place
+func Snapshot(entity any) (any, error) {
+	content, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot any
+	err = json.Unmarshal(content, &snapshot)
+
+	return snapshot, err
+}
+
+// Record appends the change between the two versions of the entity. Updates that change nothing are skipped.
+func (l Log) Record(workspace string, actor Actor, entityType EntityType, entityId string, action Action, before any, after any) error {
+	if l.Collection == nil {
+		return nil
+	}
+
+	patch, err := formats.Diff(before, after)
+	if err != nil {
+		return err
+	}
+	if len(patch) == 0 && action == ActionUpdated {
+		return nil
+	}
+
+	_, err = l.Collection.InsertOne(context.Background(), Change{
+		WorkspaceID: workspace,
+		EntityType:  entityType,
+		EntityID:    entityId,
+		Action:      action,
+		Actor:       actor,
+		Timestamp:   time.Now().Unix(),
+		Patch:       patch,
+	})
+
+	return err
+}
+
+// History returns the changes of one entity, newest first
+func (l Log) History(workspace string, entityType EntityType, entityId string, filter ActivityFilter) ([]Change, error) {
+	filter.EntityType = entityType
+	filter.EntityID = entityId
+
+	return l.Activity(workspace, filter)
+}
+
+// Activity returns the changes of the workspace matching the filter, newest first
+func (l Log) Activity(workspace string, filter ActivityFilter) ([]Change, error) {
+	query := bson.M{"workspaceId": workspace}
+	if filter.EntityType != "" {
+		query["entityType"] = filter.EntityType
+	}
+	if filter.EntityID != "" {
+		query["entityId"] = filter.EntityID
+	}
+	if filter.ActorID != "" {
+		query["actor.userId"] = filter.ActorID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+
+	timestamp := bson.M{}
+	if filter.From != 0 {
+		timestamp["$gte"] = filter.From
+	}
+	if filter.To != 0 {
+		timestamp["$lte"] = filter.To
+	}
+	if len(timestamp) > 0 {
+		query["timestamp"] = timestamp
+	}
+
+	if filter.Before != "" {
+		before, err := primitive.ObjectIDFromHex(filter.Before)
+		if err != nil {
+			return nil, errors.New("invalid before id")
+		}
+		query["_id"] = bson.M{"$lt": before}
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	findOpts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit)
+	cursor, err := l.Collection.Find(context.Background(), query, findOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]Change, 0)
+	err = cursor.All(context.Background(), &changes)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
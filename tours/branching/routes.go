@@ -4,12 +4,15 @@ import (
 	"encoding/json"
 	"github.com/go-chi/chi/v5"
 	"milestone_core/shared/server"
+	"milestone_core/shared/versioning"
+	"milestone_core/tours/audit"
 	"milestone_core/tours/flows"
 	"net/http"
 )
 
 type BranchingResource struct {
-	BranchingService flows.BranchingService
+	BranchingService  flows.BranchingService
+	ExperimentService flows.ExperimentService
 }
 
 type BranchingCtx struct {
@@ -28,6 +31,7 @@ func (rs BranchingResource) Routes() chi.Router {
 		//r.Use(rs.FlowCtx)     // lets have a users map, and lets actually load/manipulate
 		r.Get("/", rs.Get)
 		r.Put("/", rs.Update)
+		r.Get("/results", rs.GetResults)
 	})
 
 	return r
@@ -55,27 +59,36 @@ func (rs BranchingResource) Get(w http.ResponseWriter, r *http.Request) {
 		server.SendBadRequestErrorJson(w, err)
 		return
 	}
+	if branch != nil {
+		versioning.SetETag(w, branch.Version)
+	}
 
 	server.SendJson(w, branch)
 }
 
 func (rs BranchingResource) Update(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
+	version, err := versioning.IfMatch(r)
+	if err != nil {
+		sendError(w, err)
+		return
+	}
 
 	var updateInput flows.Branching
-	err := json.NewDecoder(r.Body).Decode(&updateInput)
+	err = json.NewDecoder(r.Body).Decode(&updateInput)
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
 	}
 
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
-	err = rs.BranchingService.Update(workspaceId, idParam, updateInput)
+	version, err = rs.BranchingService.Update(workspaceId, idParam, updateInput, version, audit.ActorFromContext(r.Context()))
 	if err != nil {
-		server.SendBadRequestErrorJson(w, err)
+		sendError(w, err)
 		return
 	}
 
+	versioning.SetETag(w, version)
 	server.SendJson(w, "updated branch with id: "+idParam)
 }
 
@@ -88,7 +101,7 @@ func (rs BranchingResource) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
-	res, err := rs.BranchingService.Create(workspaceId, branch)
+	res, err := rs.BranchingService.Create(workspaceId, branch, audit.ActorFromContext(r.Context()))
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
@@ -96,3 +109,25 @@ func (rs BranchingResource) Create(w http.ResponseWriter, r *http.Request) {
 
 	server.SendJson(w, res)
 }
+
+func (rs BranchingResource) GetResults(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	results, err := rs.ExperimentService.GetResults(workspaceId, idParam)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, results)
+}
+
+// sendError answers version conflicts with the current branching so the editor can merge
+func sendError(w http.ResponseWriter, err error) {
+	if versioning.SendError(w, err) {
+		return
+	}
+
+	server.SendBadRequestErrorJson(w, err)
+}
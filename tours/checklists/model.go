@@ -0,0 +1,74 @@
+package checklists
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type Checklist struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	WorkspaceID string             `json:"-" bson:"workspaceId"`
+	Name        string             `json:"name" bson:"name"`
+	Description string             `json:"description,omitempty" bson:"description,omitempty"`
+	Items       []ChecklistItem    `json:"items" bson:"items"`
+	Published   bool               `json:"published" bson:"published"`
+	Created     int64              `json:"created" bson:"created"`
+	Updated     int64              `json:"updated" bson:"updated"`
+}
+
+type ChecklistItem struct {
+	ID          string         `json:"id" bson:"id"`
+	Title       string         `json:"title" bson:"title"`
+	Description string         `json:"description,omitempty" bson:"description,omitempty"`
+	Completion  ItemCompletion `json:"completion" bson:"completion"`
+}
+
+type CompletionType string
+
+const (
+	// CompletionTypeFlowFinished completes the item when the user finishes the flow
+	CompletionTypeFlowFinished CompletionType = "flow_finished"
+	// CompletionTypeEvent completes the item when the gamification event is tracked for the user
+	CompletionTypeEvent CompletionType = "event"
+	// CompletionTypeManual items are only completed through the public api
+	CompletionTypeManual CompletionType = "manual"
+)
+
+type ItemCompletion struct {
+	Type     CompletionType `json:"type" bson:"type"`
+	FlowID   string         `json:"flowId,omitempty" bson:"flowId,omitempty"`
+	EventKey string         `json:"eventKey,omitempty" bson:"eventKey,omitempty"`
+}
+
+type ChecklistInput struct {
+	Name        *string         `json:"name,omitempty"`
+	Description *string         `json:"description,omitempty"`
+	Items       []ChecklistItem `json:"items,omitempty"`
+	Published   *bool           `json:"published,omitempty"`
+}
+
+// UserChecklist keeps the items a user completed with their completion timestamps. Completions are stored the
+// first time they are seen, so the progress does not change when a flow or event is deleted later.
+type UserChecklist struct {
+	ID             primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	WorkspaceID    string             `json:"-" bson:"workspaceId"`
+	ChecklistID    string             `json:"checklistId" bson:"checklistId"`
+	ExternalUserID string             `json:"externalUserId" bson:"externalUserId"`
+	CompletedItems map[string]int64   `json:"completedItems" bson:"completedItems"`
+}
+
+type ChecklistProgress struct {
+	ChecklistID    string         `json:"checklistId"`
+	Name           string         `json:"name"`
+	Description    string         `json:"description,omitempty"`
+	Items          []ItemProgress `json:"items"`
+	CompletedCount int            `json:"completedCount"`
+	TotalCount     int            `json:"totalCount"`
+	Completed      bool           `json:"completed"`
+}
+
+type ItemProgress struct {
+	ItemID      string         `json:"itemId"`
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Completion  CompletionType `json:"completion"`
+	Completed   bool           `json:"completed"`
+	CompletedAt int64          `json:"completedAt,omitempty"`
+}
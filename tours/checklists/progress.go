@@ -0,0 +1,61 @@
+package checklists
+
+// userFacts holds what the automatic completions are checked against, with the time each fact happened
+type userFacts struct {
+	FinishedFlows map[string]int64
+	EventKeys     map[string]int64
+}
+
+// evaluateProgress combines the stored completions with the facts of the user. Items that are completed by the
+// facts for the first time are returned separately, so they can be stored and tracked once.
+func evaluateProgress(checklist Checklist, completedItems map[string]int64, facts userFacts, now int64) (ChecklistProgress, map[string]int64) {
+	progress := ChecklistProgress{
+		ChecklistID: checklist.ID.Hex(),
+		Name:        checklist.Name,
+		Description: checklist.Description,
+		Items:       make([]ItemProgress, 0, len(checklist.Items)),
+		TotalCount:  len(checklist.Items),
+	}
+	newCompletions := make(map[string]int64)
+
+	for _, item := range checklist.Items {
+		completedAt, completed := completedItems[item.ID]
+		if !completed {
+			completedAt, completed = facts.completedAt(item.Completion)
+			if completed {
+				if completedAt == 0 {
+					completedAt = now
+				}
+				newCompletions[item.ID] = completedAt
+			}
+		}
+
+		if completed {
+			progress.CompletedCount++
+		}
+		progress.Items = append(progress.Items, ItemProgress{
+			ItemID:      item.ID,
+			Title:       item.Title,
+			Description: item.Description,
+			Completion:  item.Completion.Type,
+			Completed:   completed,
+			CompletedAt: completedAt,
+		})
+	}
+	progress.Completed = progress.TotalCount > 0 && progress.CompletedCount == progress.TotalCount
+
+	return progress, newCompletions
+}
+
+func (f userFacts) completedAt(completion ItemCompletion) (int64, bool) {
+	switch completion.Type {
+	case CompletionTypeFlowFinished:
+		finishedAt, ok := f.FinishedFlows[completion.FlowID]
+		return finishedAt, ok
+	case CompletionTypeEvent:
+		trackedAt, ok := f.EventKeys[completion.EventKey]
+		return trackedAt, ok
+	}
+
+	return 0, false
+}
@@ -0,0 +1,60 @@
+package checklists
+
+import (
+	"testing"
+)
+
+func TestEvaluateProgress(t *testing.T) {
+	checklist := Checklist{
+		Name: "Getting started",
+		Items: []ChecklistItem{
+			{ID: "profile", Title: "Complete your profile", Completion: ItemCompletion{Type: CompletionTypeFlowFinished, FlowID: "flow_1"}},
+			{ID: "invite", Title: "Invite a teammate", Completion: ItemCompletion{Type: CompletionTypeEvent, EventKey: "teammate_invited"}},
+			{ID: "project", Title: "Create first project", Completion: ItemCompletion{Type: CompletionTypeManual}},
+		},
+	}
+	now := int64(1_720_000_000)
+
+	t.Run("facts complete items once", func(t *testing.T) {
+		facts := userFacts{
+			FinishedFlows: map[string]int64{"flow_1": 0},
+			EventKeys:     map[string]int64{"teammate_invited": now - 60},
+		}
+
+		progress, newCompletions := evaluateProgress(checklist, map[string]int64{"invite": now - 120}, facts, now)
+		if progress.CompletedCount != 2 || progress.TotalCount != 3 || progress.Completed {
+			t.Fatalf("Expected 2 of 3 items to be completed, got %+v", progress)
+		}
+		if len(newCompletions) != 1 || newCompletions["profile"] != now {
+			t.Fatalf("Expected only the finished flow to be a new completion, got %v", newCompletions)
+		}
+		if progress.Items[1].CompletedAt != now-120 {
+			t.Fatalf("Expected stored completions to keep their timestamp, got %d", progress.Items[1].CompletedAt)
+		}
+	})
+
+	t.Run("manual items are only completed when stored", func(t *testing.T) {
+		progress, _ := evaluateProgress(checklist, map[string]int64{"profile": now, "invite": now, "project": now}, userFacts{}, now)
+		if !progress.Completed {
+			t.Fatalf("Expected the checklist to be completed, got %+v", progress)
+		}
+	})
+}
+
+func TestValidateChecklist(t *testing.T) {
+	t.Run("rejects items without their completion source", func(t *testing.T) {
+		checklist := Checklist{Name: "Getting started", Items: []ChecklistItem{
+			{ID: "invite", Title: "Invite a teammate", Completion: ItemCompletion{Type: CompletionTypeEvent}},
+		}}
+		if ValidateChecklist(checklist) == nil {
+			t.Fatalf("Expected an event item without an event key to be invalid")
+		}
+	})
+
+	t.Run("rejects duplicate item ids", func(t *testing.T) {
+		item := ChecklistItem{ID: "project", Title: "Create first project", Completion: ItemCompletion{Type: CompletionTypeManual}}
+		if ValidateChecklist(Checklist{Name: "Getting started", Items: []ChecklistItem{item, item}}) == nil {
+			t.Fatalf("Expected duplicate item ids to be invalid")
+		}
+	})
+}
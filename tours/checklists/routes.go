@@ -0,0 +1,100 @@
+package checklists
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"milestone_core/shared/server"
+	"net/http"
+)
+
+type Resource struct {
+	Service Service
+}
+
+func (rs Resource) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", rs.List)
+	r.Post("/", rs.Create)
+	r.Route("/{id}", func(r chi.Router) {
+		r.Get("/", rs.Get)
+		r.Put("/", rs.Update)
+		r.Delete("/", rs.Delete)
+	})
+
+	return r
+}
+
+func (rs Resource) List(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	checklists, err := rs.Service.List(workspaceId)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, checklists)
+}
+
+func (rs Resource) Get(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	id := chi.URLParam(r, "id")
+
+	checklist, err := rs.Service.Get(workspaceId, id)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, checklist)
+}
+
+func (rs Resource) Create(w http.ResponseWriter, r *http.Request) {
+	var input ChecklistInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	checklist, err := rs.Service.Create(workspaceId, input)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, checklist)
+}
+
+func (rs Resource) Update(w http.ResponseWriter, r *http.Request) {
+	var input ChecklistInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	id := chi.URLParam(r, "id")
+	checklist, err := rs.Service.Update(workspaceId, id, input)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, checklist)
+}
+
+func (rs Resource) Delete(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	id := chi.URLParam(r, "id")
+
+	err := rs.Service.Delete(workspaceId, id)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, "deleted checklist with id: "+id)
+}
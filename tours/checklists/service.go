@@ -0,0 +1,341 @@
+package checklists
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"milestone_core/gamification/events"
+	"milestone_core/public/enrolledusers"
+	"milestone_core/tours/tracker"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Service struct {
+	Collection          *mongo.Collection
+	UserCollection      *mongo.Collection
+	EnrolledUserService enrolledusers.Service
+	EventsService       events.Service
+	Tracker             tracker.Tracker
+}
+
+// EnsureIndexes creates the indexes the checklist collections rely on. Each user has one progress document per
+// checklist, completions are written with upserts that must not create a second one.
+func (s Service) EnsureIndexes() error {
+	_, err := s.UserCollection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "workspaceId", Value: 1}, {Key: "checklistId", Value: 1}, {Key: "externalUserId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	return err
+}
+
+func (s Service) List(workspaceId string) ([]Checklist, error) {
+	cursor, err := s.Collection.Find(context.Background(), bson.M{"workspaceId": workspaceId})
+	if err != nil {
+		return nil, err
+	}
+
+	checklists := make([]Checklist, 0)
+	if err = cursor.All(context.Background(), &checklists); err != nil {
+		return nil, err
+	}
+
+	return checklists, nil
+}
+
+func (s Service) Get(workspaceId string, id string) (*Checklist, error) {
+	primitiveId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var checklist Checklist
+	err = s.Collection.FindOne(context.Background(), bson.M{"_id": primitiveId, "workspaceId": workspaceId}).Decode(&checklist)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errors.New("checklist not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &checklist, nil
+}
+
+func (s Service) Create(workspaceId string, input ChecklistInput) (*Checklist, error) {
+	created := time.Now().Unix()
+	checklist := Checklist{
+		WorkspaceID: workspaceId,
+		Name:        "New checklist",
+		Items:       []ChecklistItem{},
+		Created:     created,
+		Updated:     created,
+	}
+	applyInput(&checklist, input)
+
+	err := ValidateChecklist(checklist)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.Collection.InsertOne(context.Background(), checklist)
+	if err != nil {
+		return nil, err
+	}
+	checklist.ID = result.InsertedID.(primitive.ObjectID)
+
+	return &checklist, nil
+}
+
+func (s Service) Update(workspaceId string, id string, input ChecklistInput) (*Checklist, error) {
+	checklist, err := s.Get(workspaceId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	applyInput(checklist, input)
+	checklist.Updated = time.Now().Unix()
+	err = ValidateChecklist(*checklist)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.Collection.ReplaceOne(context.Background(), bson.M{"_id": checklist.ID, "workspaceId": workspaceId}, checklist)
+	if err != nil {
+		return nil, err
+	}
+
+	return checklist, nil
+}
+
+func (s Service) Delete(workspaceId string, id string) error {
+	checklist, err := s.Get(workspaceId, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.UserCollection.DeleteMany(context.Background(), bson.M{"workspaceId": workspaceId, "checklistId": id})
+	if err != nil {
+		return err
+	}
+	_, err = s.Collection.DeleteOne(context.Background(), bson.M{"_id": checklist.ID, "workspaceId": workspaceId})
+
+	return err
+}
+
+// GetProgress returns the progress of the user in every published checklist. Items completed by a finished
+// flow or a tracked event since the last call are stored and tracked as completed.
+func (s Service) GetProgress(workspaceId string, externalUserId string) ([]ChecklistProgress, error) {
+	cursor, err := s.Collection.Find(context.Background(), bson.M{"workspaceId": workspaceId, "published": true})
+	if err != nil {
+		return nil, err
+	}
+	checklists := make([]Checklist, 0)
+	if err = cursor.All(context.Background(), &checklists); err != nil {
+		return nil, err
+	}
+
+	facts, err := s.getUserFacts(workspaceId, externalUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	progresses := make([]ChecklistProgress, 0, len(checklists))
+	for _, checklist := range checklists {
+		userChecklist, err := s.getUserChecklist(workspaceId, checklist.ID.Hex(), externalUserId)
+		if err != nil {
+			return nil, err
+		}
+
+		progress, newCompletions := evaluateProgress(checklist, userChecklist.CompletedItems, facts, now)
+		for itemId, completedAt := range newCompletions {
+			err = s.completeItem(workspaceId, externalUserId, checklist, itemId, completedAt)
+			if err != nil {
+				return nil, err
+			}
+		}
+		progresses = append(progresses, progress)
+	}
+
+	return progresses, nil
+}
+
+// CompleteItem marks an item as completed by the api, items that are already completed keep their timestamp
+func (s Service) CompleteItem(workspaceId string, externalUserId string, checklistId string, itemId string) error {
+	checklist, err := s.Get(workspaceId, checklistId)
+	if err != nil {
+		return err
+	}
+	if !checklist.Published {
+		return errors.New("checklist is not published")
+	}
+	if !checklist.hasItem(itemId) {
+		return errors.New("checklist item not found")
+	}
+
+	return s.completeItem(workspaceId, externalUserId, *checklist, itemId, time.Now().Unix())
+}
+
+// completeItem stores the completion unless the item is already completed and only tracks it when it was stored,
+// so concurrent calls for the same item track it once
+func (s Service) completeItem(workspaceId string, externalUserId string, checklist Checklist, itemId string, completedAt int64) error {
+	checklistId := checklist.ID.Hex()
+	filter := bson.M{
+		"workspaceId":              workspaceId,
+		"checklistId":              checklistId,
+		"externalUserId":           externalUserId,
+		"completedItems." + itemId: bson.M{"$exists": false},
+	}
+	update := bson.M{"$set": bson.M{"completedItems." + itemId: completedAt}}
+
+	// A completed item does not match the filter, the upsert then collides with the existing document. The
+	// collision also happens when another item created the document at the same time, so it is tried once more.
+	var res *mongo.UpdateResult
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		res, err = s.UserCollection.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
+		if !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 && res.UpsertedCount == 0 {
+		return nil
+	}
+
+	return s.Tracker.TrackEvents(workspaceId, externalUserId, []tracker.EventTrack{{
+		EntityID:  checklistId,
+		EventType: tracker.EventTypeChecklistItemCompleted,
+		Timestamp: completedAt,
+		Metadata: map[string]string{
+			"itemId":     itemId,
+			"completion": string(checklist.itemCompletion(itemId)),
+		},
+	}})
+}
+
+func (s Service) getUserChecklist(workspaceId string, checklistId string, externalUserId string) (*UserChecklist, error) {
+	var userChecklist UserChecklist
+	err := s.UserCollection.FindOne(context.Background(), bson.M{"workspaceId": workspaceId, "checklistId": checklistId, "externalUserId": externalUserId}).Decode(&userChecklist)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &UserChecklist{CompletedItems: map[string]int64{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &userChecklist, nil
+}
+
+func (s Service) getUserFacts(workspaceId string, externalUserId string) (userFacts, error) {
+	facts := userFacts{FinishedFlows: map[string]int64{}, EventKeys: map[string]int64{}}
+
+	user, err := s.EnrolledUserService.Get(workspaceId, externalUserId)
+	if err != nil {
+		return facts, err
+	}
+	if user == nil {
+		return facts, errors.New("user not found")
+	}
+
+	state, err := s.EnrolledUserService.GetState(workspaceId, user.ID.Hex())
+	if err != nil {
+		return facts, err
+	}
+	for _, flowId := range state.FlowsData.CompletedFlowsIds {
+		facts.FinishedFlows[flowId] = state.FlowsData.Progress[flowId].FinishedAt
+	}
+
+	eventKeys, err := s.EventsService.GetUserEventKeys(workspaceId, externalUserId)
+	if err != nil {
+		return facts, err
+	}
+	for _, eventKey := range eventKeys {
+		facts.EventKeys[eventKey.Key] = eventKey.FirstCreatedAt.Unix()
+	}
+
+	return facts, nil
+}
+
+func ValidateChecklist(checklist Checklist) error {
+	if checklist.Name == "" {
+		return errors.New("checklist name is required")
+	}
+
+	itemIds := make(map[string]bool, len(checklist.Items))
+	for i, item := range checklist.Items {
+		position := "item " + strconv.Itoa(i+1)
+		if item.Title == "" {
+			return errors.New(position + " requires a title")
+		}
+		// Item ids are keys of the stored completions
+		if strings.ContainsAny(item.ID, ".$") {
+			return errors.New(position + " id must not contain '.' or '$'")
+		}
+		if itemIds[item.ID] {
+			return errors.New(position + " has a duplicate id: " + item.ID)
+		}
+		itemIds[item.ID] = true
+
+		switch item.Completion.Type {
+		case CompletionTypeFlowFinished:
+			if item.Completion.FlowID == "" {
+				return errors.New(position + " requires a flow")
+			}
+		case CompletionTypeEvent:
+			if item.Completion.EventKey == "" {
+				return errors.New(position + " requires an event key")
+			}
+		case CompletionTypeManual:
+		default:
+			return errors.New(position + " has an unknown completion type: " + string(item.Completion.Type))
+		}
+	}
+
+	return nil
+}
+
+func applyInput(checklist *Checklist, input ChecklistInput) {
+	if input.Name != nil {
+		checklist.Name = *input.Name
+	}
+	if input.Description != nil {
+		checklist.Description = *input.Description
+	}
+	if input.Items != nil {
+		checklist.Items = input.Items
+		for i := range checklist.Items {
+			if checklist.Items[i].ID == "" {
+				checklist.Items[i].ID = uuid.New().String()
+			}
+		}
+	}
+	if input.Published != nil {
+		checklist.Published = *input.Published
+	}
+}
+
+func (c Checklist) hasItem(itemId string) bool {
+	return c.itemCompletion(itemId) != ""
+}
+
+func (c Checklist) itemCompletion(itemId string) CompletionType {
+	for _, item := range c.Items {
+		if item.ID == itemId {
+			return item.Completion.Type
+		}
+	}
+
+	return ""
+}
@@ -1,11 +1,18 @@
 package flows
 
 import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"milestone_core/gamification/events"
 	"milestone_core/tours/tracker"
 )
 
 type Analytics struct {
-	Tracker tracker.Tracker
+	Tracker                 tracker.Tracker
+	UserStateCollection     *mongo.Collection
+	EnrolledUsersCollection *mongo.Collection
+	EventsService           events.Service
 }
 
 func (s Analytics) GetFlowAnalytics(flow *Flow) (FlowAnalytics, error) {
@@ -41,9 +48,80 @@ func (s Analytics) GetFlowAnalytics(flow *Flow) (FlowAnalytics, error) {
 	analytics.NoOfFinished = s.getNoOfFinished(events)
 	analytics.NoOfSkipped = s.getNoOfSkipped(events)
 
+	if HasRollout(flow.Opts) {
+		rollout, err := s.getRolloutAnalytics(flow)
+		if err != nil {
+			return analytics, err
+		}
+		analytics.Rollout = rollout
+	}
+
+	if flow.Opts.Goal != nil {
+		goal, err := s.getGoalAnalytics(flow, events)
+		if err != nil {
+			return analytics, err
+		}
+		analytics.Goal = goal
+	}
+
 	return analytics, nil
 }
 
+// getRolloutAnalytics compares the users assigned to the treatment with the holdout. Holdout users never see
+// the flow, their numbers are the baseline for whatever the flow is meant to drive.
+func (s Analytics) getRolloutAnalytics(flow *Flow) (*RolloutAnalytics, error) {
+	treatment, err := s.getRolloutGroupAnalytics(flow, RolloutGroupTreatment)
+	if err != nil {
+		return nil, err
+	}
+
+	holdout, err := s.getRolloutGroupAnalytics(flow, RolloutGroupHoldout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RolloutAnalytics{
+		Treatment: treatment,
+		Holdout:   holdout,
+	}, nil
+}
+
+func (s Analytics) getRolloutGroupAnalytics(flow *Flow, group RolloutGroup) (RolloutGroupAnalytics, error) {
+	flowId := flow.ID.Hex()
+	groupFilter := bson.M{
+		"workspaceId": flow.WorkspaceID,
+		"flowsData.rolloutAssignments." + flowId + ".group": group,
+	}
+
+	groupAnalytics := RolloutGroupAnalytics{}
+	users, err := s.UserStateCollection.CountDocuments(context.Background(), groupFilter)
+	if err != nil {
+		return groupAnalytics, err
+	}
+	groupAnalytics.Users = int(users)
+
+	groupFilter["flowsData.completedFlowsIds"] = flowId
+	finished, err := s.UserStateCollection.CountDocuments(context.Background(), groupFilter)
+	if err != nil {
+		return groupAnalytics, err
+	}
+	groupAnalytics.Finished = int(finished)
+
+	delete(groupFilter, "flowsData.completedFlowsIds")
+	groupFilter["flowsData.skippedFlowsIds"] = flowId
+	skipped, err := s.UserStateCollection.CountDocuments(context.Background(), groupFilter)
+	if err != nil {
+		return groupAnalytics, err
+	}
+	groupAnalytics.Skipped = int(skipped)
+
+	if groupAnalytics.Users > 0 {
+		groupAnalytics.FinishRate = float64(groupAnalytics.Finished) / float64(groupAnalytics.Users)
+	}
+
+	return groupAnalytics, nil
+}
+
 func (s Analytics) getUniqueViews(events []tracker.EventTrack) int {
 	seenUserIds := make(map[string]bool)
 
@@ -2,13 +2,17 @@ package flows
 
 import (
 	"context"
+	"errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"milestone_core/shared/versioning"
+	"milestone_core/tours/audit"
 )
 
 type BranchingService struct {
 	Collection *mongo.Collection
+	AuditLog   audit.Log
 }
 
 func (s BranchingService) Get(workspace string, id string) (*Branching, error) {
@@ -53,7 +57,12 @@ func (s BranchingService) List(workspace string) ([]*Branching, error) {
 	return branchings, nil
 }
 
-func (s BranchingService) Create(workspace string, branching Branching) (string, error) {
+func (s BranchingService) Create(workspace string, branching Branching, actor audit.Actor) (string, error) {
+	err := ValidateBranching(branching)
+	if err != nil {
+		return "", err
+	}
+
 	branching.WorkspaceID = workspace
 
 	res, err := s.Collection.InsertOne(context.Background(), branching)
@@ -61,20 +70,53 @@ func (s BranchingService) Create(workspace string, branching Branching) (string,
 		return "", err
 	}
 
-	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+	branching.ID = res.InsertedID.(primitive.ObjectID)
+	err = s.AuditLog.Record(workspace, actor, audit.EntityTypeBranching, branching.ID.Hex(), audit.ActionCreated, nil, branching)
+	if err != nil {
+		return "", err
+	}
+
+	return branching.ID.Hex(), nil
 }
 
-func (s BranchingService) Update(workspace string, id string, branching Branching) error {
+// Update replaces the branching when it is still at the version the editor read, it returns the new version
+func (s BranchingService) Update(workspace string, id string, branching Branching, version int64, actor audit.Actor) (int64, error) {
 	branchID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	before, err := s.Get(workspace, id)
+	if err != nil {
+		return 0, err
+	}
+	if before == nil {
+		return 0, errors.New("branching not found")
+	}
+	err = versioning.Check(version, before.Version, before)
+	if err != nil {
+		return 0, err
+	}
+
+	err = ValidateBranching(branching)
+	if err != nil {
+		return 0, err
 	}
 
 	branching.WorkspaceID = workspace
-	_, err = s.Collection.ReplaceOne(context.Background(), bson.M{"_id": branchID}, branching)
+	branching.ID = branchID
+	branching.Version = before.Version + 1
+	res, err := s.Collection.ReplaceOne(context.Background(), bson.M{"_id": branchID, "version": versioning.Filter(before.Version)}, branching)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if res.MatchedCount == 0 {
+		current, err := s.Get(workspace, id)
+		if err != nil {
+			return 0, err
+		}
+		return 0, &versioning.ConflictError{Version: current.Version, Current: current}
 	}
 
-	return nil
+	return branching.Version, s.AuditLog.Record(workspace, actor, audit.EntityTypeBranching, id, audit.ActionUpdated, before, branching)
 }
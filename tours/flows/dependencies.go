@@ -0,0 +1,241 @@
+package flows
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"slices"
+	"strings"
+)
+
+type DependencyStatus string
+
+const (
+	DependencyStatusLive     DependencyStatus = "live"
+	DependencyStatusDraft    DependencyStatus = "draft"
+	DependencyStatusArchived DependencyStatus = "archived"
+	DependencyStatusMissing  DependencyStatus = "missing"
+)
+
+// DependencyGraph holds the flows of the workspace and the flows they depend on. An edge goes from a flow to a
+// flow in its DependsOn list.
+type DependencyGraph struct {
+	Nodes []DependencyNode `json:"nodes"`
+	Edges []Relation       `json:"edges"`
+}
+
+type DependencyNode struct {
+	ID     string           `json:"id"`
+	Name   string           `json:"name"`
+	Status DependencyStatus `json:"status"`
+	// Unreachable is set for live flows that can never be enrolled because none of their dependencies can be
+	// finished, Reason explains why
+	Unreachable bool   `json:"unreachable,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// GetDependencyGraph returns the dependencies between the flows of the workspace, including archived and
+// deleted flows that are still referenced
+func (s Service) GetDependencyGraph(workspace string) (*DependencyGraph, error) {
+	flows, err := s.List(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	publishedFlows, err := s.listPublishedFlows(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	archivedFlows, err := s.ListArchivedFlows(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildDependencyGraph(flows, publishedFlows, archivedFlows), nil
+}
+
+func (s Service) listPublishedFlows(workspace string) ([]*Flow, error) {
+	cursor, err := s.PublishedCollection.Find(context.Background(), bson.M{"workspaceId": workspace})
+	if err != nil {
+		return nil, err
+	}
+
+	flows := make([]*Flow, 0)
+	err = cursor.All(context.Background(), &flows)
+	if err != nil {
+		return nil, err
+	}
+
+	return flows, nil
+}
+
+// buildDependencyGraph takes the dependencies of live flows from their published revision, which is what the
+// enroller checks, and the dependencies of draft flows from the draft
+func buildDependencyGraph(flows []*Flow, publishedFlows []*Flow, archivedFlows []*Flow) *DependencyGraph {
+	graph := DependencyGraph{Nodes: make([]DependencyNode, 0, len(flows)), Edges: make([]Relation, 0)}
+	nodeIndex := make(map[string]int)
+	dependsOn := make(map[string][]string, len(flows))
+	for _, flow := range flows {
+		status := DependencyStatusDraft
+		dependencies := flow.Opts.DependsOn
+		if flow.Live {
+			status = DependencyStatusLive
+			publishedIndex := slices.IndexFunc(publishedFlows, func(published *Flow) bool { return published.ID == flow.ID })
+			if publishedIndex != -1 {
+				dependencies = publishedFlows[publishedIndex].Opts.DependsOn
+			}
+		}
+		nodeIndex[flow.ID.Hex()] = len(graph.Nodes)
+		graph.Nodes = append(graph.Nodes, DependencyNode{ID: flow.ID.Hex(), Name: flow.Name, Status: status})
+		dependsOn[flow.ID.Hex()] = dependencies
+	}
+
+	for _, flow := range flows {
+		for _, dependencyId := range dependsOn[flow.ID.Hex()] {
+			graph.Edges = append(graph.Edges, Relation{From: flow.ID.Hex(), To: dependencyId})
+			if _, exists := nodeIndex[dependencyId]; exists {
+				continue
+			}
+
+			node := DependencyNode{ID: dependencyId, Status: DependencyStatusMissing}
+			archivedIndex := slices.IndexFunc(archivedFlows, func(archived *Flow) bool { return archived.ID.Hex() == dependencyId })
+			if archivedIndex != -1 {
+				node.Name = archivedFlows[archivedIndex].Name
+				node.Status = DependencyStatusArchived
+			}
+			nodeIndex[dependencyId] = len(graph.Nodes)
+			graph.Nodes = append(graph.Nodes, node)
+		}
+	}
+
+	// A live flow can be enrolled when it has no dependencies or when any of its dependencies is a live flow
+	// that can be enrolled. Enrollable flows are found until nothing changes, the rest are unreachable.
+	enrollable := make(map[string]bool, len(flows))
+	for changed := true; changed; {
+		changed = false
+		for _, node := range graph.Nodes {
+			if node.Status != DependencyStatusLive || enrollable[node.ID] {
+				continue
+			}
+			dependencies := dependsOn[node.ID]
+			if len(dependencies) == 0 || slices.ContainsFunc(dependencies, func(id string) bool { return enrollable[id] }) {
+				enrollable[node.ID] = true
+				changed = true
+			}
+		}
+	}
+
+	for i, node := range graph.Nodes {
+		if node.Status != DependencyStatusLive || enrollable[node.ID] {
+			continue
+		}
+		graph.Nodes[i].Unreachable = true
+		graph.Nodes[i].Reason = unreachableReason(dependsOn[node.ID], graph.Nodes, nodeIndex)
+	}
+
+	return &graph
+}
+
+// unreachableReason names the kinds of dependencies that block the flow
+func unreachableReason(dependencies []string, nodes []DependencyNode, nodeIndex map[string]int) string {
+	reasons := make([]string, 0)
+	for _, status := range []DependencyStatus{DependencyStatusArchived, DependencyStatusMissing, DependencyStatusDraft} {
+		if slices.ContainsFunc(dependencies, func(id string) bool { return nodes[nodeIndex[id]].Status == status }) {
+			reasons = append(reasons, "depends on "+string(status)+" flows")
+		}
+	}
+	if slices.ContainsFunc(dependencies, func(id string) bool { return nodes[nodeIndex[id]].Status == DependencyStatusLive }) {
+		reasons = append(reasons, "depends on live flows that can never be enrolled")
+	}
+
+	return strings.Join(reasons, ", ")
+}
+
+// findDependencyCycle returns the flows of a cycle that goes through the flow, or nil when there is none
+func findDependencyCycle(dependsOn map[string][]string, flowId string) []string {
+	visited := make(map[string]bool)
+	var visit func(id string, path []string) []string
+	visit = func(id string, path []string) []string {
+		for _, dependencyId := range dependsOn[id] {
+			if dependencyId == flowId {
+				return append(path, dependencyId)
+			}
+			if visited[dependencyId] {
+				continue
+			}
+			visited[dependencyId] = true
+			if cycle := visit(dependencyId, append(path, dependencyId)); cycle != nil {
+				return cycle
+			}
+		}
+
+		return nil
+	}
+
+	return visit(flowId, []string{flowId})
+}
+
+// checkDependencyCycle rejects dependencies of the flow that lead back to the flow itself
+func (s Service) checkDependencyCycle(workspace string, flow *Flow) error {
+	flows, err := s.List(workspace)
+	if err != nil {
+		return err
+	}
+
+	return validationErrorOrNil(dependencyCycleProblems(dependencyMap(flows, flow), flow.ID.Hex()))
+}
+
+// checkPublishedDependencyCycle rejects publishing a flow whose dependencies close a cycle with the published
+// dependencies of the live flows, which are the ones the enroller follows. Drafts can differ from them, so a
+// cycle can appear here without ever being in the drafts.
+func (s Service) checkPublishedDependencyCycle(flow *Flow) error {
+	publishedFlows, err := s.listPublishedFlows(flow.WorkspaceID)
+	if err != nil {
+		return err
+	}
+
+	return validationErrorOrNil(dependencyCycleProblems(dependencyMap(publishedFlows, flow), flow.ID.Hex()))
+}
+
+// dependencyMap holds the dependencies of the flows, the flow replaces its own entry
+func dependencyMap(flows []*Flow, flow *Flow) map[string][]string {
+	dependsOn := make(map[string][]string, len(flows)+1)
+	for _, f := range flows {
+		dependsOn[f.ID.Hex()] = f.Opts.DependsOn
+	}
+	dependsOn[flow.ID.Hex()] = flow.Opts.DependsOn
+
+	return dependsOn
+}
+
+func dependencyCycleProblems(dependsOn map[string][]string, flowId string) []ValidationProblem {
+	cycle := findDependencyCycle(dependsOn, flowId)
+	if cycle == nil {
+		return nil
+	}
+
+	return []ValidationProblem{{
+		Code:    ValidationCodeDependencyCycle,
+		Message: "dependencies form a cycle: " + strings.Join(cycle, " -> "),
+	}}
+}
+
+// dependentFlowIds returns the flows that depend on the flow directly or through other flows
+func dependentFlowIds(flows []*Flow, flowId string) map[string]bool {
+	dependents := map[string]bool{flowId: true}
+	for changed := true; changed; {
+		changed = false
+		for _, flow := range flows {
+			id := flow.ID.Hex()
+			if dependents[id] {
+				continue
+			}
+			if slices.ContainsFunc(flow.Opts.DependsOn, func(dependencyId string) bool { return dependents[dependencyId] }) {
+				dependents[id] = true
+				changed = true
+			}
+		}
+	}
+
+	return dependents
+}
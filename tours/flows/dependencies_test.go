@@ -0,0 +1,86 @@
+package flows
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func TestDependencies(t *testing.T) {
+	t.Run("longer cycles are found", func(t *testing.T) {
+		dependsOn := map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}, "d": {"a"}}
+		cycle := findDependencyCycle(dependsOn, "a")
+		if len(cycle) != 4 || cycle[0] != "a" || cycle[3] != "a" {
+			t.Fatalf("Expected cycle a -> b -> c -> a, got %v", cycle)
+		}
+		if findDependencyCycle(dependsOn, "d") != nil {
+			t.Fatalf("Expected no cycle through d")
+		}
+	})
+
+	t.Run("flows that depend on the flow are not possible dependencies", func(t *testing.T) {
+		a, b, c := &Flow{ID: primitive.NewObjectID()}, &Flow{ID: primitive.NewObjectID()}, &Flow{ID: primitive.NewObjectID()}
+		b.Opts.DependsOn = []string{a.ID.Hex()}
+		c.Opts.DependsOn = []string{b.ID.Hex()}
+		dependents := dependentFlowIds([]*Flow{a, b, c}, a.ID.Hex())
+		if !dependents[c.ID.Hex()] || !dependents[a.ID.Hex()] || len(dependents) != 3 {
+			t.Fatalf("Expected a, b and c to depend on a, got %v", dependents)
+		}
+	})
+
+	t.Run("flows behind archived or unpublished flows are unreachable", func(t *testing.T) {
+		archived := &Flow{ID: primitive.NewObjectID(), Name: "Old"}
+		draft := &Flow{ID: primitive.NewObjectID(), Name: "Draft"}
+		root := &Flow{ID: primitive.NewObjectID(), Name: "Root", Live: true}
+		blocked := &Flow{ID: primitive.NewObjectID(), Live: true, Opts: Opts{DependsOn: []string{archived.ID.Hex(), draft.ID.Hex()}}}
+		chained := &Flow{ID: primitive.NewObjectID(), Live: true, Opts: Opts{DependsOn: []string{blocked.ID.Hex()}}}
+		open := &Flow{ID: primitive.NewObjectID(), Live: true, Opts: Opts{DependsOn: []string{blocked.ID.Hex(), root.ID.Hex()}}}
+
+		graph := buildDependencyGraph([]*Flow{draft, root, blocked, chained, open}, nil, []*Flow{archived})
+		unreachable := make(map[string]bool)
+		for _, node := range graph.Nodes {
+			if node.ID == archived.ID.Hex() && node.Status != DependencyStatusArchived {
+				t.Fatalf("Expected the archived flow to be a node, got %+v", node)
+			}
+			unreachable[node.ID] = node.Unreachable
+		}
+		if !unreachable[blocked.ID.Hex()] || !unreachable[chained.ID.Hex()] {
+			t.Fatalf("Expected blocked and chained flows to be unreachable, got %+v", graph.Nodes)
+		}
+		if unreachable[root.ID.Hex()] || unreachable[open.ID.Hex()] {
+			t.Fatalf("Expected flows with an enrollable dependency to be reachable, got %+v", graph.Nodes)
+		}
+		if len(graph.Edges) != 5 {
+			t.Fatalf("Expected 5 edges, got %v", graph.Edges)
+		}
+	})
+
+	t.Run("live flows use the dependencies of their published revision", func(t *testing.T) {
+		draft := &Flow{ID: primitive.NewObjectID(), Name: "Draft"}
+		root := &Flow{ID: primitive.NewObjectID(), Name: "Root", Live: true}
+		edited := &Flow{ID: primitive.NewObjectID(), Live: true, Opts: Opts{DependsOn: []string{draft.ID.Hex()}}}
+		published := &Flow{ID: edited.ID, Live: true, Opts: Opts{DependsOn: []string{root.ID.Hex()}}}
+
+		graph := buildDependencyGraph([]*Flow{draft, root, edited}, []*Flow{published}, nil)
+		for _, node := range graph.Nodes {
+			if node.Unreachable {
+				t.Fatalf("Expected the published dependencies to make the flow reachable, got %+v", node)
+			}
+		}
+		if len(graph.Edges) != 1 || graph.Edges[0].To != root.ID.Hex() {
+			t.Fatalf("Expected an edge to the published dependency, got %v", graph.Edges)
+		}
+	})
+
+	t.Run("cycles are found through the published dependencies", func(t *testing.T) {
+		a, b := &Flow{ID: primitive.NewObjectID()}, &Flow{ID: primitive.NewObjectID()}
+		publishedA := &Flow{ID: a.ID, Opts: Opts{DependsOn: []string{b.ID.Hex()}}}
+		b.Opts.DependsOn = []string{a.ID.Hex()}
+
+		if dependencyCycleProblems(dependencyMap([]*Flow{a, b}, b), b.ID.Hex()) != nil {
+			t.Fatalf("Expected no cycle between the drafts")
+		}
+		if dependencyCycleProblems(dependencyMap([]*Flow{publishedA}, b), b.ID.Hex()) == nil {
+			t.Fatalf("Expected publishing b to close a cycle with the published a")
+		}
+	})
+}
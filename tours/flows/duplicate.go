@@ -0,0 +1,142 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"milestone_core/tours/audit"
+	"strings"
+)
+
+type DuplicateInput struct {
+	// WorkspaceID is the workspace the copy is created in, the workspace of the flow when empty
+	WorkspaceID string  `json:"workspaceId,omitempty"`
+	Name        *string `json:"name,omitempty"`
+}
+
+// Duplicate copies the draft of the flow with fresh step ids. The copy is not live and goes to the end of the
+// priority list of the target workspace. Dependencies and goals on other flows are only kept within the same
+// workspace.
+func (s Service) Duplicate(workspace string, id string, input DuplicateInput, actor audit.Actor) (string, error) {
+	flow, err := s.Get(workspace, id)
+	if err != nil {
+		return "", err
+	}
+	if flow == nil {
+		return "", errors.New("flow not found")
+	}
+
+	targetWorkspace := input.WorkspaceID
+	if targetWorkspace == "" {
+		targetWorkspace = workspace
+	}
+
+	duplicate := copyFlow(flow)
+	duplicate.Name = flow.Name + " (copy)"
+	if input.Name != nil {
+		duplicate.Name = *input.Name
+	}
+	if targetWorkspace != workspace {
+		duplicate.Opts.DependsOn = nil
+		// A goal on finishing another flow can not point into a different workspace
+		if duplicate.Opts.Goal != nil && duplicate.Opts.Goal.FlowID != "" {
+			duplicate.Opts.Goal = nil
+		}
+	}
+
+	return s.insertFlow(targetWorkspace, duplicate, actor)
+}
+
+// insertFlow stores a new draft, new flows go to the end of the priority list of the workspace. Like every draft
+// it is stored with its problems, they are reported by Validate and block publishing.
+func (s Service) insertFlow(workspace string, flow *Flow, actor audit.Actor) (string, error) {
+	flowsCount, err := s.Collection.CountDocuments(context.Background(), bson.M{"workspaceId": workspace})
+	if err != nil {
+		return "", err
+	}
+
+	flow.WorkspaceID = workspace
+	flow.Priority = int(flowsCount)
+	newId, err := s.Collection.InsertOne(context.Background(), flow)
+	if err != nil {
+		return "", err
+	}
+
+	flow.ID = newId.InsertedID.(primitive.ObjectID)
+	err = s.AuditLog.Record(workspace, actor, audit.EntityTypeFlow, flow.ID.Hex(), audit.ActionCreated, nil, flow)
+	if err != nil {
+		return "", err
+	}
+
+	return flow.ID.Hex(), nil
+}
+
+// copyFlow deep copies the content of the flow with new step ids. References to steps from parents, branching
+// options and translation keys are moved to the new ids, the publishing state is not copied.
+func copyFlow(flow *Flow) *Flow {
+	stepIds := make(map[string]string, len(flow.Steps))
+	for _, step := range flow.Steps {
+		stepIds[step.StepID] = uuid.New().String()
+	}
+	newStepId := func(stepId string) string {
+		if newId, ok := stepIds[stepId]; ok {
+			return newId
+		}
+		return stepId
+	}
+
+	copied := mapFlowContent(flow, func(key string, content string) string { return content })
+	copied.ID = primitive.NilObjectID
+	copied.Live = false
+	copied.PublishedRevision = 0
+	copied.Version = 0
+	copied.Segments = append([]Segment(nil), flow.Segments...)
+	copied.Opts.DependsOn = append([]string(nil), flow.Opts.DependsOn...)
+	if flow.Opts.Goal != nil {
+		goal := *flow.Opts.Goal
+		copied.Opts.Goal = &goal
+	}
+	if flow.Opts.Schedule != nil {
+		schedule := *flow.Opts.Schedule
+		schedule.ActivatedAt = 0
+		schedule.DeactivatedAt = 0
+		copied.Opts.Schedule = &schedule
+	}
+
+	for i := range copied.Steps {
+		step := &copied.Steps[i]
+		step.StepID = newStepId(step.StepID)
+		if step.ParentNodeId != "" {
+			step.ParentNodeId = newStepId(step.ParentNodeId)
+		}
+		for j := range step.Data.BranchingOptions {
+			if step.Data.BranchingOptions[j].NextStepID != "" {
+				step.Data.BranchingOptions[j].NextStepID = newStepId(step.Data.BranchingOptions[j].NextStepID)
+			}
+		}
+	}
+
+	if flow.Translations != nil {
+		copied.Translations = make(map[string]map[string]string, len(flow.Translations))
+		for locale, values := range flow.Translations {
+			copiedValues := make(map[string]string, len(values))
+			for key, value := range values {
+				copiedValues[moveTranslationKey(key, newStepId)] = value
+			}
+			copied.Translations[locale] = copiedValues
+		}
+	}
+
+	return copied
+}
+
+func moveTranslationKey(key string, newStepId func(string) string) string {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) < 3 || parts[0] != "steps" {
+		return key
+	}
+
+	return translationKey(newStepId(parts[1]), parts[2])
+}
@@ -0,0 +1,376 @@
+package flows
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+type EligibilityCheck string
+
+const (
+	EligibilityCheckLive                EligibilityCheck = "live"
+	EligibilityCheckFinished            EligibilityCheck = "finished"
+	EligibilityCheckSkipped             EligibilityCheck = "skipped"
+	EligibilityCheckDependsOn           EligibilityCheck = "depends_on"
+	EligibilityCheckTargetingRules      EligibilityCheck = "targeting_rules"
+	EligibilityCheckTargetingExpression EligibilityCheck = "targeting_expression"
+	EligibilityCheckTrigger             EligibilityCheck = "trigger"
+	EligibilityCheckSchedule            EligibilityCheck = "schedule"
+	EligibilityCheckFrequency           EligibilityCheck = "frequency"
+	EligibilityCheckExperiment          EligibilityCheck = "experiment"
+	EligibilityCheckRollout             EligibilityCheck = "rollout"
+)
+
+type CheckFailure struct {
+	Check  EligibilityCheck `json:"check"`
+	Reason string           `json:"reason"`
+}
+
+type FlowEligibility struct {
+	FlowID   string         `json:"flowId"`
+	Name     string         `json:"name"`
+	Priority int            `json:"priority"`
+	Slot     FlowSlot       `json:"slot"`
+	Live     bool           `json:"live"`
+	Eligible bool           `json:"eligible"`
+	Current  bool           `json:"current"`
+	Selected bool           `json:"selected"`
+	Failures []CheckFailure `json:"failures,omitempty"`
+}
+
+// EligibilityReport explains the enrollment decision for a user. SelectedFlowIDs are the flows the enroller
+// returns next per slot, either the current enrollment or the first eligible flow by priority.
+type EligibilityReport struct {
+	CurrentFlowIDs  map[FlowSlot]string `json:"currentFlowIds,omitempty"`
+	SelectedFlowIDs map[FlowSlot]string `json:"selectedFlowIds"`
+	Flows           []FlowEligibility   `json:"flows"`
+}
+
+type eligibilityCheckFunc func(e *enrollmentEvaluation, flow *Flow) (*CheckFailure, error)
+
+// eligibilityChecks run in order. The rollout check assigns the user to a group, it comes last so the enroller,
+// which stops at the first failure, only assigns users that passed every other check. The experiment variant is
+// only stored once the flow passed every check, see recordExperimentAssignment.
+var eligibilityChecks = []eligibilityCheckFunc{
+	(*enrollmentEvaluation).checkSubmitted,
+	(*enrollmentEvaluation).checkDependsOn,
+	(*enrollmentEvaluation).checkTargetingRules,
+	(*enrollmentEvaluation).checkTargetingExpression,
+	(*enrollmentEvaluation).checkTrigger,
+	(*enrollmentEvaluation).checkSchedule,
+	(*enrollmentEvaluation).checkFrequency,
+	(*enrollmentEvaluation).checkExperiment,
+	(*enrollmentEvaluation).checkRollout,
+}
+
+// enrollmentEvaluation holds what the checks share while evaluating the flows of a workspace for one user
+type enrollmentEvaluation struct {
+	enroller            *Enroller
+	workspaceId         string
+	opts                EnrollmentOpts
+	now                 time.Time
+	dryRun              bool
+	experimentsByFlowId map[string]*Branching
+	location            *time.Location
+	workspaceFrequency  *FrequencySettings
+}
+
+func (s *Enroller) newEvaluation(workspaceId string, opts EnrollmentOpts, dryRun bool) (*enrollmentEvaluation, error) {
+	experimentsByFlowId, err := s.getExperimentsByFlowId(workspaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &enrollmentEvaluation{
+		enroller:            s,
+		workspaceId:         workspaceId,
+		opts:                opts,
+		now:                 time.Now(),
+		dryRun:              dryRun,
+		experimentsByFlowId: experimentsByFlowId,
+	}, nil
+}
+
+// evaluate returns the failed checks of the flow, stopping at the first one when asked to
+func (e *enrollmentEvaluation) evaluate(flow *Flow, stopAtFirstFailure bool) ([]CheckFailure, error) {
+	failures := make([]CheckFailure, 0)
+	for _, check := range eligibilityChecks {
+		failure, err := check(e, flow)
+		if err != nil {
+			return nil, err
+		}
+		if failure == nil {
+			continue
+		}
+		failures = append(failures, *failure)
+		if stopAtFirstFailure {
+			break
+		}
+	}
+	if len(failures) == 0 {
+		e.recordExperimentAssignment(flow)
+	}
+
+	return failures, nil
+}
+
+func (e *enrollmentEvaluation) checkSubmitted(flow *Flow) (*CheckFailure, error) {
+	flowId := flow.ID.Hex()
+	if slices.Contains(e.opts.FinishedIds, flowId) {
+		return &CheckFailure{Check: EligibilityCheckFinished, Reason: "user already finished the flow"}, nil
+	}
+	if slices.Contains(e.opts.SkippedIds, flowId) {
+		return &CheckFailure{Check: EligibilityCheckSkipped, Reason: "user skipped the flow"}, nil
+	}
+
+	return nil, nil
+}
+
+// checkDependsOn passes when the user finished any of the flows this flow depends on
+func (e *enrollmentEvaluation) checkDependsOn(flow *Flow) (*CheckFailure, error) {
+	if len(flow.Opts.DependsOn) == 0 {
+		return nil, nil
+	}
+	for _, dependsOnId := range flow.Opts.DependsOn {
+		if slices.Contains(e.opts.FinishedIds, dependsOnId) {
+			return nil, nil
+		}
+	}
+
+	return &CheckFailure{Check: EligibilityCheckDependsOn, Reason: "user did not finish any of the flows it depends on"}, nil
+}
+
+// checkTargetingRules evaluates the legacy rules. Flows listing user ids are shown to these users only,
+// otherwise every rule condition present on the flow needs at least one matching rule.
+func (e *enrollmentEvaluation) checkTargetingRules(flow *Flow) (*CheckFailure, error) {
+	rulesByCondition := make(map[TargetingRuleCondition][]TargetingRule)
+	for _, rule := range flow.Opts.Targeting.Rules {
+		rulesByCondition[rule.Condition] = append(rulesByCondition[rule.Condition], rule)
+	}
+
+	if userIdRules, ok := rulesByCondition[TargetingRuleUserIds]; ok {
+		for _, rule := range userIdRules {
+			if e.opts.UserId != "" && ruleValueMatches(rule.Value, e.opts.UserId) {
+				return nil, nil
+			}
+		}
+		return e.targetingRuleFailure(TargetingRuleUserIds, "user is not listed in the user ids of the flow"), nil
+	}
+
+	elapsedDays := 0
+	if e.opts.SignUpTimestamp != 0 {
+		elapsedDays = calculateDaysBetweenTimestamps(e.opts.SignUpTimestamp, e.now.Unix())
+	}
+	ruleMatchers := []struct {
+		condition TargetingRuleCondition
+		matches   func(value any) bool
+		reason    string
+	}{
+		{
+			condition: TargetingRuleUserElapsedDaysFromRegistration,
+			matches: func(value any) bool {
+				days, ok := asComparableNumber(value)
+				return e.opts.SignUpTimestamp != 0 && ok && days <= float64(elapsedDays)
+			},
+			reason: fmt.Sprintf("user signed up %d days ago", elapsedDays),
+		},
+		{
+			condition: UserRegisteredAfterTimestamp,
+			matches: func(value any) bool {
+				timestamp, ok := asComparableNumber(value)
+				return e.opts.SignUpTimestamp != 0 && ok && timestamp <= float64(e.opts.SignUpTimestamp)
+			},
+			reason: "user registered before the timestamp of the rule",
+		},
+		{
+			condition: TargetingRuleUserSegment,
+			matches: func(value any) bool {
+				return e.opts.UserSegment != "" && ruleValueMatches(value, e.opts.UserSegment)
+			},
+			reason: "user segment " + e.opts.UserSegment + " does not match",
+		},
+	}
+
+	for _, matcher := range ruleMatchers {
+		rules, ok := rulesByCondition[matcher.condition]
+		if !ok {
+			continue
+		}
+		matched := false
+		for _, rule := range rules {
+			if matcher.matches(rule.Value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return e.targetingRuleFailure(matcher.condition, matcher.reason), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (e *enrollmentEvaluation) targetingRuleFailure(condition TargetingRuleCondition, reason string) *CheckFailure {
+	return &CheckFailure{
+		Check:  EligibilityCheckTargetingRules,
+		Reason: "rule " + string(condition) + " failed: " + reason,
+	}
+}
+
+// ruleValueMatches compares like the query on the stored rules did, a list value matches any of its items
+func ruleValueMatches(value any, expected any) bool {
+	if items, ok := asSlice(value); ok {
+		for _, item := range items {
+			if valuesEqual(item, expected) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return valuesEqual(value, expected)
+}
+
+func (e *enrollmentEvaluation) checkTargetingExpression(flow *Flow) (*CheckFailure, error) {
+	if flow.Opts.Targeting.Expression == nil || EvaluateTargeting(*flow.Opts.Targeting.Expression, e.opts.UserAttributes) {
+		return nil, nil
+	}
+
+	return &CheckFailure{Check: EligibilityCheckTargetingExpression, Reason: "user attributes do not match the targeting expression"}, nil
+}
+
+func (e *enrollmentEvaluation) checkTrigger(flow *Flow) (*CheckFailure, error) {
+	if e.opts.ClientContext == nil || EvaluateTrigger(flow.Opts.Trigger, *e.opts.ClientContext) {
+		return nil, nil
+	}
+
+	return &CheckFailure{Check: EligibilityCheckTrigger, Reason: "trigger rules do not match the current page"}, nil
+}
+
+func (e *enrollmentEvaluation) checkSchedule(flow *Flow) (*CheckFailure, error) {
+	schedule := flow.Opts.Schedule
+	if schedule == nil {
+		return nil, nil
+	}
+	if !schedule.InWindow(e.now) {
+		return &CheckFailure{Check: EligibilityCheckSchedule, Reason: "outside of the schedule window"}, nil
+	}
+	if !schedule.HasRecurrence() {
+		return nil, nil
+	}
+
+	if e.location == nil {
+		location, err := e.enroller.WorkspaceService.GetLocation(e.workspaceId)
+		if err != nil {
+			return nil, err
+		}
+		e.location = location
+	}
+	if !schedule.MatchesRecurrence(e.now, e.location) {
+		return &CheckFailure{Check: EligibilityCheckSchedule, Reason: "outside of the scheduled days or hours"}, nil
+	}
+
+	return nil, nil
+}
+
+// checkFrequency applies the workspace settings to every flow, a flow with its own settings has to pass both
+func (e *enrollmentEvaluation) checkFrequency(flow *Flow) (*CheckFailure, error) {
+	if e.workspaceFrequency == nil {
+		settings, err := e.enroller.SettingsService.Get(e.workspaceId)
+		if err != nil {
+			return nil, err
+		}
+		e.workspaceFrequency = &settings.Frequency
+	}
+
+	history := e.opts.History[flow.Type.Slot()]
+	if !e.workspaceFrequency.Allows(history, e.now.Unix()) {
+		return &CheckFailure{Check: EligibilityCheckFrequency, Reason: "frequency cap or skip cooldown of the workspace reached"}, nil
+	}
+	if flow.Opts.Frequency != nil && !flow.Opts.Frequency.Allows(history, e.now.Unix()) {
+		return &CheckFailure{Check: EligibilityCheckFrequency, Reason: "frequency cap or skip cooldown of the flow reached"}, nil
+	}
+
+	return nil, nil
+}
+
+func (e *enrollmentEvaluation) checkExperiment(flow *Flow) (*CheckFailure, error) {
+	experiment, ok := e.experimentsByFlowId[flow.ID.Hex()]
+	if !ok {
+		return nil, nil
+	}
+
+	assignment := e.assignVariant(experiment)
+	if assignment.FlowID == flow.ID.Hex() {
+		return nil, nil
+	}
+
+	return &CheckFailure{Check: EligibilityCheckExperiment, Reason: "user is assigned to variant " + assignment.VariantID + " of the experiment"}, nil
+}
+
+func (e *enrollmentEvaluation) checkRollout(flow *Flow) (*CheckFailure, error) {
+	group := e.assignRolloutGroup(flow)
+	if group == RolloutGroupTreatment {
+		return nil, nil
+	}
+
+	return &CheckFailure{Check: EligibilityCheckRollout, Reason: "user is in the " + string(group) + " group of the rollout"}, nil
+}
+
+// assignVariant keeps the stored variant of the user as long as it is still part of the experiment
+func (e *enrollmentEvaluation) assignVariant(experiment *Branching) ExperimentAssignment {
+	branchingId := experiment.ID.Hex()
+	if assignment, ok := e.opts.ExperimentAssignments[branchingId]; ok {
+		for _, variant := range experiment.Variants {
+			if variant.VariantID == assignment.VariantID {
+				return assignment
+			}
+		}
+	}
+
+	variant := AssignVariant(*experiment, e.opts.UserId)
+
+	return ExperimentAssignment{
+		VariantID:  variant.VariantID,
+		FlowID:     variant.FlowID,
+		AssignedAt: e.now.Unix(),
+	}
+}
+
+// recordExperimentAssignment stores the variant of an eligible flow, so users that never see their variant are
+// not counted in the experiment results
+func (e *enrollmentEvaluation) recordExperimentAssignment(flow *Flow) {
+	experiment, ok := e.experimentsByFlowId[flow.ID.Hex()]
+	if !ok || e.dryRun || e.opts.ExperimentAssignments == nil {
+		return
+	}
+
+	e.opts.ExperimentAssignments[experiment.ID.Hex()] = e.assignVariant(experiment)
+}
+
+// assignRolloutGroup keeps the stored group of the user, only excluded users are bucketed again so that
+// raising the rollout percentage lets them in.
+func (e *enrollmentEvaluation) assignRolloutGroup(flow *Flow) RolloutGroup {
+	if !HasRollout(flow.Opts) {
+		return RolloutGroupTreatment
+	}
+
+	flowId := flow.ID.Hex()
+	if assignment, ok := e.opts.RolloutAssignments[flowId]; ok && assignment.Group != RolloutGroupExcluded {
+		return assignment.Group
+	}
+
+	bucket := RolloutBucket(e.opts.UserId, flowId)
+	group := AssignRolloutGroup(flow.Opts, bucket)
+	if group != RolloutGroupExcluded && !e.dryRun && e.opts.RolloutAssignments != nil {
+		e.opts.RolloutAssignments[flowId] = RolloutAssignment{
+			Group:      group,
+			Bucket:     bucket,
+			AssignedAt: e.now.Unix(),
+		}
+	}
+
+	return group
+}
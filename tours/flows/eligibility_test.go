@@ -0,0 +1,156 @@
+package flows
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestEnrollmentEvaluation(t *testing.T) {
+	finishedFlowId := primitive.NewObjectID().Hex()
+	evaluation := &enrollmentEvaluation{
+		opts: EnrollmentOpts{
+			FinishedIds:     []string{finishedFlowId},
+			SignUpTimestamp: time.Now().Add(-10 * 24 * time.Hour).Unix(),
+			UserSegment:     "admins",
+			UserId:          "user_1",
+			UserAttributes:  map[string]any{"plan": "pro"},
+		},
+		now:                 time.Now(),
+		dryRun:              true,
+		experimentsByFlowId: map[string]*Branching{},
+		workspaceFrequency:  &FrequencySettings{},
+	}
+
+	newFlow := func(opts Opts) *Flow {
+		return &Flow{ID: primitive.NewObjectID(), Opts: opts}
+	}
+
+	evaluateFlow := func(flow *Flow) []CheckFailure {
+		failures, err := evaluation.evaluate(flow, false)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		return failures
+	}
+
+	t.Run("eligible flow", func(t *testing.T) {
+		flow := newFlow(Opts{
+			DependsOn: []string{finishedFlowId},
+			Targeting: Targeting{Rules: []TargetingRule{
+				{Condition: TargetingRuleUserSegment, Value: "admins"},
+				{Condition: TargetingRuleUserElapsedDaysFromRegistration, Value: int32(7)},
+			}},
+		})
+		if failures := evaluateFlow(flow); len(failures) != 0 {
+			t.Fatalf("Expected flow to be eligible, got %v", failures)
+		}
+	})
+
+	t.Run("reports every failed check", func(t *testing.T) {
+		flow := newFlow(Opts{
+			DependsOn: []string{primitive.NewObjectID().Hex()},
+			Targeting: Targeting{
+				Rules:      []TargetingRule{{Condition: TargetingRuleUserElapsedDaysFromRegistration, Value: 30}},
+				Expression: &TargetingExpression{Operator: TargetingOperatorEq, Attribute: "plan", Value: "free"},
+			},
+			Schedule: &Schedule{EndAt: time.Now().Add(-time.Hour).Unix()},
+		})
+
+		failures := evaluateFlow(flow)
+		expectedChecks := []EligibilityCheck{
+			EligibilityCheckDependsOn,
+			EligibilityCheckTargetingRules,
+			EligibilityCheckTargetingExpression,
+			EligibilityCheckSchedule,
+		}
+		if len(failures) != len(expectedChecks) {
+			t.Fatalf("Expected %d failures, got %v", len(expectedChecks), failures)
+		}
+		for i, check := range expectedChecks {
+			if failures[i].Check != check {
+				t.Fatalf("Expected %s, got %s", check, failures[i].Check)
+			}
+		}
+	})
+
+	t.Run("user id rules override the audience rules", func(t *testing.T) {
+		flow := newFlow(Opts{Targeting: Targeting{Rules: []TargetingRule{
+			{Condition: TargetingRuleUserIds, Value: primitive.A{"user_1", "user_2"}},
+			{Condition: TargetingRuleUserSegment, Value: "members"},
+		}}})
+		if failures := evaluateFlow(flow); len(failures) != 0 {
+			t.Fatalf("Expected listed user to be eligible, got %v", failures)
+		}
+
+		flow.Opts.Targeting.Rules[0].Value = "user_3"
+		if failures := evaluateFlow(flow); len(failures) != 1 || failures[0].Check != EligibilityCheckTargetingRules {
+			t.Fatalf("Expected user id rule to fail, got %v", failures)
+		}
+	})
+
+	t.Run("finished flows are excluded", func(t *testing.T) {
+		flow := newFlow(Opts{})
+		flow.ID, _ = primitive.ObjectIDFromHex(finishedFlowId)
+		if failures := evaluateFlow(flow); len(failures) != 1 || failures[0].Check != EligibilityCheckFinished {
+			t.Fatalf("Expected finished flow to be excluded, got %v", failures)
+		}
+	})
+
+	t.Run("experiment variants are only stored for flows that pass every check", func(t *testing.T) {
+		rolledOut, excluded := 100, 0
+		flow := newFlow(Opts{RolloutPercentage: &excluded})
+		experiment := &Branching{ID: primitive.NewObjectID(), Variants: []BranchingVariant{{VariantID: "a", FlowID: flow.ID.Hex()}}}
+		enrolling := &enrollmentEvaluation{
+			opts: EnrollmentOpts{
+				UserId:                "user_1",
+				ExperimentAssignments: map[string]ExperimentAssignment{},
+				RolloutAssignments:    map[string]RolloutAssignment{},
+			},
+			now:                 time.Now(),
+			experimentsByFlowId: map[string]*Branching{flow.ID.Hex(): experiment},
+			workspaceFrequency:  &FrequencySettings{},
+		}
+
+		failures, err := enrolling.evaluate(flow, true)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if len(failures) != 1 || failures[0].Check != EligibilityCheckRollout {
+			t.Fatalf("Expected the rollout to exclude the user, got %v", failures)
+		}
+		if len(enrolling.opts.ExperimentAssignments) != 0 {
+			t.Fatalf("Expected no variant for an excluded user, got %v", enrolling.opts.ExperimentAssignments)
+		}
+
+		flow.Opts.RolloutPercentage = &rolledOut
+		failures, err = enrolling.evaluate(flow, true)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if len(failures) != 0 || enrolling.opts.ExperimentAssignments[experiment.ID.Hex()].VariantID != "a" {
+			t.Fatalf("Expected the variant to be stored, got %v and %v", failures, enrolling.opts.ExperimentAssignments)
+		}
+	})
+
+	t.Run("flows with their own frequency settings also follow the workspace caps", func(t *testing.T) {
+		capped := &enrollmentEvaluation{
+			opts: EnrollmentOpts{History: map[FlowSlot]FlowHistory{
+				FlowSlotModal: {EnrolledTimestamps: []int64{time.Now().Add(-time.Hour).Unix()}},
+			}},
+			now:                 time.Now(),
+			dryRun:              true,
+			experimentsByFlowId: map[string]*Branching{},
+			workspaceFrequency:  &FrequencySettings{Caps: []FrequencyCap{{MaxFlows: 1, PeriodHours: 24}}},
+		}
+		flow := newFlow(Opts{Frequency: &FrequencySettings{Caps: []FrequencyCap{{MaxFlows: 5, PeriodHours: 24}}}})
+
+		failures, err := capped.evaluate(flow, false)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if len(failures) != 1 || failures[0].Check != EligibilityCheckFrequency {
+			t.Fatalf("Expected the workspace cap to apply, got %v", failures)
+		}
+	})
+}
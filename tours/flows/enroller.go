@@ -6,11 +6,17 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"milestone_core/identity/workspace"
 	"time"
 )
 
 type Enroller struct {
-	Collection *mongo.Collection
+	Collection          *mongo.Collection
+	DraftCollection     *mongo.Collection
+	BranchingCollection *mongo.Collection
+	WorkspaceService    workspace.Service
+	SettingsService     SettingsService
 }
 
 type EnrollmentOpts struct {
@@ -20,234 +26,255 @@ type EnrollmentOpts struct {
 	SignUpTimestamp     int64
 	UserSegment         string
 	UserId              string
+	UserAttributes      map[string]any
+	// ClientContext is sent by the SDK for the current page, flows are only filtered by triggers when it is set
+	ClientContext *ClientContext
+	// RolloutAssignments are the sticky rollout groups of the user by flow id. New treatment and holdout
+	// assignments are added to the map, callers persist it with the user state.
+	RolloutAssignments map[string]RolloutAssignment
+	// ExperimentAssignments are the sticky experiment variants of the user by branching id, new assignments are
+	// added to the map the same way as rollout assignments.
+	ExperimentAssignments map[string]ExperimentAssignment
+	// CurrentEnrollments are the flows the user is in by slot, used by GetFlows
+	CurrentEnrollments map[FlowSlot]string
+	// History is used to enforce the frequency caps and the cooldown after a skipped flow, each slot is capped
+	// on its own
+	History map[FlowSlot]FlowHistory
 }
 
+// GetFlow returns the flow the user is currently enrolled in or the first live flow, by priority, that passes
+// all eligibility checks.
 func (s *Enroller) GetFlow(workspaceId string, opts EnrollmentOpts) (*Flow, error) {
-	queryOpts, err := s.buildQueryOpts(workspaceId, opts)
+	if opts.CurrentEnrollmentId != "" {
+		return s.getLiveFlow(workspaceId, opts.CurrentEnrollmentId)
+	}
+
+	liveFlows, err := s.listLiveFlows(workspaceId)
 	if err != nil {
 		return nil, err
 	}
 
-	var flow Flow
-	err = s.Collection.FindOne(context.Background(), queryOpts).Decode(&flow)
-	if errors.Is(err, mongo.ErrNoDocuments) {
-		return nil, nil
-	}
+	evaluation, err := s.newEvaluation(workspaceId, opts, false)
 	if err != nil {
 		return nil, err
 	}
 
-	return &flow, nil
-}
-
-func (s *Enroller) buildQueryOpts(workspaceId string, opts EnrollmentOpts) (bson.M, error) {
-	queryOpts := bson.M{"$and": []bson.M{}}
-	queryOpts["$and"] = append(queryOpts["$and"].([]bson.M), bson.M{
-		"workspaceId": workspaceId,
-		"live":        true,
-	})
-
-	if opts.CurrentEnrollmentId != "" {
-		queryOpts = s.withCurrentEnrollmentIdCondition(queryOpts, opts.CurrentEnrollmentId)
-		return queryOpts, nil
+	for _, flow := range liveFlows {
+		failures, err := evaluation.evaluate(flow, true)
+		if err != nil {
+			return nil, err
+		}
+		if len(failures) == 0 {
+			return flow, nil
+		}
 	}
 
-	queryOpts = s.withDependsOnCondition(queryOpts, opts.FinishedIds)
-	queryOpts = s.withExcludedFlows(queryOpts, append(opts.FinishedIds, opts.SkippedIds...))
-	queryOpts = s.withTargetingRules(queryOpts, opts)
-
-	return queryOpts, nil
+	return nil, nil
 }
 
-func (s *Enroller) withCurrentEnrollmentIdCondition(queryOpts bson.M, currentEnrollmentId string) bson.M {
-	flowId, err := primitive.ObjectIDFromHex(currentEnrollmentId)
+// GetFlows returns one flow per slot. A slot keeps the flow the user is currently enrolled in while it is live,
+// empty slots get the first live flow of their types, by priority, that passes all eligibility checks.
+func (s *Enroller) GetFlows(workspaceId string, opts EnrollmentOpts) (map[FlowSlot]*Flow, error) {
+	liveFlows, err := s.listLiveFlows(workspaceId)
 	if err != nil {
-		return queryOpts
+		return nil, err
 	}
-	queryOpts["$and"] = append(queryOpts["$and"].([]bson.M), bson.M{"_id": flowId})
 
-	return queryOpts
-}
+	slotFlows := currentSlotFlows(liveFlows, opts.CurrentEnrollments)
 
-func (s *Enroller) withDependsOnCondition(queryOpts bson.M, ids []string) bson.M {
-	inCondition := bson.M{"opts.dependsOn": bson.M{"$in": []string{}}}
-	if len(ids) > 0 {
-		inCondition = bson.M{"opts.dependsOn": bson.M{"$in": ids}}
+	evaluation, err := s.newEvaluation(workspaceId, opts, false)
+	if err != nil {
+		return nil, err
 	}
-	emptyCondition := bson.M{"opts.dependsOn": bson.M{"$size": 0}}
-	keyNotExist := bson.M{"opts.dependsOn": bson.M{"$exists": false}}
-
-	clause := bson.M{"$or": []bson.M{inCondition, emptyCondition, keyNotExist}}
-	queryOpts["$and"] = append(queryOpts["$and"].([]bson.M), clause)
 
-	return queryOpts
-}
-
-func (s *Enroller) withExcludedFlows(queryOpts bson.M, excludedIds []string) bson.M {
-	if len(excludedIds) == 0 {
-		return queryOpts
-	}
+	for _, flow := range liveFlows {
+		slot := flow.Type.Slot()
+		if _, taken := slotFlows[slot]; taken {
+			continue
+		}
 
-	primitiveIds := make([]primitive.ObjectID, len(excludedIds))
-	for i, id := range excludedIds {
-		oid, err := primitive.ObjectIDFromHex(id)
+		failures, err := evaluation.evaluate(flow, true)
 		if err != nil {
-			return queryOpts
+			return nil, err
+		}
+		if len(failures) == 0 {
+			slotFlows[slot] = flow
 		}
-		primitiveIds[i] = oid
 	}
 
-	excludedCondition := bson.M{"$nin": primitiveIds}
-	queryOpts["$and"] = append(queryOpts["$and"].([]bson.M), bson.M{"_id": excludedCondition})
-
-	return queryOpts
+	return slotFlows, nil
 }
 
-func (s *Enroller) withTargetingRules(queryOpts bson.M, opts EnrollmentOpts) bson.M {
-	audienceQuery := bson.M{"$and": []bson.M{}}
-	audienceQuery = s.withUserElapsedTimeRule(audienceQuery, opts.SignUpTimestamp)
-	audienceQuery = s.withUserRegisteredAfterTimestamp(audienceQuery, opts.SignUpTimestamp)
-	audienceQuery = s.withUserSegment(audienceQuery, opts.UserSegment)
-	userIdRuleNotExists := bson.M{
-		"opts.targeting.rules": bson.M{
-			"$not": bson.M{
-				"$elemMatch": bson.M{
-					"condition": TargetingRuleUserIds,
-				},
-			},
-		},
+// Explain runs the same checks as GetFlows for every flow of the workspace and reports all checks that exclude
+// a flow. Rollout and experiment assignments are evaluated but not added to the options.
+func (s *Enroller) Explain(workspaceId string, opts EnrollmentOpts) (*EligibilityReport, error) {
+	liveFlows, err := s.listLiveFlows(workspaceId)
+	if err != nil {
+		return nil, err
+	}
+	liveFlowsById := make(map[string]*Flow, len(liveFlows))
+	for _, flow := range liveFlows {
+		liveFlowsById[flow.ID.Hex()] = flow
+	}
+	currentFlowIds := make(map[string]bool, len(opts.CurrentEnrollments))
+	for _, flowId := range opts.CurrentEnrollments {
+		currentFlowIds[flowId] = true
 	}
-	audienceQuery["$and"] = append(audienceQuery["$and"].([]bson.M), userIdRuleNotExists)
 
-	targetingQuery := bson.M{"$or": []bson.M{audienceQuery}}
+	drafts, err := s.listDrafts(workspaceId)
+	if err != nil {
+		return nil, err
+	}
 
-	if opts.UserId != "" {
-		priorityRulesQuery := bson.M{"$and": []bson.M{}}
-		priorityRulesQuery = s.withUserId(priorityRulesQuery, opts.UserId)
-		targetingQuery["$or"] = append(targetingQuery["$or"].([]bson.M), priorityRulesQuery)
+	evaluation, err := s.newEvaluation(workspaceId, opts, true)
+	if err != nil {
+		return nil, err
 	}
 
-	queryOpts["$and"] = append(queryOpts["$and"].([]bson.M), targetingQuery)
+	report := &EligibilityReport{
+		CurrentFlowIDs:  opts.CurrentEnrollments,
+		SelectedFlowIDs: make(map[FlowSlot]string),
+		Flows:           make([]FlowEligibility, 0, len(drafts)),
+	}
+	for slot, flow := range currentSlotFlows(liveFlows, opts.CurrentEnrollments) {
+		report.SelectedFlowIDs[slot] = flow.ID.Hex()
+	}
 
-	return queryOpts
-}
+	for _, draft := range drafts {
+		flowId := draft.ID.Hex()
+		eligibility := FlowEligibility{
+			FlowID:   flowId,
+			Name:     draft.Name,
+			Priority: draft.Priority,
+			Slot:     draft.Type.Slot(),
+			Current:  currentFlowIds[flowId],
+		}
 
-func (s *Enroller) withUserElapsedTimeRule(queryOpts bson.M, signUpTimestamp int64) bson.M {
-	keyNotExist := bson.M{"opts.targeting.rules": bson.M{"$exists": false}}
-	emptyCondition := bson.M{"opts.targeting.rules": bson.M{"$size": 0}}
-
-	ruleNotExist := bson.M{
-		"opts.targeting.rules": bson.M{
-			"$not": bson.M{
-				"$elemMatch": bson.M{
-					"condition": TargetingRuleUserElapsedDaysFromRegistration,
-				},
-			},
-		},
-	}
+		liveFlow, isLive := liveFlowsById[flowId]
+		if !isLive {
+			eligibility.Failures = []CheckFailure{{Check: EligibilityCheckLive, Reason: "flow is not published"}}
+			report.Flows = append(report.Flows, eligibility)
+			continue
+		}
 
-	clause := []bson.M{keyNotExist, emptyCondition, ruleNotExist}
-
-	if signUpTimestamp != 0 {
-		elapsedDays := calculateDaysBetweenTimestamps(signUpTimestamp, time.Now().Unix())
-		conditionMatch := bson.M{
-			"opts.targeting.rules": bson.M{
-				"$elemMatch": bson.M{
-					"condition": TargetingRuleUserElapsedDaysFromRegistration,
-					"value":     bson.M{"$lte": elapsedDays},
-				},
-			},
+		eligibility.Live = true
+		eligibility.Failures, err = evaluation.evaluate(liveFlow, false)
+		if err != nil {
+			return nil, err
+		}
+		eligibility.Eligible = len(eligibility.Failures) == 0
+		if _, taken := report.SelectedFlowIDs[eligibility.Slot]; eligibility.Eligible && !taken {
+			report.SelectedFlowIDs[eligibility.Slot] = flowId
 		}
-		clause = append(clause, conditionMatch)
+		report.Flows = append(report.Flows, eligibility)
 	}
 
-	queryOpts["$and"] = append(queryOpts["$and"].([]bson.M), bson.M{"$or": clause})
+	for i := range report.Flows {
+		report.Flows[i].Selected = report.SelectedFlowIDs[report.Flows[i].Slot] == report.Flows[i].FlowID
+	}
 
-	return queryOpts
+	return report, nil
 }
 
-func (s *Enroller) withUserRegisteredAfterTimestamp(queryOpts bson.M, signUpTimestamp int64) bson.M {
-	keyNotExist := bson.M{"opts.targeting.rules": bson.M{"$exists": false}}
-	emptyCondition := bson.M{"opts.targeting.rules": bson.M{"$size": 0}}
-
-	ruleNotExist := bson.M{
-		"opts.targeting.rules": bson.M{
-			"$not": bson.M{
-				"$elemMatch": bson.M{
-					"condition": UserRegisteredAfterTimestamp,
-				},
-			},
-		},
+// currentSlotFlows keeps the current enrollments that are still live, flows moved to another slot by a type
+// change are dropped
+func currentSlotFlows(liveFlows []*Flow, currentEnrollments map[FlowSlot]string) map[FlowSlot]*Flow {
+	slotFlows := make(map[FlowSlot]*Flow)
+	for _, flow := range liveFlows {
+		slot := flow.Type.Slot()
+		if currentEnrollments[slot] == flow.ID.Hex() {
+			slotFlows[slot] = flow
+		}
 	}
 
-	clause := []bson.M{keyNotExist, emptyCondition, ruleNotExist}
+	return slotFlows
+}
 
-	if signUpTimestamp != 0 {
-		conditionMatch := bson.M{
-			"opts.targeting.rules": bson.M{
-				"$elemMatch": bson.M{
-					"condition": UserRegisteredAfterTimestamp,
-					"value":     bson.M{"$lte": signUpTimestamp},
-				},
-			},
-		}
-		clause = append(clause, conditionMatch)
+func (s *Enroller) getLiveFlow(workspaceId string, flowId string) (*Flow, error) {
+	id, err := primitive.ObjectIDFromHex(flowId)
+	if err != nil {
+		return nil, err
 	}
 
-	queryOpts["$and"] = append(queryOpts["$and"].([]bson.M), bson.M{"$or": clause})
+	var flow Flow
+	err = s.Collection.FindOne(context.Background(), bson.M{"_id": id, "workspaceId": workspaceId, "live": true}).Decode(&flow)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	return queryOpts
+	return &flow, nil
 }
 
-func (s *Enroller) withUserSegment(queryOpts bson.M, segment string) bson.M {
-	keyNotExist := bson.M{"opts.targeting.rules": bson.M{"$exists": false}}
-	emptyCondition := bson.M{"opts.targeting.rules": bson.M{"$size": 0}}
-	ruleNotExist := bson.M{
-		"opts.targeting.rules": bson.M{
-			"$not": bson.M{
-				"$elemMatch": bson.M{
-					"condition": TargetingRuleUserSegment,
-				},
-			},
-		},
+// GetPreviewFlow returns the draft of the flow, previews skip the eligibility checks and work for unpublished flows
+func (s *Enroller) GetPreviewFlow(workspaceId string, flowId string) (*Flow, error) {
+	id, err := primitive.ObjectIDFromHex(flowId)
+	if err != nil {
+		return nil, err
+	}
+
+	var flow Flow
+	err = s.DraftCollection.FindOne(context.Background(), bson.M{"_id": id, "workspaceId": workspaceId}).Decode(&flow)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errors.New("flow not found")
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	clause := []bson.M{keyNotExist, emptyCondition, ruleNotExist}
+	return &flow, nil
+}
 
-	if segment != "" {
-		conditionMatch := bson.M{
-			"opts.targeting.rules": bson.M{
-				"$elemMatch": bson.M{
-					"condition": TargetingRuleUserSegment,
-					"value":     segment,
-				},
-			},
-		}
-		clause = append(clause, conditionMatch)
+func (s *Enroller) listLiveFlows(workspaceId string) ([]*Flow, error) {
+	return s.listByPriority(s.Collection, bson.M{"workspaceId": workspaceId, "live": true})
+}
+
+func (s *Enroller) listDrafts(workspaceId string) ([]*Flow, error) {
+	return s.listByPriority(s.DraftCollection, bson.M{"workspaceId": workspaceId})
+}
+
+func (s *Enroller) listByPriority(collection *mongo.Collection, filter bson.M) ([]*Flow, error) {
+	findOpts := options.Find().SetSort(bson.D{{Key: "priority", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := collection.Find(context.Background(), filter, findOpts)
+	if err != nil {
+		return nil, err
 	}
 
-	queryOpts["$and"] = append(queryOpts["$and"].([]bson.M), bson.M{"$or": clause})
+	flows := make([]*Flow, 0)
+	if err = cursor.All(context.Background(), &flows); err != nil {
+		return nil, err
+	}
 
-	return queryOpts
+	return flows, nil
 }
 
-func (s *Enroller) withUserId(queryOpts bson.M, userId string) bson.M {
-	if userId == "" {
-		return queryOpts
+// getExperimentsByFlowId maps the flows of every variant of the running experiments to their branching
+func (s *Enroller) getExperimentsByFlowId(workspaceId string) (map[string]*Branching, error) {
+	experimentsByFlowId := make(map[string]*Branching)
+	if s.BranchingCollection == nil {
+		return experimentsByFlowId, nil
 	}
 
-	conditionMatch := bson.M{
-		"opts.targeting.rules": bson.M{
-			"$elemMatch": bson.M{
-				"condition": TargetingRuleUserIds,
-				"value":     userId,
-			},
-		},
+	cursor, err := s.BranchingCollection.Find(context.Background(), bson.M{"workspaceId": workspaceId, "experiment.enabled": true})
+	if err != nil {
+		return nil, err
 	}
+	defer cursor.Close(context.Background())
 
-	queryOpts["$and"] = append(queryOpts["$and"].([]bson.M), conditionMatch)
+	for cursor.Next(context.Background()) {
+		var branching Branching
+		err = cursor.Decode(&branching)
+		if err != nil {
+			return nil, err
+		}
+		for _, variant := range branching.Variants {
+			experimentsByFlowId[variant.FlowID] = &branching
+		}
+	}
 
-	return queryOpts
+	return experimentsByFlowId, cursor.Err()
 }
 
 func calculateDaysBetweenTimestamps(providedTimestamp int64, currentTimestamp int64) int {
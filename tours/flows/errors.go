@@ -0,0 +1,19 @@
+package flows
+
+import "strconv"
+
+type ValidationError struct {
+	Problems []ValidationProblem
+}
+
+func (e *ValidationError) Error() string {
+	return "flow is invalid, found " + strconv.Itoa(len(e.Problems)) + " problem(s)"
+}
+
+func validationErrorOrNil(problems []ValidationProblem) error {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Problems: problems}
+}
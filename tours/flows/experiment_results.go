@@ -0,0 +1,261 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"milestone_core/gamification/events"
+	"milestone_core/tours/tracker"
+	"time"
+)
+
+// significanceLevel is the p-value below which a difference to the control is reported as significant
+const significanceLevel = 0.05
+
+type ExperimentService struct {
+	BranchingService        BranchingService
+	UserStateCollection     *mongo.Collection
+	EnrolledUsersCollection *mongo.Collection
+	Tracker                 tracker.Tracker
+	EventsService           events.Service
+}
+
+type ExperimentResults struct {
+	BranchingID string           `json:"branchingId"`
+	Goal        ExperimentGoal   `json:"goal"`
+	Variants    []VariantResults `json:"variants"`
+}
+
+// VariantResults are compared with the first variant of the branching, which is the control
+type VariantResults struct {
+	VariantID          string             `json:"variantId"`
+	Name               string             `json:"name"`
+	FlowID             string             `json:"flowId"`
+	Control            bool               `json:"control"`
+	Users              int                `json:"users"`
+	Conversions        int                `json:"conversions"`
+	ConversionRate     float64            `json:"conversionRate"`
+	ConfidenceInterval ConfidenceInterval `json:"confidenceInterval"`
+	ZScore             float64            `json:"zScore"`
+	PValue             float64            `json:"pValue"`
+	Significant        bool               `json:"significant"`
+}
+
+type experimentParticipant struct {
+	ExternalUserID string
+	Assignment     ExperimentAssignment
+}
+
+func (s ExperimentService) GetResults(workspace string, branchingId string) (*ExperimentResults, error) {
+	branching, err := s.BranchingService.Get(workspace, branchingId)
+	if err != nil {
+		return nil, err
+	}
+	if branching == nil {
+		return nil, errors.New("branching not found")
+	}
+	if branching.Experiment == nil {
+		return nil, errors.New("branching is not an experiment")
+	}
+
+	participants, err := s.getParticipants(workspace, branchingId)
+	if err != nil {
+		return nil, err
+	}
+
+	converted, err := s.getConvertedUsers(workspace, branching, participants)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &ExperimentResults{
+		BranchingID: branchingId,
+		Goal:        branching.Experiment.Goal,
+		Variants:    make([]VariantResults, len(branching.Variants)),
+	}
+	for i, variant := range branching.Variants {
+		variantResults := VariantResults{
+			VariantID: variant.VariantID,
+			Name:      variant.Name,
+			FlowID:    variant.FlowID,
+			Control:   i == 0,
+			PValue:    1,
+		}
+		for _, participant := range participants {
+			if participant.Assignment.VariantID != variant.VariantID {
+				continue
+			}
+			variantResults.Users++
+			if converted[participant.ExternalUserID] {
+				variantResults.Conversions++
+			}
+		}
+		if variantResults.Users > 0 {
+			variantResults.ConversionRate = float64(variantResults.Conversions) / float64(variantResults.Users)
+		}
+		variantResults.ConfidenceInterval = wilsonInterval(variantResults.Conversions, variantResults.Users)
+		results.Variants[i] = variantResults
+	}
+
+	control := results.Variants[0]
+	for i := 1; i < len(results.Variants); i++ {
+		variant := &results.Variants[i]
+		variant.ZScore, variant.PValue = twoProportionZTest(control.Conversions, control.Users, variant.Conversions, variant.Users)
+		variant.Significant = variant.PValue < significanceLevel
+	}
+
+	return results, nil
+}
+
+// getParticipants reads the sticky assignments from the user states and resolves the external user ids used by
+// the tracker and the gamification events.
+func (s ExperimentService) getParticipants(workspace string, branchingId string) ([]experimentParticipant, error) {
+	assignmentKey := "flowsData.experimentAssignments." + branchingId
+	cursor, err := s.UserStateCollection.Find(
+		context.Background(),
+		bson.M{"workspaceId": workspace, assignmentKey: bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"userId": 1, assignmentKey: 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	assignmentsByUserId := make(map[primitive.ObjectID]ExperimentAssignment)
+	for cursor.Next(context.Background()) {
+		var state struct {
+			UserID    string `bson:"userId"`
+			FlowsData struct {
+				ExperimentAssignments map[string]ExperimentAssignment `bson:"experimentAssignments"`
+			} `bson:"flowsData"`
+		}
+		err = cursor.Decode(&state)
+		if err != nil {
+			return nil, err
+		}
+		userId, err := primitive.ObjectIDFromHex(state.UserID)
+		if err != nil {
+			continue
+		}
+		assignmentsByUserId[userId] = state.FlowsData.ExperimentAssignments[branchingId]
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	userIds := make([]primitive.ObjectID, 0, len(assignmentsByUserId))
+	for userId := range assignmentsByUserId {
+		userIds = append(userIds, userId)
+	}
+
+	usersCursor, err := s.EnrolledUsersCollection.Find(
+		context.Background(),
+		bson.M{"_id": bson.M{"$in": userIds}},
+		options.Find().SetProjection(bson.M{"externalId": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer usersCursor.Close(context.Background())
+
+	participants := make([]experimentParticipant, 0, len(userIds))
+	for usersCursor.Next(context.Background()) {
+		var user struct {
+			ID         primitive.ObjectID `bson:"_id"`
+			ExternalID string             `bson:"externalId"`
+		}
+		err = usersCursor.Decode(&user)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, experimentParticipant{
+			ExternalUserID: user.ExternalID,
+			Assignment:     assignmentsByUserId[user.ID],
+		})
+	}
+
+	return participants, usersCursor.Err()
+}
+
+// getConvertedUsers returns the external ids of the participants that reached the goal. Goal events and finished
+// flows only count when they happened after the user was assigned to the experiment.
+func (s ExperimentService) getConvertedUsers(workspace string, branching *Branching, participants []experimentParticipant) (map[string]bool, error) {
+	converted := make(map[string]bool)
+	goal := branching.Experiment.Goal
+
+	if goal.Type == ExperimentGoalTypeEvent {
+		userIds, firstAssignedAt := participantRange(participants)
+		occurrences, err := s.EventsService.GetEventOccurrences(workspace, goal.EventKey, userIds, time.Unix(firstAssignedAt, 0))
+		if err != nil {
+			return nil, err
+		}
+
+		assignedAt := make(map[string]int64, len(participants))
+		for _, participant := range participants {
+			assignedAt[participant.ExternalUserID] = participant.Assignment.AssignedAt
+		}
+		for _, occurrence := range occurrences {
+			userAssignedAt, ok := assignedAt[occurrence.UserID]
+			if ok && occurrence.CreatedAt.Unix() >= userAssignedAt {
+				converted[occurrence.UserID] = true
+			}
+		}
+
+		return converted, nil
+	}
+
+	goalFlowIds := make(map[string]bool)
+	for _, variant := range branching.Variants {
+		goalFlowIds[variant.FlowID] = true
+	}
+	if goal.FlowID != "" {
+		goalFlowIds = map[string]bool{goal.FlowID: true}
+	}
+
+	// The last time each user finished each goal flow, a finish after the assignment converts the user
+	lastFinishedByUser := make(map[string]map[string]int64)
+	for flowId := range goalFlowIds {
+		finishEvents, err := s.Tracker.FetchFlowEvents(flowId, tracker.EventTypeFlowFinished)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range finishEvents {
+			if lastFinishedByUser[event.ExternalUserID] == nil {
+				lastFinishedByUser[event.ExternalUserID] = make(map[string]int64)
+			}
+			if event.Timestamp > lastFinishedByUser[event.ExternalUserID][flowId] {
+				lastFinishedByUser[event.ExternalUserID][flowId] = event.Timestamp
+			}
+		}
+	}
+
+	for _, participant := range participants {
+		goalFlowId := goal.FlowID
+		if goalFlowId == "" {
+			goalFlowId = participant.Assignment.FlowID
+		}
+		finishedAt, finished := lastFinishedByUser[participant.ExternalUserID][goalFlowId]
+		if finished && finishedAt >= participant.Assignment.AssignedAt {
+			converted[participant.ExternalUserID] = true
+		}
+	}
+
+	return converted, nil
+}
+
+// participantRange returns the external ids of the participants and the time the first of them was assigned
+func participantRange(participants []experimentParticipant) ([]string, int64) {
+	userIds := make([]string, 0, len(participants))
+	var firstAssignedAt int64
+	for i, participant := range participants {
+		userIds = append(userIds, participant.ExternalUserID)
+		if i == 0 || participant.Assignment.AssignedAt < firstAssignedAt {
+			firstAssignedAt = participant.Assignment.AssignedAt
+		}
+	}
+
+	return userIds, firstAssignedAt
+}
@@ -0,0 +1,53 @@
+package flows
+
+import (
+	"math"
+)
+
+// confidenceZ is the z score of a two sided 95% confidence level
+const confidenceZ = 1.959964
+
+type ConfidenceInterval struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// wilsonInterval stays within [0, 1] and behaves well for small samples and rates close to 0 or 1
+func wilsonInterval(conversions int, users int) ConfidenceInterval {
+	if users == 0 {
+		return ConfidenceInterval{}
+	}
+
+	n := float64(users)
+	p := float64(conversions) / n
+	z2 := confidenceZ * confidenceZ
+	denominator := 1 + z2/n
+	center := (p + z2/(2*n)) / denominator
+	margin := confidenceZ * math.Sqrt(p*(1-p)/n+z2/(4*n*n)) / denominator
+
+	return ConfidenceInterval{
+		Lower: math.Max(0, center-margin),
+		Upper: math.Min(1, center+margin),
+	}
+}
+
+// twoProportionZTest compares the conversion rate of a variant with the control and returns the z score with
+// its two sided p-value.
+func twoProportionZTest(controlConversions int, controlUsers int, variantConversions int, variantUsers int) (float64, float64) {
+	if controlUsers == 0 || variantUsers == 0 {
+		return 0, 1
+	}
+
+	controlRate := float64(controlConversions) / float64(controlUsers)
+	variantRate := float64(variantConversions) / float64(variantUsers)
+	pooledRate := float64(controlConversions+variantConversions) / float64(controlUsers+variantUsers)
+	standardError := math.Sqrt(pooledRate * (1 - pooledRate) * (1/float64(controlUsers) + 1/float64(variantUsers)))
+	if standardError == 0 {
+		return 0, 1
+	}
+
+	zScore := (variantRate - controlRate) / standardError
+	pValue := math.Erfc(math.Abs(zScore) / math.Sqrt2)
+
+	return zScore, pValue
+}
@@ -0,0 +1,98 @@
+package flows
+
+import (
+	"errors"
+)
+
+// Experiment splits the users eligible for the variant flows of a branching. Every user is assigned to exactly one
+// variant and only ever gets the flow of that variant.
+type Experiment struct {
+	Enabled bool           `json:"enabled" bson:"enabled"`
+	Goal    ExperimentGoal `json:"goal" bson:"goal"`
+}
+
+type ExperimentGoalType string
+
+const (
+	ExperimentGoalTypeEvent        ExperimentGoalType = "event"
+	ExperimentGoalTypeFlowFinished ExperimentGoalType = "flow_finished"
+)
+
+// ExperimentGoal is either an event key of the gamification events or a finished flow. Without a flow id the
+// flow finished goal counts users that finished the flow of their own variant.
+type ExperimentGoal struct {
+	Type     ExperimentGoalType `json:"type" bson:"type"`
+	EventKey string             `json:"eventKey,omitempty" bson:"eventKey,omitempty"`
+	FlowID   string             `json:"flowId,omitempty" bson:"flowId,omitempty"`
+}
+
+// ExperimentAssignment is stored on the user state by branching id, so users keep their variant across sessions
+type ExperimentAssignment struct {
+	VariantID  string `json:"variantId" bson:"variantId"`
+	FlowID     string `json:"flowId" bson:"flowId"`
+	AssignedAt int64  `json:"assignedAt" bson:"assignedAt"`
+}
+
+// AssignVariant deterministically picks a variant by its weight. Variants without weights share the traffic equally.
+func AssignVariant(branching Branching, externalUserId string) *BranchingVariant {
+	if len(branching.Variants) == 0 {
+		return nil
+	}
+
+	totalWeight := 0
+	for _, variant := range branching.Variants {
+		totalWeight += variant.Weight
+	}
+
+	bucket := RolloutBucket(externalUserId, branching.ID.Hex())
+	if totalWeight == 0 {
+		return &branching.Variants[bucket*len(branching.Variants)/rolloutBuckets]
+	}
+
+	position := bucket * totalWeight / rolloutBuckets
+	for i, variant := range branching.Variants {
+		if position < variant.Weight {
+			return &branching.Variants[i]
+		}
+		position -= variant.Weight
+	}
+
+	return &branching.Variants[len(branching.Variants)-1]
+}
+
+func ValidateBranching(branching Branching) error {
+	seenVariantIds := make(map[string]bool)
+	seenFlowIds := make(map[string]bool)
+	for _, variant := range branching.Variants {
+		if variant.VariantID == "" || seenVariantIds[variant.VariantID] {
+			return errors.New("variant ids must be set and unique")
+		}
+		seenVariantIds[variant.VariantID] = true
+		if variant.Weight < 0 {
+			return errors.New("variant weights cannot be negative")
+		}
+		if branching.Experiment != nil && seenFlowIds[variant.FlowID] {
+			return errors.New("experiment variants must point at different flows")
+		}
+		seenFlowIds[variant.FlowID] = true
+	}
+
+	if branching.Experiment == nil {
+		return nil
+	}
+	if len(branching.Variants) < 2 {
+		return errors.New("experiment requires at least two variants")
+	}
+
+	switch branching.Experiment.Goal.Type {
+	case ExperimentGoalTypeEvent:
+		if branching.Experiment.Goal.EventKey == "" {
+			return errors.New("event goal requires an event key")
+		}
+	case ExperimentGoalTypeFlowFinished:
+	default:
+		return errors.New("unknown experiment goal type: " + string(branching.Experiment.Goal.Type))
+	}
+
+	return nil
+}
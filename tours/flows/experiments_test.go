@@ -0,0 +1,89 @@
+package flows
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"testing"
+)
+
+func TestAssignVariant(t *testing.T) {
+	branching := Branching{
+		ID: primitive.NewObjectID(),
+		Variants: []BranchingVariant{
+			{VariantID: "control", FlowID: "flow_a", Weight: 3},
+			{VariantID: "challenger", FlowID: "flow_b", Weight: 1},
+		},
+		Experiment: &Experiment{Enabled: true, Goal: ExperimentGoal{Type: ExperimentGoalTypeFlowFinished}},
+	}
+
+	t.Run("variants are sticky and follow the weights", func(t *testing.T) {
+		counts := make(map[string]int)
+		for i := 0; i < 4000; i++ {
+			userId := fmt.Sprintf("user_%d", i)
+			variant := AssignVariant(branching, userId)
+			if AssignVariant(branching, userId).VariantID != variant.VariantID {
+				t.Fatalf("Expected the same variant for %s", userId)
+			}
+			counts[variant.VariantID]++
+		}
+
+		if counts["control"] < 2800 || counts["control"] > 3200 {
+			t.Fatalf("Expected about 3000 users in control, got %d", counts["control"])
+		}
+	})
+
+	t.Run("experiments are validated", func(t *testing.T) {
+		if err := ValidateBranching(branching); err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+
+		invalid := branching
+		invalid.Experiment = &Experiment{Goal: ExperimentGoal{Type: ExperimentGoalTypeEvent}}
+		if ValidateBranching(invalid) == nil {
+			t.Fatalf("Expected event goal without key to be invalid")
+		}
+
+		invalid = branching
+		invalid.Variants = branching.Variants[:1]
+		if ValidateBranching(invalid) == nil {
+			t.Fatalf("Expected experiment with one variant to be invalid")
+		}
+	})
+}
+
+func TestExperimentStats(t *testing.T) {
+	t.Run("wilson interval", func(t *testing.T) {
+		interval := wilsonInterval(50, 100)
+		if math.Abs(interval.Lower-0.4038) > 0.001 || math.Abs(interval.Upper-0.5962) > 0.001 {
+			t.Fatalf("Unexpected interval %v", interval)
+		}
+
+		interval = wilsonInterval(0, 10)
+		if interval.Lower != 0 || interval.Upper <= 0 {
+			t.Fatalf("Unexpected interval for no conversions %v", interval)
+		}
+	})
+
+	t.Run("two proportion z test", func(t *testing.T) {
+		zScore, pValue := twoProportionZTest(100, 1000, 150, 1000)
+		if math.Abs(zScore-3.38) > 0.01 || pValue > 0.001 {
+			t.Fatalf("Unexpected z score %f and p-value %f", zScore, pValue)
+		}
+
+		_, pValue = twoProportionZTest(10, 100, 11, 100)
+		if pValue < significanceLevel {
+			t.Fatalf("Expected small difference not to be significant, got %f", pValue)
+		}
+	})
+
+	t.Run("goal events are loaded from the first assignment", func(t *testing.T) {
+		userIds, firstAssignedAt := participantRange([]experimentParticipant{
+			{ExternalUserID: "user_1", Assignment: ExperimentAssignment{AssignedAt: 200}},
+			{ExternalUserID: "user_2", Assignment: ExperimentAssignment{AssignedAt: 100}},
+		})
+		if len(userIds) != 2 || firstAssignedAt != 100 {
+			t.Fatalf("Expected both users from 100, got %v from %d", userIds, firstAssignedAt)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FrequencyCap allows at most MaxFlows flows to be started within PeriodHours, e.g. 3 flows per 168 hours
+type FrequencyCap struct {
+	MaxFlows    int `json:"maxFlows" bson:"maxFlows"`
+	PeriodHours int `json:"periodHours" bson:"periodHours"`
+}
+
+type FrequencySettings struct {
+	Caps []FrequencyCap `json:"caps,omitempty" bson:"caps,omitempty"`
+	// SkipCooldownHours is the time after a skipped flow during which no other flow is started
+	SkipCooldownHours int `json:"skipCooldownHours,omitempty" bson:"skipCooldownHours,omitempty"`
+}
+
+// FlowSettings apply to every flow of the workspace, flows with their own frequency settings have to pass both
+type FlowSettings struct {
+	ID          primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	WorkspaceID string             `json:"workspaceId" bson:"workspaceId"`
+	Frequency   FrequencySettings  `json:"frequency" bson:"frequency"`
+}
+
+// FlowHistory holds the timestamps of the earlier flows of a user that the caps are counted on
+type FlowHistory struct {
+	EnrolledTimestamps   []int64
+	LastSkippedTimestamp int64
+}
+
+// Allows reports whether another flow can be started at the given unix time
+func (f FrequencySettings) Allows(history FlowHistory, now int64) bool {
+	if f.SkipCooldownHours > 0 && history.LastSkippedTimestamp > now-int64(f.SkipCooldownHours)*3600 {
+		return false
+	}
+
+	for _, frequencyCap := range f.Caps {
+		periodStart := now - int64(frequencyCap.PeriodHours)*3600
+		startedFlows := 0
+		for _, timestamp := range history.EnrolledTimestamps {
+			if timestamp > periodStart {
+				startedFlows++
+			}
+		}
+		if startedFlows >= frequencyCap.MaxFlows {
+			return false
+		}
+	}
+
+	return true
+}
+
+func ValidateFrequency(frequency FrequencySettings) error {
+	for _, frequencyCap := range frequency.Caps {
+		if frequencyCap.MaxFlows < 1 || frequencyCap.PeriodHours < 1 {
+			return errors.New("frequency caps require at least one flow and a period of at least one hour")
+		}
+	}
+	if frequency.SkipCooldownHours < 0 {
+		return errors.New("skip cooldown cannot be negative")
+	}
+
+	return nil
+}
+
+type SettingsService struct {
+	Collection *mongo.Collection
+}
+
+func (s SettingsService) Get(workspace string) (*FlowSettings, error) {
+	var settings FlowSettings
+	err := s.Collection.FindOne(context.Background(), bson.M{"workspaceId": workspace}).Decode(&settings)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &FlowSettings{WorkspaceID: workspace}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (s SettingsService) Put(workspace string, settings FlowSettings) error {
+	err := ValidateFrequency(settings.Frequency)
+	if err != nil {
+		return err
+	}
+
+	settings.ID = primitive.NilObjectID
+	settings.WorkspaceID = workspace
+	_, err = s.Collection.ReplaceOne(context.Background(), bson.M{"workspaceId": workspace}, settings, options.Replace().SetUpsert(true))
+
+	return err
+}
@@ -0,0 +1,44 @@
+package flows
+
+import (
+	"testing"
+)
+
+func TestFrequencySettings(t *testing.T) {
+	now := int64(1_720_000_000)
+	hour := int64(3600)
+	settings := FrequencySettings{
+		Caps: []FrequencyCap{
+			{MaxFlows: 1, PeriodHours: 24},
+			{MaxFlows: 3, PeriodHours: 168},
+		},
+		SkipCooldownHours: 48,
+	}
+
+	t.Run("caps count flows started within the period", func(t *testing.T) {
+		if !settings.Allows(FlowHistory{EnrolledTimestamps: []int64{now - 25*hour}}, now) {
+			t.Fatalf("Expected a flow after 25 hours to be allowed")
+		}
+		if settings.Allows(FlowHistory{EnrolledTimestamps: []int64{now - 2*hour}}, now) {
+			t.Fatalf("Expected a second flow within 24 hours to be capped")
+		}
+		if settings.Allows(FlowHistory{EnrolledTimestamps: []int64{now - 30*hour, now - 60*hour, now - 100*hour}}, now) {
+			t.Fatalf("Expected a fourth flow within a week to be capped")
+		}
+	})
+
+	t.Run("cooldown after a skip", func(t *testing.T) {
+		if settings.Allows(FlowHistory{LastSkippedTimestamp: now - 47*hour}, now) {
+			t.Fatalf("Expected the cooldown to block flows")
+		}
+		if !settings.Allows(FlowHistory{LastSkippedTimestamp: now - 49*hour}, now) {
+			t.Fatalf("Expected flows after the cooldown to be allowed")
+		}
+	})
+
+	t.Run("invalid caps are rejected", func(t *testing.T) {
+		if ValidateFrequency(FrequencySettings{Caps: []FrequencyCap{{MaxFlows: 0, PeriodHours: 24}}}) == nil {
+			t.Fatalf("Expected cap without flows to be invalid")
+		}
+	})
+}
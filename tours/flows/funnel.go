@@ -0,0 +1,164 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"milestone_core/tours/tracker"
+)
+
+// FunnelFilter limits the funnel to step events between From and To, unix seconds where 0 is open, and to users
+// of a segment of the enrolled users
+type FunnelFilter struct {
+	From    int64
+	To      int64
+	Segment string
+}
+
+type Funnel struct {
+	FlowID string       `json:"flowId"`
+	Steps  []FunnelStep `json:"steps"`
+}
+
+// FunnelStep counts the users that started and finished the step, durations are in seconds and only cover users
+// that finished the step
+type FunnelStep struct {
+	StepID         string  `json:"stepId"`
+	ParentNodeId   string  `json:"parentNodeId,omitempty"`
+	SegmentID      string  `json:"segmentId,omitempty"`
+	Started        int     `json:"started"`
+	Finished       int     `json:"finished"`
+	DropOffPercent float64 `json:"dropOffPercent"`
+	MedianDuration float64 `json:"medianDuration"`
+	P90Duration    float64 `json:"p90Duration"`
+}
+
+type funnelStepRow struct {
+	StepID    string     `bson:"_id"`
+	Started   int        `bson:"started"`
+	Finished  int        `bson:"finished"`
+	Durations []*float64 `bson:"durations"`
+}
+
+// GetFunnel walks the steps from the root step and reports how many users reached and finished each of them. The
+// counting is done by the database, grouped by user and step first and then by step.
+func (s Analytics) GetFunnel(flow *Flow, filter FunnelFilter) (*Funnel, error) {
+	if flow == nil {
+		return nil, errors.New("flow not found")
+	}
+
+	cursor, err := s.Tracker.Collection.Aggregate(context.Background(), funnelPipeline(flow, filter, s.EnrolledUsersCollection.Name()))
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]funnelStepRow, 0)
+	if err = cursor.All(context.Background(), &rows); err != nil {
+		return nil, err
+	}
+
+	return buildFunnel(flow, rows), nil
+}
+
+func funnelPipeline(flow *Flow, filter FunnelFilter, usersCollection string) mongo.Pipeline {
+	match := bson.M{
+		"workspaceId":     flow.WorkspaceID,
+		"entityId":        flow.ID.Hex(),
+		"eventType":       bson.M{"$in": bson.A{tracker.EventTypeFlowStepStart, tracker.EventTypeFlowStepFinish}},
+		"preview":         bson.M{"$ne": true},
+		"metadata.stepId": bson.M{"$exists": true},
+	}
+	timestamp := bson.M{}
+	if filter.From != 0 {
+		timestamp["$gte"] = filter.From
+	}
+	if filter.To != 0 {
+		timestamp["$lte"] = filter.To
+	}
+	if len(timestamp) > 0 {
+		match["timestamp"] = timestamp
+	}
+
+	// firstOf keeps the first timestamp of an event type, like the average step times
+	firstOf := func(eventType tracker.EventType) bson.M {
+		return bson.M{"$min": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$eventType", eventType}}, "$timestamp", nil}}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      bson.M{"stepId": "$metadata.stepId", "user": "$externalUserId"},
+			"started":  firstOf(tracker.EventTypeFlowStepStart),
+			"finished": firstOf(tracker.EventTypeFlowStepFinish),
+		}}},
+	}
+
+	if filter.Segment != "" {
+		pipeline = append(pipeline,
+			bson.D{{Key: "$lookup", Value: bson.M{
+				"from": usersCollection,
+				"let":  bson.M{"user": "$_id.user"},
+				"pipeline": bson.A{
+					bson.M{"$match": bson.M{
+						"workspaceId": flow.WorkspaceID,
+						"segment":     filter.Segment,
+						"$expr":       bson.M{"$eq": bson.A{"$externalId", "$$user"}},
+					}},
+					bson.M{"$project": bson.M{"_id": 1}},
+				},
+				"as": "enrolledUser",
+			}}},
+			bson.D{{Key: "$match", Value: bson.M{"enrolledUser": bson.M{"$ne": bson.A{}}}}},
+		)
+	}
+
+	isSet := func(field string) bson.M {
+		return bson.M{"$ne": bson.A{bson.M{"$ifNull": bson.A{field, nil}}, nil}}
+	}
+	finishedAfterStart := bson.M{"$and": bson.A{isSet("$started"), isSet("$finished"), bson.M{"$gte": bson.A{"$finished", "$started"}}}}
+
+	return append(pipeline,
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":      "$_id.stepId",
+			"started":  bson.M{"$sum": bson.M{"$cond": bson.A{isSet("$started"), 1, 0}}},
+			"finished": bson.M{"$sum": bson.M{"$cond": bson.A{isSet("$finished"), 1, 0}}},
+			"durations": bson.M{"$percentile": bson.M{
+				"input":  bson.M{"$cond": bson.A{finishedAfterStart, bson.M{"$subtract": bson.A{"$finished", "$started"}}, nil}},
+				"p":      bson.A{0.5, 0.9},
+				"method": "approximate",
+			}},
+		}}},
+	)
+}
+
+// buildFunnel puts the aggregated steps into the order of the step chain, steps nobody reached are reported
+// with zero users
+func buildFunnel(flow *Flow, rows []funnelStepRow) *Funnel {
+	rowsByStepId := make(map[string]funnelStepRow, len(rows))
+	for _, row := range rows {
+		rowsByStepId[row.StepID] = row
+	}
+
+	funnel := &Funnel{FlowID: flow.ID.Hex(), Steps: make([]FunnelStep, 0, len(flow.Steps))}
+	for _, entry := range StepOrder(flow) {
+		row := rowsByStepId[entry.StepID]
+		step := FunnelStep{
+			StepID:       entry.StepID,
+			ParentNodeId: entry.ParentNodeId,
+			SegmentID:    entry.SegmentID,
+			Started:      row.Started,
+			Finished:     row.Finished,
+		}
+		if step.Started > 0 && step.Finished < step.Started {
+			step.DropOffPercent = float64(step.Started-step.Finished) / float64(step.Started) * 100
+		}
+		// Steps nobody finished have no durations
+		if len(row.Durations) == 2 && row.Durations[0] != nil && row.Durations[1] != nil {
+			step.MedianDuration, step.P90Duration = *row.Durations[0], *row.Durations[1]
+		}
+		funnel.Steps = append(funnel.Steps, step)
+	}
+
+	return funnel
+}
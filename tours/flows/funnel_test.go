@@ -0,0 +1,53 @@
+package flows
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestFunnel(t *testing.T) {
+	flow := &Flow{WorkspaceID: "workspace", Steps: []Step{
+		{StepID: "c", ParentNodeId: "b"},
+		{StepID: "a"},
+		{StepID: "b", ParentNodeId: "a"},
+	}}
+
+	t.Run("steps follow the chain from the root", func(t *testing.T) {
+		median, p90 := 4.0, 9.0
+		funnel := buildFunnel(flow, []funnelStepRow{
+			{StepID: "a", Started: 10, Finished: 8, Durations: []*float64{&median, &p90}},
+			{StepID: "b", Started: 8, Finished: 2, Durations: []*float64{nil, nil}},
+		})
+
+		if len(funnel.Steps) != 3 || funnel.Steps[0].StepID != "a" || funnel.Steps[1].StepID != "b" || funnel.Steps[2].StepID != "c" {
+			t.Fatalf("Expected the steps in the order a, b, c, got %+v", funnel.Steps)
+		}
+		first := funnel.Steps[0]
+		if first.DropOffPercent != 20 || first.MedianDuration != 4 || first.P90Duration != 9 {
+			t.Fatalf("Expected 20%% drop off with the durations, got %+v", first)
+		}
+		if funnel.Steps[1].DropOffPercent != 75 || funnel.Steps[1].MedianDuration != 0 {
+			t.Fatalf("Expected 75%% drop off without durations, got %+v", funnel.Steps[1])
+		}
+		if funnel.Steps[2].Started != 0 || funnel.Steps[2].DropOffPercent != 0 {
+			t.Fatalf("Expected an unreached step to have no users, got %+v", funnel.Steps[2])
+		}
+	})
+
+	t.Run("filters become part of the pipeline", func(t *testing.T) {
+		pipeline := funnelPipeline(flow, FunnelFilter{From: 100, To: 200, Segment: "trial"}, "enrolled_users")
+		if len(pipeline) != 5 {
+			t.Fatalf("Expected the segment lookup stages, got %d stages", len(pipeline))
+		}
+		match := pipeline[0][0].Value.(bson.M)
+		timestamp := match["timestamp"].(bson.M)
+		if timestamp["$gte"] != int64(100) || timestamp["$lte"] != int64(200) {
+			t.Fatalf("Expected the date range on the timestamp, got %v", timestamp)
+		}
+
+		unfiltered := funnelPipeline(flow, FunnelFilter{}, "enrolled_users")
+		if _, ok := unfiltered[0][0].Value.(bson.M)["timestamp"]; ok || len(unfiltered) != 3 {
+			t.Fatalf("Expected no filters without a range or segment")
+		}
+	})
+}
@@ -0,0 +1,256 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"milestone_core/tours/tracker"
+	"time"
+)
+
+type FlowGoalType string
+
+const (
+	FlowGoalTypeEvent        FlowGoalType = "event"
+	FlowGoalTypeURLVisit     FlowGoalType = "url_visit"
+	FlowGoalTypeFlowFinished FlowGoalType = "flow_finished"
+)
+
+const (
+	DefaultAttributionWindowDays = 7
+	MaxAttributionWindowDays     = 365
+)
+
+// FlowGoal is what the flow should lead users to: a gamification event, a visit of a url, tracked by the SDK as
+// page views, or another flow finished. Goals only count within the attribution window after the user finished
+// or skipped the flow.
+type FlowGoal struct {
+	Type     FlowGoalType         `json:"type" bson:"type"`
+	EventKey string               `json:"eventKey,omitempty" bson:"eventKey,omitempty"`
+	URL      TriggerValueURLMatch `json:"url,omitempty" bson:"url,omitempty"`
+	FlowID   string               `json:"flowId,omitempty" bson:"flowId,omitempty"`
+	// AttributionWindowDays defaults to DefaultAttributionWindowDays
+	AttributionWindowDays int `json:"attributionWindowDays,omitempty" bson:"attributionWindowDays,omitempty"`
+}
+
+// GoalAnalytics compares the conversion of users that finished or skipped the flow with users that never saw it
+type GoalAnalytics struct {
+	Goal                  FlowGoal            `json:"goal"`
+	AttributionWindowDays int                 `json:"attributionWindowDays"`
+	Finished              GoalCohortAnalytics `json:"finished"`
+	Skipped               GoalCohortAnalytics `json:"skipped"`
+	NotSeen               GoalCohortAnalytics `json:"notSeen"`
+}
+
+type GoalCohortAnalytics struct {
+	Users          int     `json:"users"`
+	Conversions    int     `json:"conversions"`
+	ConversionRate float64 `json:"conversionRate"`
+}
+
+func ValidateGoal(flow *Flow, goal FlowGoal) error {
+	switch goal.Type {
+	case FlowGoalTypeEvent:
+		if goal.EventKey == "" {
+			return errors.New("event goals need an event key")
+		}
+	case FlowGoalTypeURLVisit:
+		if goal.URL.Pattern == "" {
+			return errors.New("url goals need a url pattern")
+		}
+		if _, err := matchURL(goal.URL, ""); err != nil {
+			return err
+		}
+	case FlowGoalTypeFlowFinished:
+		if goal.FlowID == "" {
+			return errors.New("flow finished goals need a flow")
+		}
+		if goal.FlowID == flow.ID.Hex() {
+			return errors.New("the goal can not be finishing the flow itself")
+		}
+	default:
+		return errors.New("unknown goal type: " + string(goal.Type))
+	}
+
+	if goal.AttributionWindowDays < 0 || goal.AttributionWindowDays > MaxAttributionWindowDays {
+		return errors.New("attribution window must be between 0 and 365 days")
+	}
+
+	return nil
+}
+
+func (g FlowGoal) attributionWindow() int64 {
+	days := g.AttributionWindowDays
+	if days == 0 {
+		days = DefaultAttributionWindowDays
+	}
+
+	return int64(days) * int64(24*time.Hour/time.Second)
+}
+
+// getGoalAnalytics splits the users into cohorts by the flow events, finishing wins over skipping. Users that saw
+// the flow without finishing or skipping it are not part of any cohort. Users that never saw the flow are measured
+// from the time they were enrolled.
+func (s Analytics) getGoalAnalytics(flow *Flow, events []tracker.EventTrack) (*GoalAnalytics, error) {
+	goal := *flow.Opts.Goal
+	finishedAt, skippedAt := flowOutcomes(events)
+
+	notSeenSince, err := s.getNotSeenUsers(flow.WorkspaceID, events)
+	if err != nil {
+		return nil, err
+	}
+
+	window := goal.attributionWindow()
+	goalTimes, err := s.getGoalTimes(flow.WorkspaceID, goal, window, finishedAt, skippedAt, notSeenSince)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GoalAnalytics{
+		Goal:                  goal,
+		AttributionWindowDays: int(window / int64(24*time.Hour/time.Second)),
+		Finished:              goalCohort(finishedAt, goalTimes, window),
+		Skipped:               goalCohort(skippedAt, goalTimes, window),
+		NotSeen:               goalCohort(notSeenSince, goalTimes, window),
+	}, nil
+}
+
+// flowOutcomes returns when each user first finished the flow, and when users that never finished it skipped it
+func flowOutcomes(events []tracker.EventTrack) (map[string]int64, map[string]int64) {
+	finishedAt := make(map[string]int64)
+	skippedAt := make(map[string]int64)
+	firstTime := func(times map[string]int64, event tracker.EventTrack) {
+		if current, ok := times[event.ExternalUserID]; !ok || event.Timestamp < current {
+			times[event.ExternalUserID] = event.Timestamp
+		}
+	}
+
+	for _, event := range events {
+		switch event.EventType {
+		case tracker.EventTypeFlowFinished:
+			firstTime(finishedAt, event)
+		case tracker.EventTypeFlowSkipped:
+			firstTime(skippedAt, event)
+		}
+	}
+	for userId := range finishedAt {
+		delete(skippedAt, userId)
+	}
+
+	return finishedAt, skippedAt
+}
+
+// goalCohort counts the users that reached the goal within the window after their reference time
+func goalCohort(referenceTimes map[string]int64, goalTimes map[string][]int64, window int64) GoalCohortAnalytics {
+	cohort := GoalCohortAnalytics{Users: len(referenceTimes)}
+	for userId, referenceTime := range referenceTimes {
+		for _, goalTime := range goalTimes[userId] {
+			if goalTime >= referenceTime && goalTime <= referenceTime+window {
+				cohort.Conversions++
+				break
+			}
+		}
+	}
+	if cohort.Users > 0 {
+		cohort.ConversionRate = float64(cohort.Conversions) / float64(cohort.Users)
+	}
+
+	return cohort
+}
+
+// getNotSeenUsers returns the enrolled users without any event of the flow with the time they were enrolled
+func (s Analytics) getNotSeenUsers(workspaceId string, events []tracker.EventTrack) (map[string]int64, error) {
+	seen := make(map[string]bool)
+	for _, event := range events {
+		seen[event.ExternalUserID] = true
+	}
+
+	cursor, err := s.EnrolledUsersCollection.Find(
+		context.Background(),
+		bson.M{"workspaceId": workspaceId},
+		options.Find().SetProjection(bson.M{"externalId": 1, "created": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	notSeenSince := make(map[string]int64)
+	for cursor.Next(context.Background()) {
+		var user struct {
+			ExternalID string `bson:"externalId"`
+			Created    int64  `bson:"created"`
+		}
+		err = cursor.Decode(&user)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[user.ExternalID] {
+			notSeenSince[user.ExternalID] = user.Created
+		}
+	}
+
+	return notSeenSince, cursor.Err()
+}
+
+// getGoalTimes returns the times each user reached the goal, by external user id. Events and page views are only
+// loaded for the users of the cohorts within the attribution windows.
+func (s Analytics) getGoalTimes(workspaceId string, goal FlowGoal, window int64, cohorts ...map[string]int64) (map[string][]int64, error) {
+	goalTimes := make(map[string][]int64)
+
+	userIds, from, to := cohortRange(window, cohorts...)
+	switch goal.Type {
+	case FlowGoalTypeEvent:
+		occurrences, err := s.EventsService.GetEventOccurrences(workspaceId, goal.EventKey, userIds, time.Unix(from, 0))
+		if err != nil {
+			return nil, err
+		}
+		for _, occurrence := range occurrences {
+			goalTimes[occurrence.UserID] = append(goalTimes[occurrence.UserID], occurrence.CreatedAt.Unix())
+		}
+	case FlowGoalTypeURLVisit:
+		pageViews, err := s.Tracker.FetchUserEvents(workspaceId, tracker.EventTypePageView, userIds, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range pageViews {
+			matches, err := matchURL(goal.URL, event.Metadata["url"])
+			if err != nil {
+				return nil, err
+			}
+			if matches {
+				goalTimes[event.ExternalUserID] = append(goalTimes[event.ExternalUserID], event.Timestamp)
+			}
+		}
+	case FlowGoalTypeFlowFinished:
+		finishEvents, err := s.Tracker.FetchFlowEvents(goal.FlowID, tracker.EventTypeFlowFinished)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range finishEvents {
+			goalTimes[event.ExternalUserID] = append(goalTimes[event.ExternalUserID], event.Timestamp)
+		}
+	}
+
+	return goalTimes, nil
+}
+
+// cohortRange returns the users of the cohorts and the time range that covers all their attribution windows
+func cohortRange(window int64, cohorts ...map[string]int64) ([]string, int64, int64) {
+	userIds := make([]string, 0)
+	var from, to int64
+	for _, cohort := range cohorts {
+		for userId, referenceTime := range cohort {
+			if len(userIds) == 0 || referenceTime < from {
+				from = referenceTime
+			}
+			if len(userIds) == 0 || referenceTime+window > to {
+				to = referenceTime + window
+			}
+			userIds = append(userIds, userId)
+		}
+	}
+
+	return userIds, from, to
+}
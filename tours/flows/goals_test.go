@@ -0,0 +1,70 @@
+package flows
+
+import (
+	"milestone_core/tours/tracker"
+	"testing"
+)
+
+func TestGoalAnalytics(t *testing.T) {
+	day := int64(24 * 60 * 60)
+	events := []tracker.EventTrack{
+		{ExternalUserID: "finisher", EventType: tracker.EventTypeFlowStepStart, Timestamp: 100},
+		{ExternalUserID: "finisher", EventType: tracker.EventTypeFlowFinished, Timestamp: 200},
+		{ExternalUserID: "late", EventType: tracker.EventTypeFlowFinished, Timestamp: 100},
+		{ExternalUserID: "skipper", EventType: tracker.EventTypeFlowSkipped, Timestamp: 300},
+		{ExternalUserID: "retried", EventType: tracker.EventTypeFlowSkipped, Timestamp: 100},
+		{ExternalUserID: "retried", EventType: tracker.EventTypeFlowFinished, Timestamp: 400},
+	}
+
+	finishedAt, skippedAt := flowOutcomes(events)
+
+	t.Run("finishing wins over skipping", func(t *testing.T) {
+		if len(finishedAt) != 3 || finishedAt["retried"] != 400 {
+			t.Fatalf("Expected three finished users, got %v", finishedAt)
+		}
+		if len(skippedAt) != 1 || skippedAt["skipper"] != 300 {
+			t.Fatalf("Expected only the skipper to have skipped, got %v", skippedAt)
+		}
+	})
+
+	t.Run("goals count within the attribution window", func(t *testing.T) {
+		goalTimes := map[string][]int64{
+			"finisher": {150, 200 + day},
+			"late":     {100 + 8*day},
+			"skipper":  {250},
+			"retried":  {400},
+		}
+
+		finished := goalCohort(finishedAt, goalTimes, FlowGoal{}.attributionWindow())
+		if finished.Users != 3 || finished.Conversions != 2 {
+			t.Fatalf("Expected 2 of 3 finished users to convert, got %+v", finished)
+		}
+		skipped := goalCohort(skippedAt, goalTimes, FlowGoal{}.attributionWindow())
+		if skipped.Conversions != 0 || skipped.ConversionRate != 0 {
+			t.Fatalf("Expected goals before skipping not to count, got %+v", skipped)
+		}
+	})
+
+	t.Run("page views are loaded for the cohort users within their windows", func(t *testing.T) {
+		userIds, from, to := cohortRange(day, finishedAt, skippedAt)
+		if len(userIds) != 4 || from != 100 || to != 400+day {
+			t.Fatalf("Expected 4 users from 100 to %d, got %v from %d to %d", 400+day, userIds, from, to)
+		}
+	})
+
+	t.Run("goals are validated", func(t *testing.T) {
+		flow := &Flow{}
+		if ValidateGoal(flow, FlowGoal{Type: FlowGoalTypeEvent}) == nil {
+			t.Fatalf("Expected an error for an event goal without a key")
+		}
+		if ValidateGoal(flow, FlowGoal{Type: FlowGoalTypeURLVisit, URL: TriggerValueURLMatch{Mode: URLMatchModeRegex, Pattern: "("}}) == nil {
+			t.Fatalf("Expected an error for an invalid url pattern")
+		}
+		if ValidateGoal(flow, FlowGoal{Type: FlowGoalTypeEvent, EventKey: "signup", AttributionWindowDays: 400}) == nil {
+			t.Fatalf("Expected an error for a too long attribution window")
+		}
+		if err := ValidateGoal(flow, FlowGoal{Type: FlowGoalTypeURLVisit, URL: TriggerValueURLMatch{Mode: URLMatchModePrefix, Pattern: "/billing"}}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	})
+}
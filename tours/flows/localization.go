@@ -0,0 +1,159 @@
+package flows
+
+import (
+	"errors"
+	"milestone_core/shared/versioning"
+	"milestone_core/tours/audit"
+	"milestone_core/tours/personalization"
+	"milestone_core/tours/translations"
+	"strings"
+)
+
+// translationKey builds the keys of the translatable content of a step, like "steps/step_1/blocks/block_1"
+func translationKey(stepId string, parts ...string) string {
+	return "steps/" + stepId + "/" + strings.Join(parts, "/")
+}
+
+// TranslationEntries lists the translatable content of the flow: text blocks, survey questions and choices,
+// action texts and branching option labels
+func TranslationEntries(flow *Flow) []translations.Entry {
+	entries := make([]translations.Entry, 0)
+	add := func(key string, source string) {
+		if source != "" {
+			entries = append(entries, translations.Entry{Key: key, Source: source})
+		}
+	}
+
+	for _, step := range flow.Steps {
+		for _, block := range step.Data.Blocks {
+			if block.Type == StepBlockTypeText {
+				add(translationKey(step.StepID, "blocks", block.BlockID), block.Data)
+			}
+			if block.Survey != nil {
+				add(translationKey(step.StepID, "blocks", block.BlockID, "question"), block.Survey.Question)
+				for _, choice := range block.Survey.Choices {
+					add(translationKey(step.StepID, "blocks", block.BlockID, "choices", choice.ChoiceID), choice.Label)
+				}
+			}
+		}
+		add(translationKey(step.StepID, "actionText"), step.Data.ActionText)
+		for _, option := range step.Data.BranchingOptions {
+			add(translationKey(step.StepID, "branchingOptions", option.OptionID), option.Label)
+		}
+	}
+
+	return entries
+}
+
+// LocalizeFlow returns a copy of the flow with the content in the locale, content without a translation keeps
+// the default. The translations themselves are not part of the copy.
+func LocalizeFlow(flow *Flow, locale string) *Flow {
+	if flow == nil {
+		return nil
+	}
+
+	resolver := flow.Translations.Resolver(locale)
+	localized := flow
+	if !resolver.IsEmpty() {
+		localized = mapFlowContent(flow, resolver.Translate)
+	}
+
+	withoutTranslations := *localized
+	withoutTranslations.Translations = nil
+	return &withoutTranslations
+}
+
+// mapFlowContent returns a copy of the flow with every content string replaced by the mapper, which gets the
+// translation key of the content
+func mapFlowContent(flow *Flow, mapper func(key string, content string) string) *Flow {
+	mapped := *flow
+	mapped.Steps = make([]Step, len(flow.Steps))
+	for i, step := range flow.Steps {
+		step.Data.Blocks = make([]StepBlock, len(flow.Steps[i].Data.Blocks))
+		for j, block := range flow.Steps[i].Data.Blocks {
+			block.Data = mapper(translationKey(step.StepID, "blocks", block.BlockID), block.Data)
+			if block.Survey != nil {
+				survey := *block.Survey
+				survey.Question = mapper(translationKey(step.StepID, "blocks", block.BlockID, "question"), survey.Question)
+				survey.Choices = make([]SurveyChoice, len(block.Survey.Choices))
+				for k, choice := range block.Survey.Choices {
+					choice.Label = mapper(translationKey(step.StepID, "blocks", block.BlockID, "choices", choice.ChoiceID), choice.Label)
+					survey.Choices[k] = choice
+				}
+				block.Survey = &survey
+			}
+			step.Data.Blocks[j] = block
+		}
+
+		step.Data.ActionText = mapper(translationKey(step.StepID, "actionText"), step.Data.ActionText)
+		step.Data.BranchingOptions = make([]BranchingOption, len(flow.Steps[i].Data.BranchingOptions))
+		for j, option := range flow.Steps[i].Data.BranchingOptions {
+			option.Label = mapper(translationKey(step.StepID, "branchingOptions", option.OptionID), option.Label)
+			step.Data.BranchingOptions[j] = option
+		}
+		mapped.Steps[i] = step
+	}
+
+	return &mapped
+}
+
+// ExportTranslations returns the translation bundle of the draft for the locale
+func (s Service) ExportTranslations(workspace string, id string, locale string, sourceLocale string) (*translations.Bundle, error) {
+	flow, err := s.Get(workspace, id)
+	if err != nil {
+		return nil, err
+	}
+	if flow == nil {
+		return nil, errors.New("flow not found")
+	}
+
+	locale = translations.NormalizeLocale(locale)
+	if locale == "" {
+		return nil, errors.New("locale is required")
+	}
+
+	return &translations.Bundle{
+		Original:     id,
+		SourceLocale: sourceLocale,
+		Locale:       locale,
+		Entries:      flow.Translations.WithTargets(locale, TranslationEntries(flow)),
+	}, nil
+}
+
+// ImportTranslations stores the translations of the bundle on the draft, they are shown to users once the flow
+// is published
+func (s Service) ImportTranslations(workspace string, id string, version int64, bundle translations.Bundle, actor audit.Actor) error {
+	flow, err := s.Get(workspace, id)
+	if err != nil {
+		return err
+	}
+	if flow == nil {
+		return errors.New("flow not found")
+	}
+	err = versioning.Check(version, flow.Version, flow)
+	if err != nil {
+		return err
+	}
+	before, err := audit.Snapshot(flow)
+	if err != nil {
+		return err
+	}
+
+	merged, unknownKeys := flow.Translations.Merge(bundle.Locale, bundle.Entries, TranslationEntries(flow))
+	if len(unknownKeys) > 0 {
+		return errors.New("unknown translation keys: " + strings.Join(unknownKeys, ", "))
+	}
+	for _, entry := range bundle.Entries {
+		if err := personalization.Validate(entry.Target); err != nil {
+			return errors.New(entry.Key + ": " + err.Error())
+		}
+	}
+	flow.Translations = merged
+
+	err = s.saveUpdatedFlow(flow)
+	if err != nil {
+		return err
+	}
+
+	return s.AuditLog.Record(workspace, actor, audit.EntityTypeFlow, id, audit.ActionUpdated, before, flow)
+}
@@ -0,0 +1,47 @@
+package flows
+
+import (
+	"milestone_core/tours/translations"
+	"testing"
+)
+
+func TestLocalizeFlow(t *testing.T) {
+	flow := &Flow{
+		Steps: []Step{{StepID: "step_1", Data: StepData{
+			Blocks: []StepBlock{
+				{BlockID: "block_1", Type: StepBlockTypeText, Data: "Welcome"},
+				{BlockID: "block_2", Type: StepBlockTypeImage, Data: "https://example.com/image.png"},
+			},
+			ActionText: "Next",
+		}}},
+		Translations: translations.Translations{
+			"de": {
+				"steps/step_1/blocks/block_1": "Willkommen",
+				"steps/step_1/actionText":     "Weiter",
+			},
+		},
+	}
+
+	t.Run("entries cover the text content", func(t *testing.T) {
+		entries := TranslationEntries(flow)
+		if len(entries) != 2 || entries[0].Key != "steps/step_1/blocks/block_1" || entries[1].Key != "steps/step_1/actionText" {
+			t.Fatalf("Expected the text block and action text, got %+v", entries)
+		}
+	})
+
+	t.Run("returns a translated copy", func(t *testing.T) {
+		localized := LocalizeFlow(flow, "de-AT")
+		if localized.Steps[0].Data.Blocks[0].Data != "Willkommen" || localized.Steps[0].Data.ActionText != "Weiter" {
+			t.Fatalf("Expected the German content, got %+v", localized.Steps[0].Data)
+		}
+		if localized.Steps[0].Data.Blocks[1].Data != "https://example.com/image.png" {
+			t.Fatalf("Expected untranslated blocks to keep their content")
+		}
+		if localized.Translations != nil {
+			t.Fatalf("Expected the translations to be left out")
+		}
+		if flow.Steps[0].Data.Blocks[0].Data != "Welcome" {
+			t.Fatalf("Expected the flow not to be changed")
+		}
+	})
+}
@@ -2,17 +2,38 @@ package flows
 
 import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"milestone_core/tours/translations"
 )
 
 type Flow struct {
 	ID          primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
 	WorkspaceID string             `json:"workspaceId" bson:"workspaceId"`
 	Name        string             `json:"name" bson:"name"`
+	Type        FlowType           `json:"type,omitempty" bson:"type,omitempty"`
 	BaseURL     string             `json:"baseUrl,omitempty" bson:"baseUrl,omitempty"`
 	Segments    []Segment          `json:"segments,omitempty" bson:"segments,omitempty"`
 	Steps       []Step             `json:"steps" bson:"steps"`
 	Opts        Opts               `json:"opts,omitempty" bson:"opts,omitempty"`
 	Live        bool               `json:"live" bson:"live"`
+
+	PublishedRevision int `json:"publishedRevision" bson:"publishedRevision"`
+	// Priority orders eligible flows in the enroller, lower values are enrolled first
+	Priority int `json:"priority" bson:"priority"`
+	// Translations of the step content by locale, see TranslationEntries for the keys
+	Translations translations.Translations `json:"translations,omitempty" bson:"translations,omitempty"`
+	// Version is increased on every save of the draft, writes with an older version are rejected
+	Version int64 `json:"version" bson:"version"`
+}
+
+type FlowRevision struct {
+	ID             primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	FlowID         string             `json:"flowId" bson:"flowId"`
+	WorkspaceID    string             `json:"workspaceId" bson:"workspaceId"`
+	Revision       int                `json:"revision" bson:"revision"`
+	Flow           *Flow              `json:"flow,omitempty" bson:"flow,omitempty"`
+	PublishedAt    int64              `json:"publishedAt" bson:"publishedAt"`
+	PublishedBy    string             `json:"publishedBy" bson:"publishedBy"`
+	RolledBackFrom int                `json:"rolledBackFrom,omitempty" bson:"rolledBackFrom,omitempty"`
 }
 
 type Step struct {
@@ -23,14 +44,24 @@ type Step struct {
 }
 
 type StepData struct {
-	TargetUrl          string          `json:"targetUrl" bson:"targetUrl,omitempty"`
-	AssignedCssElement string          `json:"assignedCssElement" bson:"assignedCssElement,omitempty"`
-	ElementType        StepElementType `json:"elementType" bson:"elementType,omitempty"`
-	Placement          StepPlacement   `json:"placement" bson:"placement,omitempty"`
-	Blocks             []StepBlock     `json:"blocks" bson:"blocks,omitempty"`
-	Transition         StepTransition  `json:"transition" bson:"transition,omitempty"`
-	ActionType         StepActionType  `json:"actionType" bson:"actionType,omitempty"`
-	ActionText         string          `json:"actionText" bson:"actionText,omitempty"`
+	TargetUrl          string            `json:"targetUrl" bson:"targetUrl,omitempty"`
+	AssignedCssElement string            `json:"assignedCssElement" bson:"assignedCssElement,omitempty"`
+	ElementType        StepElementType   `json:"elementType" bson:"elementType,omitempty"`
+	Placement          StepPlacement     `json:"placement" bson:"placement,omitempty"`
+	Blocks             []StepBlock       `json:"blocks" bson:"blocks,omitempty"`
+	Transition         StepTransition    `json:"transition" bson:"transition,omitempty"`
+	ActionType         StepActionType    `json:"actionType" bson:"actionType,omitempty"`
+	ActionText         string            `json:"actionText" bson:"actionText,omitempty"`
+	BranchingOptions   []BranchingOption `json:"branchingOptions,omitempty" bson:"branchingOptions,omitempty"`
+}
+
+// BranchingOption is a choice offered to the end user on a branching step. It routes either to an explicit
+// child step or to the first child step of a segment.
+type BranchingOption struct {
+	OptionID   string `json:"optionId" bson:"optionId"`
+	Label      string `json:"label" bson:"label"`
+	SegmentID  string `json:"segmentId,omitempty" bson:"segmentId,omitempty"`
+	NextStepID string `json:"nextStepId,omitempty" bson:"nextStepId,omitempty"`
 }
 
 type StepOpts struct {
@@ -54,6 +85,16 @@ type Opts struct {
 	ElementTemplate StepElementTemplate `json:"elementTemplate" bson:"elementTemplate,omitempty"`
 	FinishEffect    FinishEffect        `json:"finishEffect,omitempty" bson:"finishEffect,omitempty"`
 	DependsOn       []string            `json:"dependsOn,omitempty" bson:"dependsOn,omitempty"`
+	// RolloutPercentage limits the flow to a share of the eligible users, nil means everyone
+	RolloutPercentage *int `json:"rolloutPercentage,omitempty" bson:"rolloutPercentage,omitempty"`
+	// HoldoutPercentage is the share of eligible users that never see the flow, used to measure its impact
+	HoldoutPercentage int `json:"holdoutPercentage,omitempty" bson:"holdoutPercentage,omitempty"`
+	// Schedule limits when the flow is shown, nil means the flow is shown whenever it is live
+	Schedule *Schedule `json:"schedule,omitempty" bson:"schedule,omitempty"`
+	// Frequency adds caps for this flow on top of the frequency settings of the workspace
+	Frequency *FrequencySettings `json:"frequency,omitempty" bson:"frequency,omitempty"`
+	// Goal is what the flow should lead users to, reported with the analytics of the flow
+	Goal *FlowGoal `json:"goal,omitempty" bson:"goal,omitempty"`
 }
 
 type Relation struct {
@@ -72,6 +113,8 @@ type StepBlock struct {
 	Type    StepBlockType `json:"type" bson:"type"`
 	Data    string        `json:"data" bson:"data"`
 	Order   int           `json:"order" bson:"order"`
+	// Survey is set for the survey block types
+	Survey *SurveyBlock `json:"survey,omitempty" bson:"survey,omitempty"`
 }
 
 type Trigger struct {
@@ -79,12 +122,62 @@ type Trigger struct {
 }
 
 type TriggerRule struct {
-	Condition string `json:"condition,omitempty" bson:"condition,omitempty"`
-	Value     any    `json:"value,omitempty" bson:"value,omitempty"`
+	Condition TriggerCondition `json:"condition,omitempty" bson:"condition,omitempty"`
+	Value     any              `json:"value,omitempty" bson:"value,omitempty"`
+}
+
+type TriggerCondition string
+
+const (
+	TriggerConditionURLMatch       TriggerCondition = "url_match"
+	TriggerConditionElementPresent TriggerCondition = "element_present"
+	TriggerConditionCustomEvent    TriggerCondition = "custom_event"
+	TriggerConditionTimeOnPage     TriggerCondition = "time_on_page"
+	TriggerConditionNthVisit       TriggerCondition = "nth_visit"
+)
+
+type TriggerValueURLMatch struct {
+	Mode    URLMatchMode `json:"mode" mapstructure:"mode"`
+	Pattern string       `json:"pattern" mapstructure:"pattern"`
+}
+
+type URLMatchMode string
+
+const (
+	URLMatchModeExact  URLMatchMode = "exact"
+	URLMatchModePrefix URLMatchMode = "prefix"
+	URLMatchModeGlob   URLMatchMode = "glob"
+	URLMatchModeRegex  URLMatchMode = "regex"
+)
+
+type TriggerValueElementPresent struct {
+	Selector string `json:"selector" mapstructure:"selector"`
+}
+
+type TriggerValueCustomEvent struct {
+	Event string `json:"event" mapstructure:"event"`
+}
+
+type TriggerValueTimeOnPage struct {
+	Seconds int `json:"seconds" mapstructure:"seconds"`
+}
+
+type TriggerValueNthVisit struct {
+	Visit int `json:"visit" mapstructure:"visit"`
+}
+
+// ClientContext is what the SDK knows about the page the end user is currently on
+type ClientContext struct {
+	CurrentURL        string   `json:"currentUrl"`
+	PresentElements   []string `json:"presentElements,omitempty"`
+	RecentEvents      []string `json:"recentEvents,omitempty"`
+	TimeOnPageSeconds int      `json:"timeOnPageSeconds,omitempty"`
+	VisitCount        int      `json:"visitCount,omitempty"`
 }
 
 type Targeting struct {
-	Rules []TargetingRule `json:"rules,omitempty" bson:"rules,omitempty"`
+	Rules      []TargetingRule      `json:"rules,omitempty" bson:"rules,omitempty"`
+	Expression *TargetingExpression `json:"expression,omitempty" bson:"expression,omitempty"`
 }
 
 type TargetingRule struct {
@@ -138,6 +231,12 @@ const (
 	StepBlockTypeImage  StepBlockType = "image"
 	StepBlockTypeVideo  StepBlockType = "video"
 	StepBlockTypeAvatar StepBlockType = "avatar"
+
+	StepBlockTypeNPS          StepBlockType = "nps"
+	StepBlockTypeRating       StepBlockType = "rating"
+	StepBlockTypeSingleChoice StepBlockType = "single_choice"
+	StepBlockTypeMultiChoice  StepBlockType = "multi_choice"
+	StepBlockTypeFreeText     StepBlockType = "free_text"
 )
 
 type StepElementType string
@@ -180,19 +279,37 @@ type Branching struct {
 	BaseURL     string             `json:"baseUrl,omitempty" bson:"baseUrl,omitempty"`
 	Variants    []BranchingVariant `json:"variants" bson:"variants"`
 	TargetURL   string             `json:"targetUrl,omitempty" bson:"targetUrl,omitempty"`
+	Experiment  *Experiment        `json:"experiment,omitempty" bson:"experiment,omitempty"`
+	Version     int64              `json:"version" bson:"version"`
 }
 
 type BranchingVariant struct {
 	VariantID string `json:"variantId" bson:"variantId"`
 	FlowID    string `json:"flowId" bson:"flowId"`
 	Name      string `json:"name" bson:"name"`
+	// Weight is the relative share of traffic of the variant in an experiment
+	Weight int `json:"weight" bson:"weight"`
 }
 
 type FlowAnalytics struct {
-	FlowID       string           `json:"flowId" bson:"flowId"`
-	Views        int              `json:"views" bson:"views"`
-	NoOfFinished int              `json:"noOfFinished" bson:"noOfFinished"`
-	NoOfSkipped  int              `json:"noOfSkipped" bson:"noOfSkipped"`
-	AvgTotalTime int64            `json:"avgTotalTime" bson:"avgTotalTime"`
-	AvgStepTime  map[string]int64 `json:"avgStepTime" bson:"avgStepTime"`
+	FlowID       string            `json:"flowId" bson:"flowId"`
+	Views        int               `json:"views" bson:"views"`
+	NoOfFinished int               `json:"noOfFinished" bson:"noOfFinished"`
+	NoOfSkipped  int               `json:"noOfSkipped" bson:"noOfSkipped"`
+	AvgTotalTime int64             `json:"avgTotalTime" bson:"avgTotalTime"`
+	AvgStepTime  map[string]int64  `json:"avgStepTime" bson:"avgStepTime"`
+	Rollout      *RolloutAnalytics `json:"rollout,omitempty" bson:"rollout,omitempty"`
+	Goal         *GoalAnalytics    `json:"goal,omitempty" bson:"goal,omitempty"`
+}
+
+type RolloutAnalytics struct {
+	Treatment RolloutGroupAnalytics `json:"treatment" bson:"treatment"`
+	Holdout   RolloutGroupAnalytics `json:"holdout" bson:"holdout"`
+}
+
+type RolloutGroupAnalytics struct {
+	Users      int     `json:"users" bson:"users"`
+	Finished   int     `json:"finished" bson:"finished"`
+	Skipped    int     `json:"skipped" bson:"skipped"`
+	FinishRate float64 `json:"finishRate" bson:"finishRate"`
 }
@@ -0,0 +1,65 @@
+package flows
+
+import (
+	"milestone_core/tours/personalization"
+	"strings"
+)
+
+// PersonalizeFlow returns a copy of the flow with the templates of the content rendered with the values
+func PersonalizeFlow(flow *Flow, values personalization.Values) *Flow {
+	if flow == nil {
+		return nil
+	}
+
+	return mapFlowContent(flow, func(key string, content string) string {
+		return personalization.Render(content, values)
+	})
+}
+
+// PersonalizationNamespaces returns the variable namespaces used by the content of the flow
+func PersonalizationNamespaces(flow *Flow) map[string]bool {
+	entries := TranslationEntries(flow)
+	contents := make([]string, len(entries))
+	for i, entry := range entries {
+		contents[i] = entry.Source
+	}
+
+	return personalization.Namespaces(contents...)
+}
+
+// validatePersonalization checks the templates of the content and of its translations
+func validatePersonalization(flow *Flow) []ValidationProblem {
+	problems := make([]ValidationProblem, 0)
+	for _, entry := range TranslationEntries(flow) {
+		if err := personalization.Validate(entry.Source); err != nil {
+			problems = append(problems, ValidationProblem{
+				StepID:  stepIdFromTranslationKey(entry.Key),
+				Code:    ValidationCodeInvalidTemplate,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	for locale, values := range flow.Translations {
+		for key, value := range values {
+			if err := personalization.Validate(value); err != nil {
+				problems = append(problems, ValidationProblem{
+					StepID:  stepIdFromTranslationKey(key),
+					Code:    ValidationCodeInvalidTemplate,
+					Message: locale + ": " + err.Error(),
+				})
+			}
+		}
+	}
+
+	return problems
+}
+
+func stepIdFromTranslationKey(key string) string {
+	parts := strings.Split(key, "/")
+	if len(parts) < 2 {
+		return ""
+	}
+
+	return parts[1]
+}
@@ -0,0 +1,188 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"milestone_core/shared/formats"
+	"milestone_core/tours/audit"
+	"time"
+)
+
+// EnsureIndexes creates the indexes the flow collections rely on. A revision number is unique per flow, so a
+// publish can never write a revision twice.
+func (s Service) EnsureIndexes() error {
+	_, err := s.RevisionsCollection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "flowId", Value: 1}, {Key: "revision", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	return err
+}
+
+func (s Service) ListRevisions(workspace string, flowId string) ([]FlowRevision, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "revision", Value: -1}}).
+		SetProjection(bson.M{"flow": 0})
+	cursor, err := s.RevisionsCollection.Find(context.Background(), bson.M{"flowId": flowId, "workspaceId": workspace}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]FlowRevision, 0)
+	err = cursor.All(context.Background(), &revisions)
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (s Service) GetRevision(workspace string, flowId string, revision int) (*FlowRevision, error) {
+	var flowRevision FlowRevision
+	err := s.RevisionsCollection.FindOne(context.Background(), bson.M{
+		"flowId":      flowId,
+		"workspaceId": workspace,
+		"revision":    revision,
+	}).Decode(&flowRevision)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &flowRevision, nil
+}
+
+// DiffRevisions returns the JSON patch that turns the content of revision `from` into the content of revision `to`.
+func (s Service) DiffRevisions(workspace string, flowId string, from int, to int) ([]formats.PatchOperation, error) {
+	fromRevision, err := s.GetRevision(workspace, flowId, from)
+	if err != nil {
+		return nil, err
+	}
+	toRevision, err := s.GetRevision(workspace, flowId, to)
+	if err != nil {
+		return nil, err
+	}
+	if fromRevision == nil || toRevision == nil {
+		return nil, errors.New("revision not found")
+	}
+
+	return formats.Diff(revisionContent(*fromRevision.Flow), revisionContent(*toRevision.Flow))
+}
+
+// Rollback restores the draft to the content of an earlier revision and publishes it as a new revision,
+// so the revision history itself is never rewritten.
+func (s Service) Rollback(workspace string, flowId string, revision int, actor audit.Actor) (*FlowRevision, error) {
+	flow, err := s.Get(workspace, flowId)
+	if err != nil {
+		return nil, err
+	}
+	if flow == nil {
+		return nil, errors.New("flow not found")
+	}
+
+	targetRevision, err := s.GetRevision(workspace, flowId, revision)
+	if err != nil {
+		return nil, err
+	}
+	if targetRevision == nil {
+		return nil, errors.New("revision not found")
+	}
+
+	restoredFlow := *targetRevision.Flow
+	restoredFlow.ID = flow.ID
+	restoredFlow.WorkspaceID = flow.WorkspaceID
+	restoredFlow.PublishedRevision = flow.PublishedRevision
+	restoredFlow.Priority = flow.Priority
+	restoredFlow.Version = flow.Version
+
+	err = s.checkDependencyCycle(workspace, &restoredFlow)
+	if err != nil {
+		return nil, err
+	}
+
+	flowRevision, err := s.publishRevision(&restoredFlow, actor, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.AuditLog.Record(workspace, actor, audit.EntityTypeFlow, flowId, audit.ActionRolledBack, revisionContent(*flow), revisionContent(restoredFlow))
+	if err != nil {
+		return nil, err
+	}
+
+	return flowRevision, nil
+}
+
+func (s Service) publishRevision(flow *Flow, actor audit.Actor, rolledBackFrom int) (*FlowRevision, error) {
+	err := validationErrorOrNil(ValidateFlowForPublish(flow))
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkPublishedDependencyCycle(flow)
+	if err != nil {
+		return nil, err
+	}
+
+	before := publishState(flow)
+	flow.Live = true
+	flow.PublishedRevision++
+
+	snapshot := *flow
+	flowRevision := FlowRevision{
+		FlowID:         flow.ID.Hex(),
+		WorkspaceID:    flow.WorkspaceID,
+		Revision:       flow.PublishedRevision,
+		Flow:           &snapshot,
+		PublishedAt:    time.Now().Unix(),
+		PublishedBy:    actor.UserID,
+		RolledBackFrom: rolledBackFrom,
+	}
+
+	// The draft is saved first, its version check lets only one of concurrent publishes take the revision number
+	err = s.saveUpdatedFlow(flow)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.RevisionsCollection.InsertOne(context.Background(), flowRevision)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.PublishedCollection.ReplaceOne(context.Background(), bson.M{"_id": flow.ID}, snapshot, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.AuditLog.Record(flow.WorkspaceID, actor, audit.EntityTypeFlow, flow.ID.Hex(), audit.ActionPublished, before, publishState(flow))
+	if err != nil {
+		return nil, err
+	}
+
+	return &flowRevision, nil
+}
+
+// publishState is what publishing and unpublishing change, the content is not part of their change log entry
+func publishState(flow *Flow) map[string]any {
+	return map[string]any{"live": flow.Live, "publishedRevision": flow.PublishedRevision}
+}
+
+// revisionContent strips the publishing bookkeeping so diffs only show what authors changed.
+func revisionContent(flow Flow) Flow {
+	flow.Live = false
+	flow.PublishedRevision = 0
+	flow.Priority = 0
+	if flow.Opts.Schedule != nil {
+		schedule := *flow.Opts.Schedule
+		schedule.ActivatedAt = 0
+		schedule.DeactivatedAt = 0
+		flow.Opts.Schedule = &schedule
+	}
+
+	return flow
+}
@@ -0,0 +1,74 @@
+package flows
+
+import (
+	"errors"
+	"hash/fnv"
+)
+
+type RolloutGroup string
+
+const (
+	RolloutGroupTreatment RolloutGroup = "treatment"
+	RolloutGroupHoldout   RolloutGroup = "holdout"
+	RolloutGroupExcluded  RolloutGroup = "excluded"
+)
+
+// rolloutBuckets gives percentages a resolution of 0.01%
+const rolloutBuckets = 10000
+
+// RolloutAssignment is stored on the user state, so users keep their group across sessions
+type RolloutAssignment struct {
+	Group      RolloutGroup `json:"group" bson:"group"`
+	Bucket     int          `json:"bucket" bson:"bucket"`
+	AssignedAt int64        `json:"assignedAt" bson:"assignedAt"`
+}
+
+// RolloutBucket deterministically maps a user to a bucket of a flow. The flow id is part of the hash, so the
+// same users do not end up in the holdout of every flow.
+func RolloutBucket(externalUserId string, flowId string) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(externalUserId + ":" + flowId))
+
+	return int(hash.Sum32() % rolloutBuckets)
+}
+
+// AssignRolloutGroup splits the buckets into the holdout first, then the treatment; the remaining buckets are
+// excluded. Without a rollout percentage every user outside the holdout gets the flow.
+func AssignRolloutGroup(opts Opts, bucket int) RolloutGroup {
+	holdoutLimit := opts.HoldoutPercentage * rolloutBuckets / 100
+	if bucket < holdoutLimit {
+		return RolloutGroupHoldout
+	}
+
+	if opts.RolloutPercentage == nil {
+		return RolloutGroupTreatment
+	}
+
+	treatmentLimit := holdoutLimit + *opts.RolloutPercentage*rolloutBuckets/100
+	if bucket < treatmentLimit {
+		return RolloutGroupTreatment
+	}
+
+	return RolloutGroupExcluded
+}
+
+func HasRollout(opts Opts) bool {
+	return opts.RolloutPercentage != nil || opts.HoldoutPercentage > 0
+}
+
+func ValidateRollout(opts Opts) error {
+	if opts.HoldoutPercentage < 0 || opts.HoldoutPercentage > 100 {
+		return errors.New("holdout percentage must be between 0 and 100")
+	}
+	if opts.RolloutPercentage == nil {
+		return nil
+	}
+	if *opts.RolloutPercentage < 0 || *opts.RolloutPercentage > 100 {
+		return errors.New("rollout percentage must be between 0 and 100")
+	}
+	if *opts.RolloutPercentage+opts.HoldoutPercentage > 100 {
+		return errors.New("rollout and holdout percentages cannot exceed 100 together")
+	}
+
+	return nil
+}
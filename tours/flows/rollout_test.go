@@ -0,0 +1,50 @@
+package flows
+
+import (
+	"testing"
+)
+
+func TestAssignRolloutGroup(t *testing.T) {
+	t.Run("buckets are deterministic per user and flow", func(t *testing.T) {
+		if RolloutBucket("user_1", "flow_1") != RolloutBucket("user_1", "flow_1") {
+			t.Fatalf("Expected the same bucket for the same user and flow")
+		}
+
+		sameBuckets := 0
+		for _, userId := range []string{"user_1", "user_2", "user_3", "user_4", "user_5"} {
+			if RolloutBucket(userId, "flow_1") == RolloutBucket(userId, "flow_2") {
+				sameBuckets++
+			}
+		}
+		if sameBuckets == 5 {
+			t.Fatalf("Expected buckets to depend on the flow")
+		}
+	})
+
+	t.Run("groups follow the percentages", func(t *testing.T) {
+		rollout := 50
+		opts := Opts{RolloutPercentage: &rollout, HoldoutPercentage: 10}
+
+		counts := make(map[RolloutGroup]int)
+		for bucket := 0; bucket < rolloutBuckets; bucket++ {
+			counts[AssignRolloutGroup(opts, bucket)]++
+		}
+		if counts[RolloutGroupHoldout] != 1000 || counts[RolloutGroupTreatment] != 5000 || counts[RolloutGroupExcluded] != 4000 {
+			t.Fatalf("Unexpected group sizes: %v", counts)
+		}
+
+		if AssignRolloutGroup(Opts{HoldoutPercentage: 10}, rolloutBuckets-1) != RolloutGroupTreatment {
+			t.Fatalf("Expected users outside the holdout to get the flow without a rollout percentage")
+		}
+	})
+
+	t.Run("invalid percentages are rejected", func(t *testing.T) {
+		rollout := 95
+		if ValidateRollout(Opts{RolloutPercentage: &rollout, HoldoutPercentage: 10}) == nil {
+			t.Fatalf("Expected percentages over 100 to be invalid")
+		}
+		if ValidateRollout(Opts{HoldoutPercentage: -1}) == nil {
+			t.Fatalf("Expected negative holdout to be invalid")
+		}
+	})
+}
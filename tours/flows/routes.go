@@ -1,21 +1,37 @@
 package flows
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"io"
+	"milestone_core/identity/workspace"
 	"milestone_core/shared/awsinternal"
+	"milestone_core/shared/rest"
 	"milestone_core/shared/server"
+	"milestone_core/shared/versioning"
+	"milestone_core/tours/audit"
+	"milestone_core/tours/preview"
+	"milestone_core/tours/translations"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type FlowsResource struct {
-	FlowService Service
-	Analytics   Analytics
-	Ctx         FlowCtx
+	FlowService      Service
+	Analytics        Analytics
+	SettingsService  SettingsService
+	TemplateService  TemplateService
+	TransferService  TransferService
+	WorkspaceService workspace.Service
+	PreviewTokens    preview.Tokens
+	Ctx              FlowCtx
 }
 
 type FlowCtx struct {
@@ -29,6 +45,18 @@ func (rs FlowsResource) Routes() chi.Router {
 	//r.Use(rs.Ctx)
 
 	r.Get("/", rs.List)
+	r.Put("/priority", rs.Reorder)
+	r.Get("/settings", rs.GetSettings)
+	r.Put("/settings", rs.PutSettings)
+	r.Get("/export", rs.Export)
+	r.Post("/import", rs.Import)
+	r.Get("/dependency-graph", rs.GetDependencyGraph)
+	r.Route("/templates", func(r chi.Router) {
+		r.Get("/", rs.ListTemplates)
+		r.Post("/", rs.CreateTemplate)
+		r.Delete("/{templateId}", rs.DeleteTemplate)
+		r.Post("/{templateId}/instantiate", rs.InstantiateTemplate)
+	})
 
 	r.Route("/{id}", func(r chi.Router) {
 		r.Post("/{stepId}/media", rs.UploadMediaFile)
@@ -37,10 +65,29 @@ func (rs FlowsResource) Routes() chi.Router {
 		r.Delete("/", rs.Archive)
 		r.Put("/{stepId}", rs.UpdateStep)
 		r.Post("/capture", rs.Capture)
+		r.Post("/duplicate", rs.Duplicate)
 		r.Get("/analytics", rs.GetFlowAnalytics)
+		r.Get("/funnel", rs.GetFunnel)
 		r.Post("/publish", rs.Publish)
 		r.Post("/unpublish", rs.Unpublish)
+		r.Get("/validate", rs.Validate)
+		r.Post("/preview", rs.CreatePreviewToken)
 		r.Get("/possible-depends-on-list", rs.GetPossibleDependsOnListForFlow)
+		r.Get("/translations", rs.ExportTranslations)
+		r.Put("/translations", rs.ImportTranslations)
+		r.Route("/steps", func(r chi.Router) {
+			r.Post("/", rs.InsertStep)
+			r.Post("/reorder", rs.ReorderSteps)
+			r.Post("/delete", rs.DeleteSteps)
+			r.Post("/{stepId}/move", rs.MoveStep)
+			r.Post("/{stepId}/duplicate", rs.DuplicateStep)
+		})
+		r.Route("/revisions", func(r chi.Router) {
+			r.Get("/", rs.ListRevisions)
+			r.Get("/diff", rs.DiffRevisions)
+			r.Get("/{revision}", rs.GetRevision)
+			r.Post("/{revision}/rollback", rs.Rollback)
+		})
 	})
 
 	r.Route("/archive", func(r chi.Router) {
@@ -62,6 +109,59 @@ func (rs FlowsResource) List(w http.ResponseWriter, r *http.Request) {
 	server.SendJson(w, flows)
 }
 
+type ReorderInput struct {
+	FlowIds []string `json:"flowIds"`
+}
+
+func (rs FlowsResource) Reorder(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	var input ReorderInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	err = rs.FlowService.Reorder(workspaceId, input.FlowIds, audit.ActorFromContext(r.Context()))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, "reordered flows")
+}
+
+func (rs FlowsResource) GetSettings(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	settings, err := rs.SettingsService.Get(workspaceId)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, settings)
+}
+
+func (rs FlowsResource) PutSettings(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	var settings FlowSettings
+	err := json.NewDecoder(r.Body).Decode(&settings)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	err = rs.SettingsService.Put(workspaceId, settings)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, "updated flow settings")
+}
+
 func (rs FlowsResource) Get(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
@@ -72,6 +172,9 @@ func (rs FlowsResource) Get(w http.ResponseWriter, r *http.Request) {
 		server.SendBadRequestErrorJson(w, err)
 		return
 	}
+	if resultFlow != nil {
+		versioning.SetETag(w, resultFlow.Version)
+	}
 
 	server.SendJson(w, resultFlow)
 }
@@ -79,33 +182,48 @@ func (rs FlowsResource) Get(w http.ResponseWriter, r *http.Request) {
 func (rs FlowsResource) Update(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	version, err := versioning.IfMatch(r)
+	if err != nil {
+		sendFlowError(w, err)
+		return
+	}
 
 	var updateInput UpdateInput
-	err := json.NewDecoder(r.Body).Decode(&updateInput)
+	err = json.NewDecoder(r.Body).Decode(&updateInput)
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
 	}
 
-	err = rs.FlowService.Update(workspaceId, idParam, updateInput)
+	result, err := rs.FlowService.Update(workspaceId, idParam, updateInput, version, audit.ActorFromContext(r.Context()))
 	if err != nil {
-		server.SendBadRequestErrorJson(w, err)
+		sendFlowError(w, err)
 		return
 	}
 
-	server.SendJson(w, "updated flow with id: "+idParam)
+	versioning.SetETag(w, result.Version)
+	server.SendJson(w, result)
 }
 
 func (rs FlowsResource) UpdateStep(w http.ResponseWriter, r *http.Request) {
 	flowId := chi.URLParam(r, "id")
 	stepId := chi.URLParam(r, "stepId")
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	version, err := versioning.IfMatch(r)
+	if err != nil {
+		sendFlowError(w, err)
+		return
+	}
 
 	inputFlow, err := rs.FlowService.Get(workspaceId, flowId)
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
 	}
+	if inputFlow == nil {
+		server.SendBadRequestErrorJson(w, errors.New("flow not found"))
+		return
+	}
 
 	var updateInput Step
 	err = json.NewDecoder(r.Body).Decode(&updateInput)
@@ -114,13 +232,14 @@ func (rs FlowsResource) UpdateStep(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = rs.FlowService.UpdateStep(workspaceId, inputFlow, stepId, updateInput)
+	result, err := rs.FlowService.UpdateStep(workspaceId, inputFlow, stepId, updateInput, version, audit.ActorFromContext(r.Context()))
 	if err != nil {
-		server.SendBadRequestErrorJson(w, err)
+		sendFlowError(w, err)
 		return
 	}
 
-	server.SendJson(w, "updated flow with id: "+flowId)
+	versioning.SetETag(w, result.Version)
+	server.SendJson(w, result)
 }
 
 func (rs FlowsResource) Capture(w http.ResponseWriter, r *http.Request) {
@@ -134,12 +253,107 @@ func (rs FlowsResource) Capture(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newId, err := rs.FlowService.Capture(workspaceId, idParam, updateInput)
+	newId, err := rs.FlowService.Capture(workspaceId, idParam, updateInput, audit.ActorFromContext(r.Context()))
+	if err != nil {
+		sendFlowError(w, err)
+		return
+	}
+
+	server.SendJson(w, newId)
+}
+
+func (rs FlowsResource) Duplicate(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	var input DuplicateInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	if input.WorkspaceID != "" && input.WorkspaceID != workspaceId {
+		isMember, err := rs.WorkspaceService.IsMember(input.WorkspaceID, server.GetUserIdFromContext(r.Context()))
+		if err != nil {
+			server.SendBadRequestErrorJson(w, err)
+			return
+		}
+		if !isMember {
+			server.SendBadRequestErrorJson(w, errors.New("target workspace not found"))
+			return
+		}
+	}
+
+	newId, err := rs.FlowService.Duplicate(workspaceId, idParam, input, audit.ActorFromContext(r.Context()))
+	if err != nil {
+		sendFlowError(w, err)
+		return
+	}
+
+	server.SendJson(w, newId)
+}
+
+func (rs FlowsResource) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	templates, err := rs.TemplateService.List(workspaceId)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, templates)
+}
+
+func (rs FlowsResource) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	var input CreateTemplateInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	template, err := rs.TemplateService.CreateFromFlow(workspaceId, input)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, template)
+}
+
+func (rs FlowsResource) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	templateId := chi.URLParam(r, "templateId")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	err := rs.TemplateService.Delete(workspaceId, templateId)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, "deleted template with id: "+templateId)
+}
+
+func (rs FlowsResource) InstantiateTemplate(w http.ResponseWriter, r *http.Request) {
+	templateId := chi.URLParam(r, "templateId")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	var input InstantiateTemplateInput
+	err := json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
 	}
 
+	newId, err := rs.TemplateService.Instantiate(workspaceId, templateId, input, audit.ActorFromContext(r.Context()))
+	if err != nil {
+		sendFlowError(w, err)
+		return
+	}
+
 	server.SendJson(w, newId)
 }
 
@@ -211,24 +425,110 @@ func (rs FlowsResource) GetFlowAnalytics(w http.ResponseWriter, r *http.Request)
 	server.SendJson(w, analytics)
 }
 
+func (rs FlowsResource) GetFunnel(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	filter := FunnelFilter{Segment: r.URL.Query().Get("segment")}
+	for name, target := range map[string]*int64{"from": &filter.From, "to": &filter.To} {
+		if r.URL.Query().Get(name) == "" {
+			continue
+		}
+		value, err := strconv.ParseInt(r.URL.Query().Get(name), 10, 64)
+		if err != nil {
+			server.SendBadRequestErrorJson(w, errors.New("invalid "+name+" parameter"))
+			return
+		}
+		*target = value
+	}
+
+	flow, err := rs.FlowService.Get(workspaceId, idParam)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	funnel, err := rs.Analytics.GetFunnel(flow, filter)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, funnel)
+}
+
 func (rs FlowsResource) Publish(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
 
-	err := rs.FlowService.Publish(workspaceId, idParam)
+	revision, err := rs.FlowService.Publish(workspaceId, idParam, audit.ActorFromContext(r.Context()))
+	if err != nil {
+		sendFlowError(w, err)
+		return
+	}
+
+	server.SendJson(w, revision)
+}
+
+func (rs FlowsResource) Validate(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	result, err := rs.FlowService.Validate(workspaceId, idParam)
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
 	}
 
-	server.SendJson(w, "published flow with id: "+idParam)
+	server.SendJson(w, result)
+}
+
+type PreviewTokenInput struct {
+	TTLSeconds int64 `json:"ttlSeconds"`
+}
+
+type PreviewTokenResponse struct {
+	Token     string `json:"token"`
+	SessionID string `json:"sessionId"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// CreatePreviewToken mints a short lived token the SDK passes to show the draft of the flow to a test user
+func (rs FlowsResource) CreatePreviewToken(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	var input PreviewTokenInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil && !errors.Is(err, io.EOF) {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	flow, err := rs.FlowService.Get(workspaceId, idParam)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+	if flow == nil {
+		server.SendBadRequestErrorJson(w, errors.New("flow not found"))
+		return
+	}
+
+	token, claims, err := rs.PreviewTokens.Mint(workspaceId, idParam, time.Duration(input.TTLSeconds)*time.Second)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, PreviewTokenResponse{Token: token, SessionID: claims.SessionID, ExpiresAt: claims.ExpiresAt})
 }
 
 func (rs FlowsResource) Unpublish(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
 
-	err := rs.FlowService.UnPublish(workspaceId, idParam)
+	err := rs.FlowService.UnPublish(workspaceId, idParam, audit.ActorFromContext(r.Context()))
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
@@ -241,7 +541,7 @@ func (rs FlowsResource) Archive(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
 
-	err := rs.FlowService.Archive(workspaceId, idParam)
+	err := rs.FlowService.Archive(workspaceId, idParam, audit.ActorFromContext(r.Context()))
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
@@ -263,6 +563,18 @@ func (rs FlowsResource) GetPossibleDependsOnListForFlow(w http.ResponseWriter, r
 	server.SendJson(w, possibleDependsOnList)
 }
 
+func (rs FlowsResource) GetDependencyGraph(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	graph, err := rs.FlowService.GetDependencyGraph(workspaceId)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, graph)
+}
+
 func (rs FlowsResource) ListArchive(w http.ResponseWriter, r *http.Request) {
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
 	flows, err := rs.FlowService.ListArchivedFlows(workspaceId)
@@ -278,7 +590,7 @@ func (rs FlowsResource) RestoreFlow(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
 
-	err := rs.FlowService.RestoreFlow(workspaceId, idParam)
+	err := rs.FlowService.RestoreFlow(workspaceId, idParam, audit.ActorFromContext(r.Context()))
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
@@ -286,3 +598,280 @@ func (rs FlowsResource) RestoreFlow(w http.ResponseWriter, r *http.Request) {
 
 	server.SendJson(w, "restored flow with id: "+idParam)
 }
+
+func (rs FlowsResource) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	revisions, err := rs.FlowService.ListRevisions(workspaceId, idParam)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, revisions)
+}
+
+func (rs FlowsResource) GetRevision(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	revisionNumber, err := strconv.Atoi(chi.URLParam(r, "revision"))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, errors.New("invalid revision number"))
+		return
+	}
+
+	revision, err := rs.FlowService.GetRevision(workspaceId, idParam, revisionNumber)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+	if revision == nil {
+		server.SendBadRequestErrorJson(w, errors.New("revision not found"))
+		return
+	}
+
+	server.SendJson(w, revision)
+}
+
+func (rs FlowsResource) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, errors.New("invalid from revision"))
+		return
+	}
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, errors.New("invalid to revision"))
+		return
+	}
+
+	diff, err := rs.FlowService.DiffRevisions(workspaceId, idParam, from, to)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, diff)
+}
+
+func (rs FlowsResource) Rollback(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+
+	revisionNumber, err := strconv.Atoi(chi.URLParam(r, "revision"))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, errors.New("invalid revision number"))
+		return
+	}
+
+	revision, err := rs.FlowService.Rollback(workspaceId, idParam, revisionNumber, audit.ActorFromContext(r.Context()))
+	if err != nil {
+		sendFlowError(w, err)
+		return
+	}
+
+	server.SendJson(w, revision)
+}
+
+func (rs FlowsResource) ExportTranslations(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	format, err := translations.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	bundle, err := rs.FlowService.ExportTranslations(workspaceId, id, r.URL.Query().Get("locale"), r.URL.Query().Get("sourceLocale"))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	translations.SendBundle(w, format, *bundle)
+}
+
+func (rs FlowsResource) ImportTranslations(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	format, err := translations.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+	// Imports only merge the translations of one locale, translation tools can skip the version check
+	version, err := versioning.OptionalIfMatch(r)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	bundle, err := translations.ReadBundle(r.Body, format)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	err = rs.FlowService.ImportTranslations(workspaceId, id, version, *bundle, audit.ActorFromContext(r.Context()))
+	if err != nil {
+		sendFlowError(w, err)
+		return
+	}
+
+	server.SendJson(w, "imported "+bundle.Locale+" translations")
+}
+
+func (rs FlowsResource) Export(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	format, err := ParseTransferFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	var ids []string
+	if idsParam := r.URL.Query().Get("ids"); idsParam != "" {
+		ids = strings.Split(idsParam, ",")
+	}
+
+	export, err := rs.TransferService.Export(workspaceId, ids)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	var content bytes.Buffer
+	err = WriteExport(&content, format, *export)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	_, _ = w.Write(content.Bytes())
+}
+
+// Import creates the flows of an export, with dryRun=true it only reports what would be created
+func (rs FlowsResource) Import(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	format, err := ParseTransferFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	export, err := ReadExport(r.Body, format)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	opts := ImportOpts{
+		DryRun:    r.URL.Query().Get("dryRun") == "true",
+		DependsOn: DependsOnPolicy(r.URL.Query().Get("dependsOn")),
+	}
+	result, err := rs.TransferService.Import(workspaceId, *export, opts, audit.ActorFromContext(r.Context()))
+	if err != nil && result != nil {
+		rest.SendResponse(w, result, http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		sendFlowError(w, err)
+		return
+	}
+	if !result.Valid {
+		rest.SendResponse(w, result, http.StatusUnprocessableEntity)
+		return
+	}
+
+	server.SendJson(w, result)
+}
+
+func (rs FlowsResource) InsertStep(w http.ResponseWriter, r *http.Request) {
+	var input InsertStepInput
+	rs.stepOperation(w, r, &input, func(workspaceId string, flowId string, version int64, actor audit.Actor) (*StepOperationResult, error) {
+		return rs.FlowService.InsertStep(workspaceId, flowId, version, input, actor)
+	})
+}
+
+func (rs FlowsResource) MoveStep(w http.ResponseWriter, r *http.Request) {
+	var input MoveStepInput
+	rs.stepOperation(w, r, &input, func(workspaceId string, flowId string, version int64, actor audit.Actor) (*StepOperationResult, error) {
+		return rs.FlowService.MoveStep(workspaceId, flowId, version, chi.URLParam(r, "stepId"), input, actor)
+	})
+}
+
+func (rs FlowsResource) ReorderSteps(w http.ResponseWriter, r *http.Request) {
+	var input ReorderStepsInput
+	rs.stepOperation(w, r, &input, func(workspaceId string, flowId string, version int64, actor audit.Actor) (*StepOperationResult, error) {
+		return rs.FlowService.ReorderSteps(workspaceId, flowId, version, input, actor)
+	})
+}
+
+func (rs FlowsResource) DuplicateStep(w http.ResponseWriter, r *http.Request) {
+	rs.stepOperation(w, r, nil, func(workspaceId string, flowId string, version int64, actor audit.Actor) (*StepOperationResult, error) {
+		return rs.FlowService.DuplicateStep(workspaceId, flowId, version, chi.URLParam(r, "stepId"), actor)
+	})
+}
+
+func (rs FlowsResource) DeleteSteps(w http.ResponseWriter, r *http.Request) {
+	var input DeleteStepsInput
+	rs.stepOperation(w, r, &input, func(workspaceId string, flowId string, version int64, actor audit.Actor) (*StepOperationResult, error) {
+		return rs.FlowService.DeleteSteps(workspaceId, flowId, version, input, actor)
+	})
+}
+
+// stepOperation decodes the input when there is one, runs the operation against the version from If-Match and
+// answers with the new step order
+func (rs FlowsResource) stepOperation(w http.ResponseWriter, r *http.Request, input interface{}, operation func(workspaceId string, flowId string, version int64, actor audit.Actor) (*StepOperationResult, error)) {
+	version, err := versioning.IfMatch(r)
+	if err != nil {
+		sendFlowError(w, err)
+		return
+	}
+
+	if input != nil {
+		err = json.NewDecoder(r.Body).Decode(input)
+		if err != nil {
+			server.SendBadRequestErrorJson(w, err)
+			return
+		}
+	}
+
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	result, err := operation(workspaceId, chi.URLParam(r, "id"), version, audit.ActorFromContext(r.Context()))
+	if err != nil {
+		sendFlowError(w, err)
+		return
+	}
+
+	versioning.SetETag(w, result.Version)
+	server.SendJson(w, result)
+}
+
+// sendFlowError reports validation failures with the list of problems so the editor can highlight the steps,
+// and version conflicts with the current flow so the editor can merge
+func sendFlowError(w http.ResponseWriter, err error) {
+	if versioning.SendError(w, err) {
+		return
+	}
+
+	var validationError *ValidationError
+	if errors.As(err, &validationError) {
+		rest.SendResponse(w, struct {
+			Error    string              `json:"error"`
+			Problems []ValidationProblem `json:"problems"`
+		}{
+			Error:    validationError.Error(),
+			Problems: validationError.Problems,
+		}, http.StatusUnprocessableEntity)
+		return
+	}
+
+	server.SendBadRequestErrorJson(w, err)
+}
@@ -0,0 +1,107 @@
+package flows
+
+import (
+	"errors"
+	"time"
+)
+
+// Schedule limits when a flow is shown. StartAt and EndAt are unix timestamps that open and close the window, the
+// scheduler publishes and unpublishes the flow at these times. Days of week and hours repeat within the window and
+// are evaluated in the timezone of the workspace.
+type Schedule struct {
+	StartAt    int64          `json:"startAt,omitempty" bson:"startAt,omitempty"`
+	EndAt      int64          `json:"endAt,omitempty" bson:"endAt,omitempty"`
+	DaysOfWeek []time.Weekday `json:"daysOfWeek,omitempty" bson:"daysOfWeek,omitempty"`
+	Hours      *ScheduleHours `json:"hours,omitempty" bson:"hours,omitempty"`
+
+	// ActivatedAt and DeactivatedAt are set by the scheduler, so a flow unpublished by hand is not published again
+	ActivatedAt   int64 `json:"activatedAt,omitempty" bson:"activatedAt,omitempty"`
+	DeactivatedAt int64 `json:"deactivatedAt,omitempty" bson:"deactivatedAt,omitempty"`
+}
+
+// ScheduleHours are "HH:MM" times of day, a range ending before it starts runs over midnight
+type ScheduleHours struct {
+	From string `json:"from" bson:"from"`
+	To   string `json:"to" bson:"to"`
+}
+
+const scheduleTimeLayout = "15:04"
+
+func (s Schedule) InWindow(now time.Time) bool {
+	if s.StartAt != 0 && now.Unix() < s.StartAt {
+		return false
+	}
+	if s.EndAt != 0 && now.Unix() >= s.EndAt {
+		return false
+	}
+
+	return true
+}
+
+func (s Schedule) HasRecurrence() bool {
+	return len(s.DaysOfWeek) > 0 || s.Hours != nil
+}
+
+// MatchesRecurrence checks the days of week and hours in the given location
+func (s Schedule) MatchesRecurrence(now time.Time, location *time.Location) bool {
+	localNow := now.In(location)
+
+	if len(s.DaysOfWeek) > 0 {
+		dayMatches := false
+		for _, day := range s.DaysOfWeek {
+			if day == localNow.Weekday() {
+				dayMatches = true
+				break
+			}
+		}
+		if !dayMatches {
+			return false
+		}
+	}
+
+	if s.Hours == nil {
+		return true
+	}
+
+	from, fromErr := time.Parse(scheduleTimeLayout, s.Hours.From)
+	to, toErr := time.Parse(scheduleTimeLayout, s.Hours.To)
+	if fromErr != nil || toErr != nil {
+		return false
+	}
+
+	minuteOfDay := localNow.Hour()*60 + localNow.Minute()
+	fromMinute := from.Hour()*60 + from.Minute()
+	toMinute := to.Hour()*60 + to.Minute()
+	if fromMinute <= toMinute {
+		return minuteOfDay >= fromMinute && minuteOfDay < toMinute
+	}
+
+	return minuteOfDay >= fromMinute || minuteOfDay < toMinute
+}
+
+func ValidateSchedule(schedule Schedule) error {
+	if schedule.StartAt < 0 || schedule.EndAt < 0 {
+		return errors.New("schedule times cannot be negative")
+	}
+	if schedule.StartAt != 0 && schedule.EndAt != 0 && schedule.EndAt <= schedule.StartAt {
+		return errors.New("schedule must end after it starts")
+	}
+	for _, day := range schedule.DaysOfWeek {
+		if day < time.Sunday || day > time.Saturday {
+			return errors.New("days of week must be between 0 (sunday) and 6 (saturday)")
+		}
+	}
+	if schedule.Hours != nil {
+		if _, err := time.Parse(scheduleTimeLayout, schedule.Hours.From); err != nil {
+			return errors.New("schedule hours must be in HH:MM format")
+		}
+		if _, err := time.Parse(scheduleTimeLayout, schedule.Hours.To); err != nil {
+			return errors.New("schedule hours must be in HH:MM format")
+		}
+		if schedule.Hours.From == schedule.Hours.To {
+			return errors.New("schedule hours cannot start and end at the same time")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package flows
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	if err != nil {
+		t.Skip("timezone data not available")
+	}
+
+	// Monday 2024-07-01 08:30 UTC is 09:30 in Lisbon
+	now := time.Date(2024, time.July, 1, 8, 30, 0, 0, time.UTC)
+
+	t.Run("window", func(t *testing.T) {
+		schedule := Schedule{StartAt: now.Add(-time.Hour).Unix(), EndAt: now.Add(time.Hour).Unix()}
+		if !schedule.InWindow(now) {
+			t.Fatalf("Expected schedule to be in window")
+		}
+		if schedule.InWindow(now.Add(time.Hour)) {
+			t.Fatalf("Expected window to be closed at its end")
+		}
+	})
+
+	t.Run("days and hours in the workspace timezone", func(t *testing.T) {
+		schedule := Schedule{
+			DaysOfWeek: []time.Weekday{time.Monday, time.Tuesday},
+			Hours:      &ScheduleHours{From: "09:00", To: "17:00"},
+		}
+		if !schedule.MatchesRecurrence(now, lisbon) {
+			t.Fatalf("Expected schedule to match in Lisbon")
+		}
+		if schedule.MatchesRecurrence(now, time.UTC) {
+			t.Fatalf("Expected schedule not to match in UTC")
+		}
+
+		overnight := Schedule{Hours: &ScheduleHours{From: "22:00", To: "02:00"}}
+		if !overnight.MatchesRecurrence(time.Date(2024, time.July, 1, 1, 0, 0, 0, time.UTC), time.UTC) {
+			t.Fatalf("Expected overnight hours to match after midnight")
+		}
+	})
+
+	t.Run("invalid schedules are rejected", func(t *testing.T) {
+		invalid := []Schedule{
+			{StartAt: 200, EndAt: 100},
+			{DaysOfWeek: []time.Weekday{7}},
+			{Hours: &ScheduleHours{From: "9am", To: "17:00"}},
+		}
+		for _, schedule := range invalid {
+			if ValidateSchedule(schedule) == nil {
+				t.Fatalf("Expected %v to be invalid", schedule)
+			}
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package flows
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"log"
+	"milestone_core/tours/audit"
+	"time"
+)
+
+// schedulerActor publishes and unpublishes the scheduled flows
+var schedulerActor = audit.Actor{UserID: "scheduler"}
+
+// Scheduler publishes flows when their schedule window opens and unpublishes them when it closes
+type Scheduler struct {
+	FlowService Service
+	Interval    time.Duration
+}
+
+func (s Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := s.Run(time.Now())
+		if err != nil {
+			log.Default().Print("flow scheduler: ", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s Scheduler) Run(now time.Time) error {
+	cursor, err := s.FlowService.Collection.Find(context.Background(), bson.M{"opts.schedule": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(context.Background()) {
+		var flow Flow
+		err = cursor.Decode(&flow)
+		if err != nil {
+			return err
+		}
+
+		schedule := flow.Opts.Schedule
+		if schedule == nil {
+			continue
+		}
+		if schedule.EndAt != 0 && now.Unix() >= schedule.EndAt {
+			if schedule.DeactivatedAt == 0 {
+				s.deactivate(&flow, now)
+			}
+			continue
+		}
+		if schedule.StartAt != 0 && schedule.InWindow(now) && schedule.ActivatedAt == 0 {
+			s.activate(&flow, now)
+		}
+	}
+
+	return cursor.Err()
+}
+
+// activate claims the flow before publishing it, so several running instances publish it only once
+func (s Scheduler) activate(flow *Flow, now time.Time) {
+	claimed, err := s.claim(flow, "opts.schedule.activatedAt", now)
+	if err != nil || !claimed {
+		return
+	}
+
+	// Publishing saves the whole draft, the marker has to be part of it
+	flow.Opts.Schedule.ActivatedAt = now.Unix()
+	_, err = s.FlowService.publishRevision(flow, schedulerActor, 0)
+	if err != nil {
+		log.Default().Print("flow scheduler: could not publish flow ", flow.ID.Hex(), ": ", err)
+		s.release(flow, "opts.schedule.activatedAt")
+	}
+}
+
+func (s Scheduler) deactivate(flow *Flow, now time.Time) {
+	claimed, err := s.claim(flow, "opts.schedule.deactivatedAt", now)
+	if err != nil || !claimed || !flow.Live {
+		return
+	}
+
+	err = s.FlowService.UnPublish(flow.WorkspaceID, flow.ID.Hex(), schedulerActor)
+	if err != nil {
+		log.Default().Print("flow scheduler: could not unpublish flow ", flow.ID.Hex(), ": ", err)
+		s.release(flow, "opts.schedule.deactivatedAt")
+	}
+}
+
+func (s Scheduler) claim(flow *Flow, marker string, now time.Time) (bool, error) {
+	res, err := s.FlowService.Collection.UpdateOne(
+		context.Background(),
+		bson.M{"_id": flow.ID, marker: bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{marker: now.Unix()}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount == 1, nil
+}
+
+func (s Scheduler) release(flow *Flow, marker string) {
+	_, _ = s.FlowService.Collection.UpdateOne(context.Background(), bson.M{"_id": flow.ID}, bson.M{"$unset": bson.M{marker: ""}})
+}
@@ -8,12 +8,19 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
+	"milestone_core/shared/versioning"
+	"milestone_core/tours/audit"
+	"slices"
 )
 
 type Service struct {
-	Collection        *mongo.Collection
-	ArchiveCollection *mongo.Collection
+	Collection          *mongo.Collection
+	ArchiveCollection   *mongo.Collection
+	PublishedCollection *mongo.Collection
+	RevisionsCollection *mongo.Collection
+	AuditLog            audit.Log
 }
 
 func (s Service) Get(workspace string, id string) (*Flow, error) {
@@ -34,7 +41,7 @@ func (s Service) Get(workspace string, id string) (*Flow, error) {
 	return &flow, nil
 }
 
-func (s Service) Archive(workspace string, id string) error {
+func (s Service) Archive(workspace string, id string, actor audit.Actor) error {
 	flowID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
@@ -53,9 +60,17 @@ func (s Service) Archive(workspace string, id string) error {
 		return err
 	}
 
+	_, err = s.PublishedCollection.DeleteOne(context.Background(), bson.M{"_id": flowID})
+	if err != nil {
+		return err
+	}
+
 	_, err = s.Collection.DeleteOne(context.Background(), bson.M{"_id": flowID})
+	if err != nil {
+		return err
+	}
 
-	return err
+	return s.AuditLog.Record(workspace, actor, audit.EntityTypeFlow, id, audit.ActionArchived, flow, nil)
 }
 
 func (s Service) ListArchivedFlows(workspace string) ([]*Flow, error) {
@@ -80,7 +95,7 @@ func (s Service) ListArchivedFlows(workspace string) ([]*Flow, error) {
 	return flows, nil
 }
 
-func (s Service) RestoreFlow(workspace string, id string) error {
+func (s Service) RestoreFlow(workspace string, id string, actor audit.Actor) error {
 	flowID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
@@ -95,38 +110,23 @@ func (s Service) RestoreFlow(workspace string, id string) error {
 		return err
 	}
 
+	// The published snapshot was dropped on archive, a restored flow has to be published again
+	archivedFlow.Live = false
 	_, err = s.Collection.InsertOne(context.Background(), archivedFlow)
 	if err != nil {
 		return err
 	}
 
 	_, err = s.ArchiveCollection.DeleteOne(context.Background(), bson.M{"_id": flowID, "workspaceId": workspace})
-	return err
-}
-
-func (s Service) GetChildrenStep(workspace string, flowId string, parentStepId string, segmentId string) (*Step, error) {
-	flow, err := s.Get(workspace, flowId)
 	if err != nil {
-		return nil, err
-	}
-
-	for i := range flow.Steps {
-		if flow.Steps[i].ParentNodeId == parentStepId && (len(segmentId) == 0 || flow.Steps[i].Opts.SegmentID == segmentId) {
-			return &flow.Steps[i], nil
-		}
+		return err
 	}
 
-	return nil, nil
+	return s.AuditLog.Record(workspace, actor, audit.EntityTypeFlow, id, audit.ActionRestored, nil, archivedFlow)
 }
 
 func (s Service) GetStep(workspace string, flow *Flow, stepId string) *Step {
-	for i := range flow.Steps {
-		if flow.Steps[i].StepID == stepId {
-			return &flow.Steps[i]
-		}
-	}
-
-	return nil
+	return findStep(flow, stepId)
 }
 
 func (s Service) GetRootStep(workspace string, flowId string) (*Step, error) {
@@ -147,7 +147,8 @@ func (s Service) GetRootStep(workspace string, flowId string) (*Step, error) {
 }
 
 func (s Service) List(workspace string) ([]*Flow, error) {
-	cursor, err := s.Collection.Find(context.Background(), bson.M{})
+	findOpts := options.Find().SetSort(bson.D{{Key: "priority", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := s.Collection.Find(context.Background(), bson.M{"workspaceId": workspace}, findOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -168,28 +169,108 @@ func (s Service) List(workspace string) ([]*Flow, error) {
 	return flows, nil
 }
 
-func (s Service) Publish(workspace string, id string) error {
+// Publish freezes the current draft into a new immutable revision and makes it the one served to end users.
+func (s Service) Publish(workspace string, id string, actor audit.Actor) (*FlowRevision, error) {
 	flow, err := s.Get(workspace, id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if flow == nil {
+		return nil, errors.New("flow not found")
 	}
 
-	flow.Live = true
-	err = s.saveUpdatedFlow(flow)
-
-	return err
+	return s.publishRevision(flow, actor, 0)
 }
 
-func (s Service) UnPublish(workspace string, id string) error {
+func (s Service) UnPublish(workspace string, id string, actor audit.Actor) error {
 	flow, err := s.Get(workspace, id)
 	if err != nil {
 		return err
 	}
+	if flow == nil {
+		return errors.New("flow not found")
+	}
 
+	_, err = s.PublishedCollection.DeleteOne(context.Background(), bson.M{"_id": flow.ID})
+	if err != nil {
+		return err
+	}
+
+	before := publishState(flow)
 	flow.Live = false
 	err = s.saveUpdatedFlow(flow)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return s.AuditLog.Record(workspace, actor, audit.EntityTypeFlow, id, audit.ActionUnpublished, before, publishState(flow))
+}
+
+// Reorder sets the priority of all flows of the workspace to their position in the list. Both the draft and the published
+// snapshot are updated, so the new order applies to end users without publishing the flows again. The priority is
+// not editor content, so the versions stay the same and open editors can keep saving.
+func (s Service) Reorder(workspace string, flowIds []string, actor audit.Actor) error {
+	ids := make([]primitive.ObjectID, len(flowIds))
+	seenIds := make(map[string]bool, len(flowIds))
+	for i, flowId := range flowIds {
+		id, err := primitive.ObjectIDFromHex(flowId)
+		if err != nil {
+			return errors.New("invalid flow id: " + flowId)
+		}
+		if seenIds[flowId] {
+			return errors.New("flow id is listed more than once: " + flowId)
+		}
+		seenIds[flowId] = true
+		ids[i] = id
+	}
+
+	foundCount, err := s.Collection.CountDocuments(context.Background(), bson.M{"_id": bson.M{"$in": ids}, "workspaceId": workspace})
+	if err != nil {
+		return err
+	}
+	if int(foundCount) != len(ids) {
+		return errors.New("flow not found")
+	}
+	// A partial list would leave the other flows with priorities that clash with the new ones
+	flows, err := s.List(workspace)
+	if err != nil {
+		return err
+	}
+	if len(flows) != len(ids) {
+		return errors.New("all flows of the workspace must be listed")
+	}
+
+	models := make([]mongo.WriteModel, len(ids))
+	for i, id := range ids {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id, "workspaceId": workspace}).
+			SetUpdate(bson.M{"$set": bson.M{"priority": i}})
+	}
+
+	_, err = s.Collection.BulkWrite(context.Background(), models)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.PublishedCollection.BulkWrite(context.Background(), models)
+	if err != nil {
+		return err
+	}
+
+	for _, flow := range flows {
+		priority := slices.Index(flowIds, flow.ID.Hex())
+		if priority == flow.Priority {
+			continue
+		}
+		reordered := *flow
+		reordered.Priority = priority
+		err = s.AuditLog.Record(workspace, actor, audit.EntityTypeFlow, flow.ID.Hex(), audit.ActionUpdated, flow, reordered)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (s Service) ListLive(workspace string) ([]*Flow, error) {
@@ -214,16 +295,32 @@ func (s Service) ListLive(workspace string) ([]*Flow, error) {
 	return flows, nil
 }
 
-func (s Service) Update(workspace string, id string, updateInput UpdateInput) error {
+// Update saves the changes to the draft, problems of the draft are returned and only block publishing
+func (s Service) Update(workspace string, id string, updateInput UpdateInput, version int64, actor audit.Actor) (*SaveResult, error) {
 	flow, err := s.Get(workspace, id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if flow == nil {
+		return nil, errors.New("flow not found")
+	}
+	err = versioning.Check(version, flow.Version, flow)
+	if err != nil {
+		return nil, err
+	}
+	before, err := audit.Snapshot(flow)
+	if err != nil {
+		return nil, err
 	}
 
 	if nil != updateInput.Name {
 		flow.Name = *updateInput.Name
 	}
 
+	if nil != updateInput.Type {
+		flow.Type = *updateInput.Type
+	}
+
 	if nil != updateInput.BaseURL {
 		flow.BaseURL = *updateInput.BaseURL
 	}
@@ -257,7 +354,10 @@ func (s Service) Update(workspace string, id string, updateInput UpdateInput) er
 	}
 
 	for _, updatedStep := range updateInput.UpdatedSteps {
-		s.updateStepData(flow, &updatedStep)
+		err = s.updateStepData(flow, &updatedStep)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	for _, updatedSegment := range updateInput.Segments {
@@ -283,6 +383,10 @@ func (s Service) Update(workspace string, id string, updateInput UpdateInput) er
 
 	if nil != updateInput.DependsOn {
 		flow.Opts.DependsOn = updateInput.DependsOn
+		err = s.checkDependencyCycle(workspace, flow)
+		if err != nil {
+			return nil, err
+		}
 	}
 	if updateInput.Trigger != nil {
 		flow.Opts.Trigger = *updateInput.Trigger
@@ -290,6 +394,35 @@ func (s Service) Update(workspace string, id string, updateInput UpdateInput) er
 	if updateInput.Targeting != nil {
 		flow.Opts.Targeting = *updateInput.Targeting
 	}
+	if updateInput.Schedule != nil {
+		// Scheduler markers are kept while their time stays the same, so editing a running schedule does not
+		// publish the draft again
+		schedule := *updateInput.Schedule
+		schedule.ActivatedAt = 0
+		schedule.DeactivatedAt = 0
+		if current := flow.Opts.Schedule; current != nil {
+			if current.StartAt == schedule.StartAt {
+				schedule.ActivatedAt = current.ActivatedAt
+			}
+			if current.EndAt == schedule.EndAt {
+				schedule.DeactivatedAt = current.DeactivatedAt
+			}
+		}
+		flow.Opts.Schedule = &schedule
+		if schedule.StartAt == 0 && schedule.EndAt == 0 && !schedule.HasRecurrence() {
+			flow.Opts.Schedule = nil
+		}
+	}
+	if updateInput.Frequency != nil {
+		flow.Opts.Frequency = updateInput.Frequency.Settings
+	}
+	if updateInput.Goal != nil {
+		flow.Opts.Goal = updateInput.Goal.Goal
+	}
+	if updateInput.Rollout != nil {
+		flow.Opts.RolloutPercentage = updateInput.Rollout.RolloutPercentage
+		flow.Opts.HoldoutPercentage = updateInput.Rollout.HoldoutPercentage
+	}
 	if updateInput.FinishEffect != nil {
 		flow.Opts.FinishEffect = *updateInput.FinishEffect
 
@@ -334,31 +467,56 @@ func (s Service) Update(workspace string, id string, updateInput UpdateInput) er
 	}
 
 	err = s.saveUpdatedFlow(flow)
+	if err != nil {
+		return nil, err
+	}
 
-	return err
+	err = s.AuditLog.Record(workspace, actor, audit.EntityTypeFlow, id, audit.ActionUpdated, before, flow)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SaveResult{Version: flow.Version, Problems: ValidateFlow(flow)}, nil
 }
 
-func (s Service) UpdateStep(workspace string, flow *Flow, stepID string, updateInput Step) error {
+func (s Service) UpdateStep(workspace string, flow *Flow, stepID string, updateInput Step, version int64, actor audit.Actor) (*SaveResult, error) {
+	err := versioning.Check(version, flow.Version, flow)
+	if err != nil {
+		return nil, err
+	}
 	step := s.GetStep(workspace, flow, stepID)
 	if step == nil {
-		return errors.New("step not found")
+		return nil, errors.New("step not found")
+	}
+	before, err := audit.Snapshot(flow)
+	if err != nil {
+		return nil, err
 	}
 
 	step.Data = updateInput.Data
 	if len(updateInput.ParentNodeId) > 0 {
 		step.ParentNodeId = updateInput.ParentNodeId
 	}
-	err := s.saveUpdatedFlow(flow)
 
-	return err
+	err = s.saveUpdatedFlow(flow)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.AuditLog.Record(workspace, actor, audit.EntityTypeFlow, flow.ID.Hex(), audit.ActionUpdated, before, flow)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SaveResult{Version: flow.Version, Problems: ValidateFlow(flow)}, nil
 }
 
-func (s Service) Capture(workspace string, id string, input UpdateInput) (string, error) {
+func (s Service) Capture(workspace string, id string, input UpdateInput, actor audit.Actor) (string, error) {
 	flow := Flow{
-		Name:        *input.Name,
-		BaseURL:     *input.BaseURL,
-		WorkspaceID: workspace,
-		Steps:       input.NewSteps,
+		Name:    *input.Name,
+		BaseURL: *input.BaseURL,
+		Steps:   input.NewSteps,
+		Type:    FlowTypeTour,
 		Opts: Opts{
 			Segmentation:    false,
 			Targeting:       Targeting{},
@@ -370,12 +528,28 @@ func (s Service) Capture(workspace string, id string, input UpdateInput) (string
 		},
 	}
 
-	newId, err := s.Collection.InsertOne(context.Background(), flow)
+	if input.Type != nil {
+		flow.Type = *input.Type
+	}
+
+	return s.insertFlow(workspace, &flow, actor)
+}
+
+func (s Service) Validate(workspace string, id string) (*ValidationResult, error) {
+	flow, err := s.Get(workspace, id)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	if flow == nil {
+		return nil, errors.New("flow not found")
+	}
+
+	problems := ValidateFlowForPublish(flow)
 
-	return newId.InsertedID.(primitive.ObjectID).Hex(), nil
+	return &ValidationResult{
+		Valid:    len(problems) == 0,
+		Problems: problems,
+	}, nil
 }
 
 func (s Service) GetPossibleDependsOnListForFlow(workspace string, flowId string) ([]EssentialFlowInfo, error) {
@@ -384,19 +558,12 @@ func (s Service) GetPossibleDependsOnListForFlow(workspace string, flowId string
 		return nil, err
 	}
 
-	isFlowInDependsOnList := func(flow Flow) bool {
-		for _, dependsOnId := range flow.Opts.DependsOn {
-			if dependsOnId == flowId {
-				return true
-			}
-		}
-
-		return false
-	}
+	// Flows that already depend on this flow, directly or not, would close a cycle
+	dependents := dependentFlowIds(flows, flowId)
 
 	dependsOnList := make([]EssentialFlowInfo, 0)
 	for _, f := range flows {
-		if f.ID.Hex() != flowId && !isFlowInDependsOnList(*f) {
+		if !dependents[f.ID.Hex()] {
 			dependsOnList = append(dependsOnList, EssentialFlowInfo{
 				ID:   f.ID.Hex(),
 				Name: f.Name,
@@ -414,7 +581,7 @@ type EssentialFlowInfo struct {
 	Live bool   `json:"live"`
 }
 
-func (s Service) updateStepData(flow *Flow, updatedStep *Step) {
+func (s Service) updateStepData(flow *Flow, updatedStep *Step) error {
 	for i := range flow.Steps {
 		if flow.Steps[i].StepID == updatedStep.StepID {
 			flow.Steps[i].Data = updatedStep.Data
@@ -422,38 +589,53 @@ func (s Service) updateStepData(flow *Flow, updatedStep *Step) {
 				flow.Steps[i].ParentNodeId = updatedStep.ParentNodeId
 			}
 
-			return
+			return nil
 		}
 	}
 
 	// Create new step
-	s.createNewStep(flow, updatedStep)
+	return s.createNewStep(flow, updatedStep)
 }
 
-func (s Service) createNewStep(flow *Flow, updatedStep *Step) {
-	var rightNode *Step
-	for i := range flow.Steps {
-		if flow.Steps[i].ParentNodeId == updatedStep.ParentNodeId {
-			rightNode = &flow.Steps[i]
-			break
-		}
-	}
-
-	if rightNode != nil {
-		rightNode.ParentNodeId = updatedStep.StepID
-	}
-	flow.Steps = append(flow.Steps, Step{
-		StepID:       updatedStep.StepID,
-		ParentNodeId: updatedStep.ParentNodeId,
-		Data:         updatedStep.Data,
+// createNewStep adds the step after its parent, after a branching step it only takes the place of the child in
+// its own segment
+func (s Service) createNewStep(flow *Flow, updatedStep *Step) error {
+	return attachAfter(flow, Step{
+		StepID: updatedStep.StepID,
+		Data:   updatedStep.Data,
 		Opts: StepOpts{
 			SegmentID: updatedStep.Opts.SegmentID,
 		},
-	})
+	}, updatedStep.ParentNodeId)
 }
 
+// saveUpdatedFlow replaces the whole document, so optional fields cleared on the flow are removed as well. The
+// replace only succeeds when the flow was not saved since it was read, every save increases the version.
 func (s Service) saveUpdatedFlow(flow *Flow) error {
-	_, err := s.Collection.UpdateByID(context.Background(), flow.ID, bson.M{"$set": flow})
+	readVersion := flow.Version
+	flow.Version++
+	// The stored priority is kept, it is only changed by Reorder and an editor may have read an older one
+	replacement := bson.M{"$mergeObjects": bson.A{bson.M{"$literal": flow}, bson.M{"priority": "$priority"}}}
+	update := mongo.Pipeline{{{Key: "$replaceWith", Value: replacement}}}
+	res, err := s.Collection.UpdateOne(context.Background(), bson.M{"_id": flow.ID, "version": versioning.Filter(readVersion)}, update)
+	if err == nil && res.MatchedCount == 0 {
+		err = s.versionConflict(flow.WorkspaceID, flow.ID.Hex())
+	}
+	if err != nil {
+		flow.Version = readVersion
+	}
 
 	return err
 }
+
+func (s Service) versionConflict(workspace string, id string) error {
+	current, err := s.Get(workspace, id)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return errors.New("flow not found")
+	}
+
+	return &versioning.ConflictError{Version: current.Version, Current: current}
+}
@@ -0,0 +1,40 @@
+package flows
+
+type FlowType string
+
+const (
+	FlowTypeTour      FlowType = "tour"
+	FlowTypeChecklist FlowType = "checklist"
+	FlowTypeBanner    FlowType = "banner"
+	FlowTypeSurvey    FlowType = "survey"
+)
+
+// FlowSlot is the place in the UI a flow is shown in. Users can be in one flow per slot at the same time.
+type FlowSlot string
+
+const (
+	FlowSlotModal     FlowSlot = "modal"
+	FlowSlotChecklist FlowSlot = "checklist"
+	FlowSlotBanner    FlowSlot = "banner"
+)
+
+// Slot maps the type to its slot, flows created before types existed are tours
+func (t FlowType) Slot() FlowSlot {
+	switch t {
+	case FlowTypeChecklist:
+		return FlowSlotChecklist
+	case FlowTypeBanner:
+		return FlowSlotBanner
+	}
+
+	return FlowSlotModal
+}
+
+func (t FlowType) IsValid() bool {
+	switch t {
+	case "", FlowTypeTour, FlowTypeChecklist, FlowTypeBanner, FlowTypeSurvey:
+		return true
+	}
+
+	return false
+}
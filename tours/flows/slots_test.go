@@ -0,0 +1,50 @@
+package flows
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func TestFlowSlots(t *testing.T) {
+	t.Run("types map to slots", func(t *testing.T) {
+		if FlowType("").Slot() != FlowSlotModal {
+			t.Fatalf("Expected flows without a type to be shown in the modal slot")
+		}
+		if FlowTypeSurvey.Slot() != FlowSlotModal {
+			t.Fatalf("Expected surveys to be shown in the modal slot")
+		}
+		if FlowTypeChecklist.Slot() != FlowSlotChecklist || FlowTypeBanner.Slot() != FlowSlotBanner {
+			t.Fatalf("Expected checklists and banners to have their own slots")
+		}
+		if FlowType("popup").IsValid() {
+			t.Fatalf("Expected an unknown type to be invalid")
+		}
+	})
+
+	t.Run("current enrollments are kept per slot while live", func(t *testing.T) {
+		tour := &Flow{ID: primitive.NewObjectID()}
+		banner := &Flow{ID: primitive.NewObjectID(), Type: FlowTypeBanner}
+		unpublished := primitive.NewObjectID().Hex()
+
+		slotFlows := currentSlotFlows([]*Flow{tour, banner}, map[FlowSlot]string{
+			FlowSlotModal:     tour.ID.Hex(),
+			FlowSlotBanner:    banner.ID.Hex(),
+			FlowSlotChecklist: unpublished,
+		})
+		if slotFlows[FlowSlotModal] != tour || slotFlows[FlowSlotBanner] != banner {
+			t.Fatalf("Expected the live current flows to keep their slots, got %v", slotFlows)
+		}
+		if _, ok := slotFlows[FlowSlotChecklist]; ok {
+			t.Fatalf("Expected the slot of an unpublished flow to be free")
+		}
+	})
+
+	t.Run("a flow is only current in the slot of its type", func(t *testing.T) {
+		checklist := &Flow{ID: primitive.NewObjectID(), Type: FlowTypeChecklist}
+
+		slotFlows := currentSlotFlows([]*Flow{checklist}, map[FlowSlot]string{FlowSlotModal: checklist.ID.Hex()})
+		if len(slotFlows) != 0 {
+			t.Fatalf("Expected a flow moved to another slot to be dropped, got %v", slotFlows)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package flows
+
+import "errors"
+
+func findStep(flow *Flow, stepId string) *Step {
+	for i := range flow.Steps {
+		if flow.Steps[i].StepID == stepId {
+			return &flow.Steps[i]
+		}
+	}
+
+	return nil
+}
+
+func childSteps(flow *Flow, parentStepId string) []*Step {
+	children := make([]*Step, 0)
+	for i := range flow.Steps {
+		if flow.Steps[i].ParentNodeId == parentStepId {
+			children = append(children, &flow.Steps[i])
+		}
+	}
+
+	return children
+}
+
+func findBranchingOption(step *Step, optionId string) *BranchingOption {
+	for i := range step.Data.BranchingOptions {
+		if step.Data.BranchingOptions[i].OptionID == optionId {
+			return &step.Data.BranchingOptions[i]
+		}
+	}
+
+	return nil
+}
+
+// ResolveNextStep returns the step that follows currentStepId. Branching steps need the option chosen by the
+// end user, other steps continue with the child in their own segment, falling back to the first child.
+// A nil step means the flow ends after the current step.
+func ResolveNextStep(flow *Flow, currentStepId string, optionId string) (*Step, error) {
+	currentStep := findStep(flow, currentStepId)
+	if currentStep == nil {
+		return nil, errors.New("step not found")
+	}
+
+	children := childSteps(flow, currentStepId)
+
+	if currentStep.Data.ElementType == StepElementTypeBranching && len(currentStep.Data.BranchingOptions) > 0 {
+		if optionId == "" {
+			return nil, errors.New("branching step requires an option")
+		}
+		option := findBranchingOption(currentStep, optionId)
+		if option == nil {
+			return nil, errors.New("branching option not found")
+		}
+
+		for _, child := range children {
+			if option.NextStepID != "" && child.StepID == option.NextStepID {
+				return child, nil
+			}
+			if option.NextStepID == "" && child.Opts.SegmentID == option.SegmentID {
+				return child, nil
+			}
+		}
+
+		return nil, errors.New("branching option does not lead to a step")
+	}
+
+	if len(children) == 0 {
+		return nil, nil
+	}
+	for _, child := range children {
+		if child.Opts.SegmentID == currentStep.Opts.SegmentID {
+			return child, nil
+		}
+	}
+
+	return children[0], nil
+}
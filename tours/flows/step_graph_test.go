@@ -0,0 +1,75 @@
+package flows
+
+import (
+	"testing"
+)
+
+func TestResolveNextStep(t *testing.T) {
+	flow := Flow{
+		Segments: []Segment{{SegmentID: "admins"}, {SegmentID: "members"}},
+		Steps: []Step{
+			{StepID: "step_1"},
+			{
+				StepID:       "choice",
+				ParentNodeId: "step_1",
+				Data: StepData{
+					ElementType: StepElementTypeBranching,
+					BranchingOptions: []BranchingOption{
+						{OptionID: "admin", SegmentID: "admins"},
+						{OptionID: "member", SegmentID: "members"},
+						{OptionID: "skip", NextStepID: "members_1"},
+					},
+				},
+			},
+			{StepID: "admins_1", ParentNodeId: "choice", Opts: StepOpts{SegmentID: "admins"}},
+			{StepID: "members_1", ParentNodeId: "choice", Opts: StepOpts{SegmentID: "members"}},
+			{StepID: "members_2", ParentNodeId: "members_1", Opts: StepOpts{SegmentID: "members"}},
+		},
+	}
+
+	if problems := ValidateFlow(&flow); len(problems) != 0 {
+		t.Fatalf("Expected valid flow, got %v", problems)
+	}
+
+	t.Run("option routes into its segment", func(t *testing.T) {
+		nextStep, err := ResolveNextStep(&flow, "choice", "admin")
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if nextStep == nil || nextStep.StepID != "admins_1" {
+			t.Fatalf("Expected admins_1, got %v", nextStep)
+		}
+
+		nextStep, err = ResolveNextStep(&flow, "choice", "skip")
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if nextStep == nil || nextStep.StepID != "members_1" {
+			t.Fatalf("Expected members_1, got %v", nextStep)
+		}
+	})
+
+	t.Run("branching step requires a known option", func(t *testing.T) {
+		if _, err := ResolveNextStep(&flow, "choice", ""); err == nil {
+			t.Fatalf("Expected error for missing option")
+		}
+		if _, err := ResolveNextStep(&flow, "choice", "unknown"); err == nil {
+			t.Fatalf("Expected error for unknown option")
+		}
+	})
+
+	t.Run("regular steps continue in their segment and end on the last step", func(t *testing.T) {
+		nextStep, err := ResolveNextStep(&flow, "members_1", "")
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if nextStep == nil || nextStep.StepID != "members_2" {
+			t.Fatalf("Expected members_2, got %v", nextStep)
+		}
+
+		nextStep, err = ResolveNextStep(&flow, "members_2", "")
+		if err != nil || nextStep != nil {
+			t.Fatalf("Expected end of flow, got %v, %v", nextStep, err)
+		}
+	})
+}
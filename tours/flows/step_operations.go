@@ -0,0 +1,393 @@
+package flows
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"milestone_core/shared/versioning"
+	"milestone_core/tours/audit"
+	"slices"
+	"strings"
+)
+
+type StepPosition string
+
+const (
+	StepPositionBefore StepPosition = "before"
+	StepPositionAfter  StepPosition = "after"
+)
+
+type InsertStepInput struct {
+	Step         Step         `json:"step"`
+	AnchorStepID string       `json:"anchorStepId"`
+	Position     StepPosition `json:"position"`
+}
+
+type MoveStepInput struct {
+	// ParentStepID is the step the moved step follows, an empty id makes it the first step
+	ParentStepID string `json:"parentStepId"`
+}
+
+type ReorderStepsInput struct {
+	// StepIDs is the new order of a run of consecutive steps
+	StepIDs []string `json:"stepIds"`
+}
+
+type DeleteStepsInput struct {
+	StepIDs []string `json:"stepIds"`
+}
+
+type StepOrderEntry struct {
+	StepID       string `json:"stepId"`
+	ParentNodeId string `json:"parentNodeId,omitempty"`
+	SegmentID    string `json:"segmentId,omitempty"`
+}
+
+// StepOperationResult is the order of the steps after an operation, with the new version of the flow and the
+// problems of the saved draft
+type StepOperationResult struct {
+	Version  int64               `json:"version"`
+	Steps    []StepOrderEntry    `json:"steps"`
+	Problems []ValidationProblem `json:"problems"`
+}
+
+func (s Service) InsertStep(workspace string, id string, version int64, input InsertStepInput, actor audit.Actor) (*StepOperationResult, error) {
+	return s.applyStepOperation(workspace, id, version, actor, func(flow *Flow) error {
+		return insertStep(flow, input)
+	})
+}
+
+func (s Service) MoveStep(workspace string, id string, version int64, stepId string, input MoveStepInput, actor audit.Actor) (*StepOperationResult, error) {
+	return s.applyStepOperation(workspace, id, version, actor, func(flow *Flow) error {
+		return moveStep(flow, stepId, input.ParentStepID)
+	})
+}
+
+func (s Service) ReorderSteps(workspace string, id string, version int64, input ReorderStepsInput, actor audit.Actor) (*StepOperationResult, error) {
+	return s.applyStepOperation(workspace, id, version, actor, func(flow *Flow) error {
+		return reorderSteps(flow, input.StepIDs)
+	})
+}
+
+func (s Service) DuplicateStep(workspace string, id string, version int64, stepId string, actor audit.Actor) (*StepOperationResult, error) {
+	return s.applyStepOperation(workspace, id, version, actor, func(flow *Flow) error {
+		return duplicateStep(flow, stepId)
+	})
+}
+
+// DeleteSteps removes all the steps in one save, so either all of them are deleted or none
+func (s Service) DeleteSteps(workspace string, id string, version int64, input DeleteStepsInput, actor audit.Actor) (*StepOperationResult, error) {
+	return s.applyStepOperation(workspace, id, version, actor, func(flow *Flow) error {
+		return deleteSteps(flow, input.StepIDs)
+	})
+}
+
+// applyStepOperation runs the operation on the draft and saves it, problems of the resulting draft are returned
+func (s Service) applyStepOperation(workspace string, id string, version int64, actor audit.Actor, operation func(flow *Flow) error) (*StepOperationResult, error) {
+	flow, err := s.Get(workspace, id)
+	if err != nil {
+		return nil, err
+	}
+	if flow == nil {
+		return nil, errors.New("flow not found")
+	}
+	err = versioning.Check(version, flow.Version, flow)
+	if err != nil {
+		return nil, err
+	}
+	before, err := audit.Snapshot(flow)
+	if err != nil {
+		return nil, err
+	}
+
+	err = operation(flow)
+	if err != nil {
+		return nil, err
+	}
+	sortSteps(flow)
+
+	err = s.saveUpdatedFlow(flow)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.AuditLog.Record(workspace, actor, audit.EntityTypeFlow, id, audit.ActionUpdated, before, flow)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StepOperationResult{Version: flow.Version, Steps: StepOrder(flow), Problems: ValidateFlow(flow)}, nil
+}
+
+// StepOrder lists the steps depth first from the root, children keep their order in the flow
+func StepOrder(flow *Flow) []StepOrderEntry {
+	order := make([]StepOrderEntry, 0, len(flow.Steps))
+	for _, step := range orderedSteps(flow) {
+		order = append(order, StepOrderEntry{StepID: step.StepID, ParentNodeId: step.ParentNodeId, SegmentID: step.Opts.SegmentID})
+	}
+
+	return order
+}
+
+func orderedSteps(flow *Flow) []Step {
+	ordered := make([]Step, 0, len(flow.Steps))
+	visited := make(map[string]bool, len(flow.Steps))
+	var visit func(parentId string)
+	visit = func(parentId string) {
+		for _, child := range childSteps(flow, parentId) {
+			if visited[child.StepID] {
+				continue
+			}
+			visited[child.StepID] = true
+			ordered = append(ordered, *child)
+			visit(child.StepID)
+		}
+	}
+	visit("")
+
+	// Steps that are not connected to the root are kept at the end, the validator reports them
+	for _, step := range flow.Steps {
+		if !visited[step.StepID] {
+			ordered = append(ordered, step)
+		}
+	}
+
+	return ordered
+}
+
+func sortSteps(flow *Flow) {
+	flow.Steps = orderedSteps(flow)
+}
+
+func insertStep(flow *Flow, input InsertStepInput) error {
+	anchor := findStep(flow, input.AnchorStepID)
+	if anchor == nil {
+		return errors.New("anchor step not found")
+	}
+
+	step := input.Step
+	if step.StepID == "" {
+		step.StepID = uuid.New().String()
+	}
+	if findStep(flow, step.StepID) != nil {
+		return errors.New("step id is already used: " + step.StepID)
+	}
+
+	switch input.Position {
+	case StepPositionBefore:
+		if step.Opts.SegmentID == "" {
+			step.Opts.SegmentID = anchor.Opts.SegmentID
+		}
+		step.ParentNodeId = anchor.ParentNodeId
+		anchorId := anchor.StepID
+		anchor.ParentNodeId = step.StepID
+		redirectBranchingOptions(flow, anchorId, step.StepID)
+		flow.Steps = append(flow.Steps, step)
+		return nil
+	case StepPositionAfter:
+		return attachAfter(flow, step, anchor.StepID)
+	}
+
+	return errors.New("position must be before or after")
+}
+
+// attachAfter adds the step as the child of the parent and moves the children of the parent below it. After a
+// branching step only the child in the segment of the step moves.
+func attachAfter(flow *Flow, step Step, parentId string) error {
+	parent := findStep(flow, parentId)
+	if parent == nil && parentId != "" {
+		return errors.New("parent step not found")
+	}
+	if parent != nil && parent.Data.ElementType != StepElementTypeBranching && step.Opts.SegmentID == "" {
+		step.Opts.SegmentID = parent.Opts.SegmentID
+	}
+
+	followingSteps := childSteps(flow, parentId)
+	if len(followingSteps) > 1 {
+		if step.Opts.SegmentID == "" {
+			return errors.New("a step after a branching step needs a segment")
+		}
+		followingSteps = slices.DeleteFunc(followingSteps, func(child *Step) bool { return child.Opts.SegmentID != step.Opts.SegmentID })
+	}
+
+	step.ParentNodeId = parentId
+	for _, following := range followingSteps {
+		following.ParentNodeId = step.StepID
+		redirectBranchingOptions(flow, following.StepID, step.StepID)
+	}
+	flow.Steps = append(flow.Steps, step)
+
+	return nil
+}
+
+// detachStep takes the step out of the graph, its children continue from its parent
+func detachStep(flow *Flow, stepId string) (Step, error) {
+	index := slices.IndexFunc(flow.Steps, func(step Step) bool { return step.StepID == stepId })
+	if index == -1 {
+		return Step{}, errors.New("step not found: " + stepId)
+	}
+
+	step := flow.Steps[index]
+	children := childSteps(flow, stepId)
+	if step.ParentNodeId == "" && len(children) > 1 {
+		return Step{}, errors.New("the first step can not be removed while it has several following steps")
+	}
+	for _, child := range children {
+		child.ParentNodeId = step.ParentNodeId
+	}
+
+	nextStepId := ""
+	if len(children) == 1 {
+		nextStepId = children[0].StepID
+	}
+	redirectBranchingOptions(flow, stepId, nextStepId)
+	flow.Steps = slices.Delete(flow.Steps, index, index+1)
+
+	return step, nil
+}
+
+func moveStep(flow *Flow, stepId string, parentStepId string) error {
+	if stepId == parentStepId {
+		return errors.New("a step can not follow itself")
+	}
+	if parentStepId != "" && findStep(flow, parentStepId) == nil {
+		return errors.New("parent step not found")
+	}
+
+	step, err := detachStep(flow, stepId)
+	if err != nil {
+		return err
+	}
+
+	// The segment only carries over when the step stays right after a branching step
+	parent := findStep(flow, parentStepId)
+	if parent == nil || parent.Data.ElementType != StepElementTypeBranching {
+		step.Opts.SegmentID = ""
+	}
+
+	return attachAfter(flow, step, parentStepId)
+}
+
+// reorderSteps puts a run of consecutive steps into the given order. The run must be a single path without
+// branching inside it, the steps that followed the run follow its new last step.
+func reorderSteps(flow *Flow, stepIds []string) error {
+	if len(stepIds) < 2 {
+		return errors.New("at least two steps are needed to reorder")
+	}
+	inRun := make(map[string]bool, len(stepIds))
+	for _, stepId := range stepIds {
+		if findStep(flow, stepId) == nil {
+			return errors.New("step not found: " + stepId)
+		}
+		if inRun[stepId] {
+			return errors.New("step is listed more than once: " + stepId)
+		}
+		inRun[stepId] = true
+	}
+
+	var first *Step
+	for _, stepId := range stepIds {
+		step := findStep(flow, stepId)
+		if !inRun[step.ParentNodeId] {
+			if first != nil {
+				return errors.New("the steps are not consecutive")
+			}
+			first = step
+		}
+	}
+	if first == nil {
+		return errors.New("the steps are not consecutive")
+	}
+
+	// Walk the current path to find the last step of the run
+	last := first
+	for length := 1; length < len(stepIds); length++ {
+		children := childSteps(flow, last.StepID)
+		if len(children) != 1 || !inRun[children[0].StepID] {
+			return errors.New("the steps are not consecutive or branch inside the run")
+		}
+		last = children[0]
+	}
+
+	runParentId := first.ParentNodeId
+	firstId, lastId := first.StepID, last.StepID
+	following := childSteps(flow, lastId)
+
+	for i, stepId := range stepIds {
+		step := findStep(flow, stepId)
+		if i == 0 {
+			step.ParentNodeId = runParentId
+		} else {
+			step.ParentNodeId = stepIds[i-1]
+		}
+	}
+	newLastId := stepIds[len(stepIds)-1]
+	for _, step := range following {
+		step.ParentNodeId = newLastId
+	}
+	redirectBranchingOptions(flow, firstId, stepIds[0])
+
+	return nil
+}
+
+// duplicateStep inserts a copy of the step with a new id right after it
+func duplicateStep(flow *Flow, stepId string) error {
+	step := findStep(flow, stepId)
+	if step == nil {
+		return errors.New("step not found")
+	}
+	if step.Data.ElementType == StepElementTypeBranching {
+		return errors.New("branching steps can not be duplicated")
+	}
+
+	single := &Flow{Steps: []Step{*step}}
+	copied := mapFlowContent(single, func(key string, content string) string { return content }).Steps[0]
+	copied.StepID = uuid.New().String()
+	copied.Opts.IsSource = false
+
+	for locale, values := range flow.Translations {
+		for key, value := range values {
+			if strings.HasPrefix(key, translationKey(stepId)) {
+				flow.Translations[locale][translationKey(copied.StepID)+strings.TrimPrefix(key, translationKey(stepId))] = value
+			}
+		}
+	}
+
+	return attachAfter(flow, copied, stepId)
+}
+
+// deleteSteps removes the steps, the steps that followed them continue from the closest remaining step
+func deleteSteps(flow *Flow, stepIds []string) error {
+	if len(stepIds) == 0 {
+		return errors.New("no steps to delete")
+	}
+
+	for _, stepId := range stepIds {
+		_, err := detachStep(flow, stepId)
+		if err != nil {
+			return err
+		}
+
+		for locale, values := range flow.Translations {
+			for key := range values {
+				if strings.HasPrefix(key, translationKey(stepId)) {
+					delete(flow.Translations[locale], key)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// redirectBranchingOptions points branching options that lead to a step at its replacement, an empty
+// replacement falls back to the segment of the option
+func redirectBranchingOptions(flow *Flow, stepId string, replacementId string) {
+	for i := range flow.Steps {
+		for j := range flow.Steps[i].Data.BranchingOptions {
+			option := &flow.Steps[i].Data.BranchingOptions[j]
+			if option.NextStepID == stepId {
+				option.NextStepID = replacementId
+			}
+		}
+	}
+}
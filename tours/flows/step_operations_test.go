@@ -0,0 +1,132 @@
+package flows
+
+import (
+	"strings"
+	"testing"
+)
+
+func linearFlow(ids ...string) *Flow {
+	flow := &Flow{Translations: map[string]map[string]string{"de": {}}}
+	parent := ""
+	for _, id := range ids {
+		flow.Steps = append(flow.Steps, Step{StepID: id, ParentNodeId: parent})
+		flow.Translations["de"]["steps/"+id+"/actionText"] = "Weiter " + id
+		parent = id
+	}
+
+	return flow
+}
+
+func stepOrderIds(flow *Flow) string {
+	ids := make([]string, 0, len(flow.Steps))
+	for _, entry := range StepOrder(flow) {
+		ids = append(ids, entry.StepID)
+	}
+
+	return strings.Join(ids, ",")
+}
+
+func TestStepOperations(t *testing.T) {
+	t.Run("insert before and after keeps the chain", func(t *testing.T) {
+		flow := linearFlow("a", "b", "c")
+		err := insertStep(flow, InsertStepInput{Step: Step{StepID: "x"}, AnchorStepID: "b", Position: StepPositionBefore})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		err = insertStep(flow, InsertStepInput{Step: Step{StepID: "y"}, AnchorStepID: "c", Position: StepPositionAfter})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if order := stepOrderIds(flow); order != "a,x,b,c,y" {
+			t.Fatalf("Expected a,x,b,c,y, got %s", order)
+		}
+	})
+
+	t.Run("move puts the step after the new parent", func(t *testing.T) {
+		flow := linearFlow("a", "b", "c", "d")
+		err := moveStep(flow, "b", "c")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if order := stepOrderIds(flow); order != "a,c,b,d" {
+			t.Fatalf("Expected a,c,b,d, got %s", order)
+		}
+
+		err = moveStep(flow, "d", "")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if order := stepOrderIds(flow); order != "d,a,c,b" {
+			t.Fatalf("Expected d,a,c,b, got %s", order)
+		}
+	})
+
+	t.Run("reorder rewires a consecutive run", func(t *testing.T) {
+		flow := linearFlow("a", "b", "c", "d")
+		err := reorderSteps(flow, []string{"c", "a", "b"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if order := stepOrderIds(flow); order != "c,a,b,d" {
+			t.Fatalf("Expected c,a,b,d, got %s", order)
+		}
+
+		err = reorderSteps(flow, []string{"c", "d"})
+		if err == nil {
+			t.Fatalf("Expected an error for steps that are not consecutive")
+		}
+	})
+
+	t.Run("duplicate copies the step and its translations", func(t *testing.T) {
+		flow := linearFlow("a", "b")
+		err := duplicateStep(flow, "a")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		order := StepOrder(flow)
+		if len(order) != 3 || order[1].ParentNodeId != "a" || order[2].StepID != "b" {
+			t.Fatalf("Expected the copy between a and b, got %v", order)
+		}
+		if flow.Translations["de"]["steps/"+order[1].StepID+"/actionText"] != "Weiter a" {
+			t.Fatalf("Expected the translations to be copied, got %v", flow.Translations)
+		}
+	})
+
+	t.Run("delete reconnects the remaining steps and fixes branching options", func(t *testing.T) {
+		flow := &Flow{Steps: []Step{
+			{StepID: "a", Data: StepData{ElementType: StepElementTypeBranching, BranchingOptions: []BranchingOption{
+				{OptionID: "yes", SegmentID: "s1", NextStepID: "b"},
+				{OptionID: "no", SegmentID: "s2", NextStepID: "d"},
+			}}},
+			{StepID: "b", ParentNodeId: "a", Opts: StepOpts{SegmentID: "s1"}},
+			{StepID: "c", ParentNodeId: "b", Opts: StepOpts{SegmentID: "s1"}},
+			{StepID: "d", ParentNodeId: "a", Opts: StepOpts{SegmentID: "s2"}},
+		}}
+		err := deleteSteps(flow, []string{"b", "d"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if order := stepOrderIds(flow); order != "a,c" {
+			t.Fatalf("Expected a,c, got %s", order)
+		}
+		options := findStep(flow, "a").Data.BranchingOptions
+		if options[0].NextStepID != "c" || options[1].NextStepID != "" {
+			t.Fatalf("Expected the options to follow the remaining steps, got %v", options)
+		}
+	})
+
+	t.Run("steps added in an update only move the child in their segment", func(t *testing.T) {
+		flow := &Flow{Steps: []Step{
+			{StepID: "branch", Data: StepData{ElementType: StepElementTypeBranching}},
+			{StepID: "left", ParentNodeId: "branch", Opts: StepOpts{SegmentID: "s1"}},
+			{StepID: "right", ParentNodeId: "branch", Opts: StepOpts{SegmentID: "s2"}},
+		}}
+		err := Service{}.updateStepData(flow, &Step{StepID: "new", ParentNodeId: "branch", Opts: StepOpts{SegmentID: "s2"}})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if left, right := findStep(flow, "left"), findStep(flow, "right"); left.ParentNodeId != "branch" || right.ParentNodeId != "new" {
+			t.Fatalf("Expected only the s2 child to follow the new step, got %+v", flow.Steps)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package flows
+
+import (
+	"errors"
+	"strconv"
+)
+
+const (
+	defaultRatingScale = 5
+	maxRatingScale     = 10
+)
+
+// SurveyBlock holds the question of a survey block, choices are only used by the choice types
+type SurveyBlock struct {
+	Question string         `json:"question" bson:"question"`
+	Required bool           `json:"required,omitempty" bson:"required,omitempty"`
+	Scale    int            `json:"scale,omitempty" bson:"scale,omitempty"`
+	Choices  []SurveyChoice `json:"choices,omitempty" bson:"choices,omitempty"`
+}
+
+type SurveyChoice struct {
+	ChoiceID string `json:"choiceId" bson:"choiceId"`
+	Label    string `json:"label" bson:"label"`
+}
+
+func (t StepBlockType) IsSurvey() bool {
+	switch t {
+	case StepBlockTypeNPS, StepBlockTypeRating, StepBlockTypeSingleChoice, StepBlockTypeMultiChoice, StepBlockTypeFreeText:
+		return true
+	}
+
+	return false
+}
+
+// RatingScale is the highest rating of a rating block, ratings start at 1
+func (b SurveyBlock) RatingScale() int {
+	if b.Scale == 0 {
+		return defaultRatingScale
+	}
+
+	return b.Scale
+}
+
+func (b SurveyBlock) HasChoice(choiceId string) bool {
+	for _, choice := range b.Choices {
+		if choice.ChoiceID == choiceId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ValidateSurveyBlock(block StepBlock) error {
+	if block.Survey == nil || block.Survey.Question == "" {
+		return errors.New("survey block requires a question")
+	}
+
+	switch block.Type {
+	case StepBlockTypeRating:
+		scale := block.Survey.RatingScale()
+		if scale < 2 || scale > maxRatingScale {
+			return errors.New("rating scale must be between 2 and " + strconv.Itoa(maxRatingScale))
+		}
+	case StepBlockTypeSingleChoice, StepBlockTypeMultiChoice:
+		if len(block.Survey.Choices) < 2 {
+			return errors.New("choice block requires at least two choices")
+		}
+		choiceIds := make(map[string]bool, len(block.Survey.Choices))
+		for _, choice := range block.Survey.Choices {
+			if choice.ChoiceID == "" || choice.Label == "" {
+				return errors.New("choices require an id and a label")
+			}
+			if choiceIds[choice.ChoiceID] {
+				return errors.New("choice id is used more than once: " + choice.ChoiceID)
+			}
+			choiceIds[choice.ChoiceID] = true
+		}
+	}
+
+	return nil
+}
+
+func validateSurveyBlocks(flow *Flow) []ValidationProblem {
+	problems := make([]ValidationProblem, 0)
+	for _, step := range flow.Steps {
+		for _, block := range step.Data.Blocks {
+			if !block.Type.IsSurvey() {
+				continue
+			}
+			if err := ValidateSurveyBlock(block); err != nil {
+				problems = append(problems, ValidationProblem{
+					StepID:  step.StepID,
+					Code:    ValidationCodeInvalidSurveyBlock,
+					Message: block.BlockID + ": " + err.Error(),
+				})
+			}
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,252 @@
+package flows
+
+import (
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type TargetingOperator string
+
+const (
+	TargetingOperatorAnd      TargetingOperator = "and"
+	TargetingOperatorOr       TargetingOperator = "or"
+	TargetingOperatorEq       TargetingOperator = "eq"
+	TargetingOperatorNeq      TargetingOperator = "neq"
+	TargetingOperatorIn       TargetingOperator = "in"
+	TargetingOperatorContains TargetingOperator = "contains"
+	TargetingOperatorGt       TargetingOperator = "gt"
+	TargetingOperatorLt       TargetingOperator = "lt"
+	TargetingOperatorExists   TargetingOperator = "exists"
+	TargetingOperatorRegex    TargetingOperator = "regex"
+)
+
+// TargetingExpression is either a group (and/or) of nested expressions or a single comparison of a user
+// attribute with a value. Attributes are dotted paths into the targeting attributes of the enrolled user,
+// e.g. "plan", "billing.mrr" or "user.email" for the built-in fields.
+type TargetingExpression struct {
+	Operator   TargetingOperator     `json:"operator" bson:"operator"`
+	Conditions []TargetingExpression `json:"conditions,omitempty" bson:"conditions,omitempty"`
+	Attribute  string                `json:"attribute,omitempty" bson:"attribute,omitempty"`
+	Value      any                   `json:"value,omitempty" bson:"value,omitempty"`
+}
+
+// EvaluateTargeting reports whether the attributes satisfy the expression. An empty group matches everyone.
+func EvaluateTargeting(expression TargetingExpression, attributes map[string]any) bool {
+	switch expression.Operator {
+	case TargetingOperatorAnd:
+		for _, condition := range expression.Conditions {
+			if !EvaluateTargeting(condition, attributes) {
+				return false
+			}
+		}
+		return true
+	case TargetingOperatorOr:
+		if len(expression.Conditions) == 0 {
+			return true
+		}
+		for _, condition := range expression.Conditions {
+			if EvaluateTargeting(condition, attributes) {
+				return true
+			}
+		}
+		return false
+	}
+
+	actual, found := lookupAttribute(attributes, expression.Attribute)
+
+	switch expression.Operator {
+	case TargetingOperatorExists:
+		expected := true
+		if value, ok := expression.Value.(bool); ok {
+			expected = value
+		}
+		return (found && actual != nil) == expected
+	case TargetingOperatorEq:
+		return found && valuesEqual(actual, expression.Value)
+	case TargetingOperatorNeq:
+		return !found || !valuesEqual(actual, expression.Value)
+	case TargetingOperatorIn:
+		options, ok := asSlice(expression.Value)
+		if !found || !ok {
+			return false
+		}
+		for _, option := range options {
+			if valuesEqual(actual, option) {
+				return true
+			}
+		}
+		return false
+	case TargetingOperatorContains:
+		if !found {
+			return false
+		}
+		if items, ok := asSlice(actual); ok {
+			for _, item := range items {
+				if valuesEqual(item, expression.Value) {
+					return true
+				}
+			}
+			return false
+		}
+		actualString, ok := actual.(string)
+		expectedString, expectedOk := expression.Value.(string)
+		return ok && expectedOk && strings.Contains(actualString, expectedString)
+	case TargetingOperatorGt, TargetingOperatorLt:
+		actualNumber, ok := asComparableNumber(actual)
+		expectedNumber, expectedOk := asComparableNumber(expression.Value)
+		if !found || !ok || !expectedOk {
+			return false
+		}
+		if expression.Operator == TargetingOperatorGt {
+			return actualNumber > expectedNumber
+		}
+		return actualNumber < expectedNumber
+	case TargetingOperatorRegex:
+		actualString, ok := actual.(string)
+		pattern, patternOk := expression.Value.(string)
+		if !found || !ok || !patternOk {
+			return false
+		}
+		matched, err := regexp.MatchString(pattern, actualString)
+		return err == nil && matched
+	}
+
+	return false
+}
+
+// ValidateTargeting checks operators, required attributes and regular expressions before a flow is saved.
+func ValidateTargeting(expression TargetingExpression) error {
+	switch expression.Operator {
+	case TargetingOperatorAnd, TargetingOperatorOr:
+		for _, condition := range expression.Conditions {
+			if err := ValidateTargeting(condition); err != nil {
+				return err
+			}
+		}
+		return nil
+	case TargetingOperatorEq, TargetingOperatorNeq, TargetingOperatorContains, TargetingOperatorExists:
+	case TargetingOperatorIn:
+		if _, ok := asSlice(expression.Value); !ok {
+			return fmt.Errorf("operator in on %s requires a list of values", expression.Attribute)
+		}
+	case TargetingOperatorGt, TargetingOperatorLt:
+		if _, ok := asComparableNumber(expression.Value); !ok {
+			return fmt.Errorf("operator %s on %s requires a number", expression.Operator, expression.Attribute)
+		}
+	case TargetingOperatorRegex:
+		pattern, ok := expression.Value.(string)
+		if !ok {
+			return fmt.Errorf("operator regex on %s requires a pattern", expression.Attribute)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid regex on %s: %s", expression.Attribute, err)
+		}
+	default:
+		return errors.New("unknown targeting operator: " + string(expression.Operator))
+	}
+
+	if expression.Attribute == "" {
+		return fmt.Errorf("operator %s requires an attribute", expression.Operator)
+	}
+
+	return nil
+}
+
+func lookupAttribute(attributes map[string]any, path string) (any, bool) {
+	if value, ok := attributes[path]; ok {
+		return value, true
+	}
+
+	var current any = attributes
+	for _, key := range strings.Split(path, ".") {
+		currentMap, ok := asMap(current)
+		if !ok {
+			return nil, false
+		}
+		current, ok = currentMap[key]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+func valuesEqual(actual any, expected any) bool {
+	actualNumber, actualIsNumber := asNumber(actual)
+	expectedNumber, expectedIsNumber := asNumber(expected)
+	if actualIsNumber && expectedIsNumber {
+		return actualNumber == expectedNumber
+	}
+
+	return reflect.DeepEqual(actual, expected)
+}
+
+func asNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+
+	return 0, false
+}
+
+// asComparableNumber also accepts numeric strings, so attributes sent as text can still be compared with gt/lt
+func asComparableNumber(value any) (float64, bool) {
+	if text, ok := value.(string); ok {
+		number, err := strconv.ParseFloat(text, 64)
+		return number, err == nil
+	}
+
+	return asNumber(value)
+}
+
+// asSlice accepts any slice type, values decoded from mongo come as primitive.A instead of []any
+func asSlice(value any) ([]any, bool) {
+	if _, isDocument := value.(primitive.D); isDocument {
+		return nil, false
+	}
+
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() != reflect.Slice {
+		return nil, false
+	}
+
+	items := make([]any, reflected.Len())
+	for i := range items {
+		items[i] = reflected.Index(i).Interface()
+	}
+
+	return items, true
+}
+
+func asMap(value any) (map[string]any, bool) {
+	if document, ok := value.(primitive.D); ok {
+		return document.Map(), true
+	}
+
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() != reflect.Map || reflected.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+
+	items := make(map[string]any, reflected.Len())
+	for _, key := range reflected.MapKeys() {
+		items[key.String()] = reflected.MapIndex(key).Interface()
+	}
+
+	return items, true
+}
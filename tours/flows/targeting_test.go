@@ -0,0 +1,85 @@
+package flows
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func TestEvaluateTargeting(t *testing.T) {
+	attributes := map[string]any{
+		"role":    "admin",
+		"plan":    "pro",
+		"mrr":     int64(120),
+		"country": "PT",
+		"tags":    primitive.A{"beta", "early"},
+		"billing": map[string]any{"seats": 5},
+		"user":    map[string]any{"email": "jane@acme.io"},
+	}
+
+	t.Run("admins on the pro plan", func(t *testing.T) {
+		expression := TargetingExpression{
+			Operator: TargetingOperatorAnd,
+			Conditions: []TargetingExpression{
+				{Operator: TargetingOperatorEq, Attribute: "role", Value: "admin"},
+				{Operator: TargetingOperatorEq, Attribute: "plan", Value: "pro"},
+			},
+		}
+		if !EvaluateTargeting(expression, attributes) {
+			t.Fatalf("Expected admin on pro plan to match")
+		}
+
+		attributes["plan"] = "free"
+		defer func() { attributes["plan"] = "pro" }()
+		if EvaluateTargeting(expression, attributes) {
+			t.Fatalf("Expected admin on free plan not to match")
+		}
+	})
+
+	t.Run("nested groups and operators", func(t *testing.T) {
+		expression := TargetingExpression{
+			Operator: TargetingOperatorOr,
+			Conditions: []TargetingExpression{
+				{Operator: TargetingOperatorIn, Attribute: "country", Value: []any{"US", "CA"}},
+				{
+					Operator: TargetingOperatorAnd,
+					Conditions: []TargetingExpression{
+						{Operator: TargetingOperatorGt, Attribute: "mrr", Value: 100},
+						{Operator: TargetingOperatorLt, Attribute: "billing.seats", Value: float64(10)},
+						{Operator: TargetingOperatorContains, Attribute: "tags", Value: "beta"},
+						{Operator: TargetingOperatorRegex, Attribute: "user.email", Value: "@acme\\.io$"},
+						{Operator: TargetingOperatorExists, Attribute: "deletedAt", Value: false},
+						{Operator: TargetingOperatorNeq, Attribute: "role", Value: "viewer"},
+					},
+				},
+			},
+		}
+		if err := ValidateTargeting(expression); err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if !EvaluateTargeting(expression, attributes) {
+			t.Fatalf("Expected expression to match")
+		}
+	})
+
+	t.Run("missing attributes do not match comparisons", func(t *testing.T) {
+		for _, operator := range []TargetingOperator{TargetingOperatorEq, TargetingOperatorGt, TargetingOperatorContains, TargetingOperatorExists} {
+			if EvaluateTargeting(TargetingExpression{Operator: operator, Attribute: "missing", Value: "x"}, attributes) {
+				t.Fatalf("Expected %s on missing attribute not to match", operator)
+			}
+		}
+	})
+
+	t.Run("invalid expressions are rejected", func(t *testing.T) {
+		invalid := []TargetingExpression{
+			{Operator: "between", Attribute: "mrr"},
+			{Operator: TargetingOperatorRegex, Attribute: "email", Value: "("},
+			{Operator: TargetingOperatorIn, Attribute: "plan", Value: "pro"},
+			{Operator: TargetingOperatorEq, Value: "pro"},
+		}
+		for _, expression := range invalid {
+			if ValidateTargeting(expression) == nil {
+				t.Fatalf("Expected %v to be invalid", expression)
+			}
+		}
+	})
+}
@@ -0,0 +1,273 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"milestone_core/tours/audit"
+	"regexp"
+	"slices"
+	"time"
+)
+
+var placeholderPattern = regexp.MustCompile(`\[\[\s*([A-Za-z0-9_]+)\s*\]\]`)
+
+// FlowTemplate is a flow that new flows are created from. System templates are shared by all workspaces, the
+// other templates belong to one workspace. Content can use placeholders like "[[product_name]]".
+type FlowTemplate struct {
+	ID           primitive.ObjectID    `json:"id" bson:"_id,omitempty"`
+	WorkspaceID  string                `json:"-" bson:"workspaceId,omitempty"`
+	System       bool                  `json:"system" bson:"system"`
+	Name         string                `json:"name" bson:"name"`
+	Description  string                `json:"description,omitempty" bson:"description,omitempty"`
+	Category     string                `json:"category,omitempty" bson:"category,omitempty"`
+	Placeholders []TemplatePlaceholder `json:"placeholders" bson:"placeholders"`
+	Flow         Flow                  `json:"flow" bson:"flow"`
+	Created      int64                 `json:"created" bson:"created"`
+}
+
+type TemplatePlaceholder struct {
+	Key      string `json:"key" bson:"key"`
+	Label    string `json:"label" bson:"label"`
+	Default  string `json:"default,omitempty" bson:"default,omitempty"`
+	Required bool   `json:"required,omitempty" bson:"required,omitempty"`
+}
+
+type CreateTemplateInput struct {
+	FlowID       string                `json:"flowId"`
+	Name         string                `json:"name"`
+	Description  string                `json:"description,omitempty"`
+	Category     string                `json:"category,omitempty"`
+	Placeholders []TemplatePlaceholder `json:"placeholders,omitempty"`
+}
+
+type InstantiateTemplateInput struct {
+	Name   *string           `json:"name,omitempty"`
+	Values map[string]string `json:"values,omitempty"`
+}
+
+type TemplateService struct {
+	Collection  *mongo.Collection
+	FlowService Service
+}
+
+// List returns the system templates and the templates of the workspace
+func (s TemplateService) List(workspace string) ([]FlowTemplate, error) {
+	findOpts := options.Find().SetSort(bson.D{{Key: "system", Value: -1}, {Key: "name", Value: 1}})
+	cursor, err := s.Collection.Find(context.Background(), s.visibleFilter(workspace), findOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make([]FlowTemplate, 0)
+	if err = cursor.All(context.Background(), &templates); err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+func (s TemplateService) Get(workspace string, id string) (*FlowTemplate, error) {
+	templateId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := s.visibleFilter(workspace)
+	filter["_id"] = templateId
+	var template FlowTemplate
+	err = s.Collection.FindOne(context.Background(), filter).Decode(&template)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errors.New("template not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+// CreateFromFlow saves the draft of the flow as a template of the workspace
+func (s TemplateService) CreateFromFlow(workspace string, input CreateTemplateInput) (*FlowTemplate, error) {
+	flow, err := s.FlowService.Get(workspace, input.FlowID)
+	if err != nil {
+		return nil, err
+	}
+	if flow == nil {
+		return nil, errors.New("flow not found")
+	}
+
+	template := FlowTemplate{
+		WorkspaceID:  workspace,
+		Name:         input.Name,
+		Description:  input.Description,
+		Category:     input.Category,
+		Placeholders: input.Placeholders,
+		Flow:         *copyFlow(flow),
+		Created:      time.Now().Unix(),
+	}
+	if template.Name == "" {
+		template.Name = flow.Name
+	}
+	if template.Placeholders == nil {
+		template.Placeholders = []TemplatePlaceholder{}
+	}
+	template.Flow.WorkspaceID = ""
+	template.Flow.Priority = 0
+	template.Flow.Opts.DependsOn = nil
+
+	err = ValidateTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.Collection.InsertOne(context.Background(), template)
+	if err != nil {
+		return nil, err
+	}
+	template.ID = result.InsertedID.(primitive.ObjectID)
+
+	return &template, nil
+}
+
+// Delete removes a template of the workspace, system templates can not be deleted
+func (s TemplateService) Delete(workspace string, id string) error {
+	templateId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.Collection.DeleteOne(context.Background(), bson.M{"_id": templateId, "workspaceId": workspace, "system": false})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("template not found")
+	}
+
+	return nil
+}
+
+// Instantiate creates a new flow in the workspace from the template with the placeholders filled in
+func (s TemplateService) Instantiate(workspace string, id string, input InstantiateTemplateInput, actor audit.Actor) (string, error) {
+	template, err := s.Get(workspace, id)
+	if err != nil {
+		return "", err
+	}
+
+	values, err := placeholderValues(template.Placeholders, input.Values)
+	if err != nil {
+		return "", err
+	}
+
+	flow := fillPlaceholders(copyFlow(&template.Flow), values)
+	if input.Name != nil {
+		flow.Name = *input.Name
+	}
+
+	return s.FlowService.insertFlow(workspace, flow, actor)
+}
+
+func (s TemplateService) visibleFilter(workspace string) bson.M {
+	return bson.M{"$or": bson.A{bson.M{"system": true}, bson.M{"workspaceId": workspace}}}
+}
+
+// ValidateTemplate checks that every placeholder used by the content is declared once
+func ValidateTemplate(template FlowTemplate) error {
+	if template.Name == "" {
+		return errors.New("template name is required")
+	}
+
+	declared := make(map[string]bool, len(template.Placeholders))
+	for _, placeholder := range template.Placeholders {
+		if !placeholderPattern.MatchString("[[" + placeholder.Key + "]]") {
+			return errors.New("invalid placeholder key: " + placeholder.Key)
+		}
+		if declared[placeholder.Key] {
+			return errors.New("placeholder is declared more than once: " + placeholder.Key)
+		}
+		declared[placeholder.Key] = true
+	}
+
+	for _, key := range usedPlaceholders(&template.Flow) {
+		if !declared[key] {
+			return errors.New("placeholder is used but not declared: " + key)
+		}
+	}
+
+	return nil
+}
+
+// placeholderValues combines the given values with the defaults and checks the required placeholders
+func placeholderValues(placeholders []TemplatePlaceholder, input map[string]string) (map[string]string, error) {
+	values := make(map[string]string, len(placeholders))
+	for _, placeholder := range placeholders {
+		value, ok := input[placeholder.Key]
+		if !ok || value == "" {
+			value = placeholder.Default
+		}
+		if value == "" && placeholder.Required {
+			return nil, errors.New("placeholder is required: " + placeholder.Key)
+		}
+		values[placeholder.Key] = value
+	}
+
+	for key := range input {
+		if _, ok := values[key]; !ok {
+			return nil, errors.New("unknown placeholder: " + key)
+		}
+	}
+
+	return values, nil
+}
+
+// templateTexts applies the mapper to every text of the flow that can hold placeholders
+func templateTexts(flow *Flow, mapper func(text string) string) *Flow {
+	mapped := mapFlowContent(flow, func(key string, content string) string { return mapper(content) })
+	mapped.Name = mapper(mapped.Name)
+	mapped.BaseURL = mapper(mapped.BaseURL)
+	for i := range mapped.Steps {
+		mapped.Steps[i].Data.TargetUrl = mapper(mapped.Steps[i].Data.TargetUrl)
+	}
+	if flow.Translations != nil {
+		mapped.Translations = make(map[string]map[string]string, len(flow.Translations))
+	}
+	for locale, values := range flow.Translations {
+		mappedValues := make(map[string]string, len(values))
+		for key, value := range values {
+			mappedValues[key] = mapper(value)
+		}
+		mapped.Translations[locale] = mappedValues
+	}
+
+	return mapped
+}
+
+func fillPlaceholders(flow *Flow, values map[string]string) *Flow {
+	return templateTexts(flow, func(text string) string {
+		return placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+			key := placeholderPattern.FindStringSubmatch(match)[1]
+			if value, ok := values[key]; ok {
+				return value
+			}
+			return match
+		})
+	})
+}
+
+func usedPlaceholders(flow *Flow) []string {
+	keys := make([]string, 0)
+	templateTexts(flow, func(text string) string {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			if !slices.Contains(keys, match[1]) {
+				keys = append(keys, match[1])
+			}
+		}
+		return text
+	})
+
+	return keys
+}
@@ -0,0 +1,86 @@
+package flows
+
+import (
+	"testing"
+)
+
+func TestCopyFlow(t *testing.T) {
+	flow := &Flow{
+		Name: "Welcome",
+		Live: true,
+		Steps: []Step{
+			{StepID: "a", Data: StepData{BranchingOptions: []BranchingOption{{OptionID: "o", Label: "Next", NextStepID: "b"}}}},
+			{StepID: "b", ParentNodeId: "a", Data: StepData{ActionText: "Done"}},
+		},
+		Translations: map[string]map[string]string{"de": {"steps/b/actionText": "Fertig"}},
+	}
+
+	copied := copyFlow(flow)
+
+	t.Run("steps get new ids and references follow", func(t *testing.T) {
+		first, second := copied.Steps[0], copied.Steps[1]
+		if first.StepID == "a" || second.StepID == "b" {
+			t.Fatalf("Expected new step ids, got %s and %s", first.StepID, second.StepID)
+		}
+		if second.ParentNodeId != first.StepID || first.Data.BranchingOptions[0].NextStepID != second.StepID {
+			t.Fatalf("Expected parent and branching references to use the new ids")
+		}
+		if copied.Translations["de"]["steps/"+second.StepID+"/actionText"] != "Fertig" {
+			t.Fatalf("Expected translation keys to use the new ids, got %v", copied.Translations)
+		}
+	})
+
+	t.Run("the original is untouched and the copy is not live", func(t *testing.T) {
+		if flow.Steps[1].ParentNodeId != "a" || flow.Steps[0].Data.BranchingOptions[0].NextStepID != "b" {
+			t.Fatalf("Expected the original flow to keep its references")
+		}
+		if copied.Live {
+			t.Fatalf("Expected the copy not to be live")
+		}
+	})
+}
+
+func TestTemplatePlaceholders(t *testing.T) {
+	template := FlowTemplate{
+		Name: "Feature launch",
+		Placeholders: []TemplatePlaceholder{
+			{Key: "product", Required: true},
+			{Key: "cta", Default: "Try it"},
+		},
+		Flow: Flow{
+			Name:  "Launch of [[product]]",
+			Steps: []Step{{StepID: "a", Data: StepData{ActionText: "[[ cta ]]", Blocks: []StepBlock{{BlockID: "b", Data: "Meet [[product]]"}}}}},
+		},
+	}
+
+	t.Run("used placeholders must be declared", func(t *testing.T) {
+		if err := ValidateTemplate(template); err != nil {
+			t.Fatalf("Expected template to be valid, got %v", err)
+		}
+		undeclared := template
+		undeclared.Placeholders = template.Placeholders[:1]
+		if ValidateTemplate(undeclared) == nil {
+			t.Fatalf("Expected undeclared placeholder to be rejected")
+		}
+	})
+
+	t.Run("values and defaults are filled in", func(t *testing.T) {
+		values, err := placeholderValues(template.Placeholders, map[string]string{"product": "Reports"})
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		flow := fillPlaceholders(&template.Flow, values)
+		if flow.Name != "Launch of Reports" || flow.Steps[0].Data.ActionText != "Try it" || flow.Steps[0].Data.Blocks[0].Data != "Meet Reports" {
+			t.Fatalf("Expected placeholders to be filled, got %+v", flow)
+		}
+	})
+
+	t.Run("required and unknown placeholders are rejected", func(t *testing.T) {
+		if _, err := placeholderValues(template.Placeholders, nil); err == nil {
+			t.Fatalf("Expected missing required placeholder to be rejected")
+		}
+		if _, err := placeholderValues(template.Placeholders, map[string]string{"product": "x", "other": "y"}); err == nil {
+			t.Fatalf("Expected unknown placeholder to be rejected")
+		}
+	})
+}
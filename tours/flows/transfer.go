@@ -0,0 +1,577 @@
+package flows
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v3"
+	"io"
+	"milestone_core/tours/audit"
+	"milestone_core/tours/translations"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// TransferVersion is the version of the export format, bumped on changes that older importers can not read
+const TransferVersion = 1
+
+type TransferFormat string
+
+const (
+	TransferFormatJSON TransferFormat = "json"
+	TransferFormatYAML TransferFormat = "yaml"
+)
+
+func ParseTransferFormat(format string) (TransferFormat, error) {
+	switch TransferFormat(format) {
+	case TransferFormatJSON, "":
+		return TransferFormatJSON, nil
+	case TransferFormatYAML, "yml":
+		return TransferFormatYAML, nil
+	}
+
+	return "", errors.New("unknown export format: " + format)
+}
+
+func (f TransferFormat) ContentType() string {
+	if f == TransferFormatYAML {
+		return "application/yaml"
+	}
+
+	return "application/json"
+}
+
+// FlowExport is the portable form of flows used to move them between workspaces. The ids are the ones of the
+// source workspace, they only link the entries of the export to each other and are replaced on import.
+type FlowExport struct {
+	Version    int                 `json:"version"`
+	ExportedAt int64               `json:"exportedAt"`
+	Flows      []ExportedFlow      `json:"flows"`
+	Branchings []ExportedBranching `json:"branchings,omitempty"`
+	// References are the flows outside the export that the exported flows depend on
+	References []FlowReference `json:"references,omitempty"`
+}
+
+type ExportedFlow struct {
+	ID           string                    `json:"id"`
+	Name         string                    `json:"name"`
+	Type         FlowType                  `json:"type,omitempty"`
+	BaseURL      string                    `json:"baseUrl,omitempty"`
+	Segments     []Segment                 `json:"segments,omitempty"`
+	Steps        []Step                    `json:"steps"`
+	Opts         Opts                      `json:"opts"`
+	Translations translations.Translations `json:"translations,omitempty"`
+	// Media lists the uploaded files the flow shows, they stay hosted at these urls
+	Media []string `json:"media,omitempty"`
+}
+
+type ExportedBranching struct {
+	ID         string             `json:"id"`
+	Name       string             `json:"name"`
+	Content    string             `json:"content"`
+	BaseURL    string             `json:"baseUrl,omitempty"`
+	TargetURL  string             `json:"targetUrl,omitempty"`
+	Variants   []BranchingVariant `json:"variants"`
+	Experiment *Experiment        `json:"experiment,omitempty"`
+}
+
+type FlowReference struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// DependsOnPolicy decides what happens to references to flows that are neither in the export nor in the
+// target workspace
+type DependsOnPolicy string
+
+const (
+	DependsOnPolicyFail      DependsOnPolicy = "fail"
+	DependsOnPolicyDrop      DependsOnPolicy = "drop"
+	DependsOnPolicyMatchName DependsOnPolicy = "match_name"
+)
+
+type ImportOpts struct {
+	DryRun    bool            `json:"dryRun"`
+	DependsOn DependsOnPolicy `json:"dependsOn"`
+}
+
+type ConflictResolution string
+
+const (
+	ConflictResolutionMissing ConflictResolution = "missing"
+	ConflictResolutionDropped ConflictResolution = "dropped"
+	ConflictResolutionMatched ConflictResolution = "matched"
+)
+
+type ImportConflict struct {
+	// SourceID is the exported flow or branching with the reference
+	SourceID   string             `json:"sourceId"`
+	Reference  FlowReference      `json:"reference"`
+	Resolution ConflictResolution `json:"resolution"`
+	// MatchedID is the flow of the target workspace the reference was matched to by name
+	MatchedID string `json:"matchedId,omitempty"`
+}
+
+type ImportedEntry struct {
+	SourceID string              `json:"sourceId"`
+	ID       string              `json:"id,omitempty"`
+	Name     string              `json:"name"`
+	Problems []ValidationProblem `json:"problems,omitempty"`
+}
+
+// ImportResult describes what an import creates. Nothing is created when the import is a dry run or not valid.
+// When a write fails part way Error is set, the entries created before the failure keep their ids.
+type ImportResult struct {
+	DryRun     bool             `json:"dryRun"`
+	Valid      bool             `json:"valid"`
+	Flows      []ImportedEntry  `json:"flows"`
+	Branchings []ImportedEntry  `json:"branchings"`
+	Conflicts  []ImportConflict `json:"conflicts"`
+	Error      string           `json:"error,omitempty"`
+}
+
+type TransferService struct {
+	FlowService      Service
+	BranchingService BranchingService
+}
+
+// Export returns the drafts of the flows, all flows of the workspace when no ids are given. Branchings that
+// route to an exported flow are included together with all of their variant flows.
+func (s TransferService) Export(workspace string, ids []string) (*FlowExport, error) {
+	var flows []*Flow
+	var err error
+	if len(ids) == 0 {
+		flows, err = s.FlowService.List(workspace)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for _, id := range ids {
+			flows, err = s.appendFlow(workspace, flows, id)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	branchings, err := s.BranchingService.List(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	exportedBranchings := make([]*Branching, 0)
+	for changed := true; changed; {
+		changed = false
+		for _, branching := range branchings {
+			if slices.Contains(exportedBranchings, branching) || !routesToFlows(branching, flows) {
+				continue
+			}
+			exportedBranchings = append(exportedBranchings, branching)
+			changed = true
+			for _, variant := range branching.Variants {
+				if !containsFlow(flows, variant.FlowID) {
+					flows, err = s.appendFlow(workspace, flows, variant.FlowID)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+
+	export := FlowExport{
+		Version:    TransferVersion,
+		ExportedAt: time.Now().Unix(),
+		Flows:      make([]ExportedFlow, len(flows)),
+		Branchings: make([]ExportedBranching, len(exportedBranchings)),
+		References: make([]FlowReference, 0),
+	}
+	for i, flow := range flows {
+		export.Flows[i] = exportFlow(flow)
+		for _, dependsOn := range flow.Opts.DependsOn {
+			export.References, err = s.appendReference(workspace, export.References, flows, dependsOn)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if flow.Opts.Goal != nil && flow.Opts.Goal.FlowID != "" {
+			export.References, err = s.appendReference(workspace, export.References, flows, flow.Opts.Goal.FlowID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	for i, branching := range exportedBranchings {
+		export.Branchings[i] = exportBranching(branching)
+		if branching.Experiment != nil && branching.Experiment.Goal.FlowID != "" {
+			export.References, err = s.appendReference(workspace, export.References, flows, branching.Experiment.Goal.FlowID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &export, nil
+}
+
+// Import creates the flows and branchings of the export as new drafts in the workspace. A failed write returns the
+// error together with the result, which has the ids of the entries created so far.
+func (s TransferService) Import(workspace string, export FlowExport, opts ImportOpts, actor audit.Actor) (*ImportResult, error) {
+	targetFlows, err := s.FlowService.List(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	result, flows, branchings, err := planImport(export, targetFlows, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun || !result.Valid {
+		return result, nil
+	}
+
+	for i := range flows {
+		result.Flows[i].ID, err = s.FlowService.insertFlow(workspace, &flows[i], actor)
+		if err != nil {
+			result.Error = err.Error()
+			return result, err
+		}
+	}
+	for i, branching := range branchings {
+		result.Branchings[i].ID, err = s.BranchingService.Create(workspace, branching, actor)
+		if err != nil {
+			result.Error = err.Error()
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// planImport maps the export onto the target workspace without writing anything. Flows get new ids up front so
+// that references between the exported flows can be moved to them.
+func planImport(export FlowExport, targetFlows []*Flow, opts ImportOpts) (*ImportResult, []Flow, []Branching, error) {
+	if export.Version < 1 || export.Version > TransferVersion {
+		return nil, nil, nil, errors.New("unsupported export version: " + strconv.Itoa(export.Version))
+	}
+	switch opts.DependsOn {
+	case "":
+		opts.DependsOn = DependsOnPolicyFail
+	case DependsOnPolicyFail, DependsOnPolicyDrop, DependsOnPolicyMatchName:
+	default:
+		return nil, nil, nil, errors.New("unknown depends on policy: " + string(opts.DependsOn))
+	}
+
+	result := ImportResult{
+		DryRun:     opts.DryRun,
+		Valid:      true,
+		Flows:      make([]ImportedEntry, len(export.Flows)),
+		Branchings: make([]ImportedEntry, len(export.Branchings)),
+		Conflicts:  make([]ImportConflict, 0),
+	}
+
+	newIds := make(map[string]primitive.ObjectID, len(export.Flows))
+	for _, exported := range export.Flows {
+		if _, duplicate := newIds[exported.ID]; duplicate || exported.ID == "" {
+			return nil, nil, nil, errors.New("exported flows need unique ids")
+		}
+		newIds[exported.ID] = primitive.NewObjectID()
+	}
+	referenceNames := make(map[string]string, len(export.References))
+	for _, reference := range export.References {
+		referenceNames[reference.ID] = reference.Name
+	}
+
+	// resolve returns the id in the target workspace, or an empty id when the reference is dropped
+	resolve := func(sourceId string, referenceId string) string {
+		if newId, ok := newIds[referenceId]; ok {
+			return newId.Hex()
+		}
+		if containsFlow(targetFlows, referenceId) {
+			return referenceId
+		}
+
+		conflict := ImportConflict{
+			SourceID:   sourceId,
+			Reference:  FlowReference{ID: referenceId, Name: referenceNames[referenceId]},
+			Resolution: ConflictResolutionMissing,
+		}
+		switch opts.DependsOn {
+		case DependsOnPolicyDrop:
+			conflict.Resolution = ConflictResolutionDropped
+		case DependsOnPolicyMatchName:
+			for _, flow := range targetFlows {
+				if conflict.Reference.Name != "" && flow.Name == conflict.Reference.Name {
+					conflict.Resolution = ConflictResolutionMatched
+					conflict.MatchedID = flow.ID.Hex()
+					break
+				}
+			}
+		}
+		if conflict.Resolution == ConflictResolutionMissing {
+			result.Valid = false
+		}
+		result.Conflicts = append(result.Conflicts, conflict)
+
+		return conflict.MatchedID
+	}
+
+	flows := make([]Flow, len(export.Flows))
+	for i, exported := range export.Flows {
+		flow := importFlow(exported)
+		flow.ID = newIds[exported.ID]
+		dependsOn := make([]string, 0, len(exported.Opts.DependsOn))
+		for _, reference := range exported.Opts.DependsOn {
+			if id := resolve(exported.ID, reference); id != "" {
+				dependsOn = append(dependsOn, id)
+			}
+		}
+		flow.Opts.DependsOn = dependsOn
+		if flow.Opts.Goal != nil && flow.Opts.Goal.FlowID != "" {
+			// A dropped goal flow drops the goal
+			goal := *flow.Opts.Goal
+			goal.FlowID = resolve(exported.ID, goal.FlowID)
+			flow.Opts.Goal = &goal
+			if goal.FlowID == "" {
+				flow.Opts.Goal = nil
+			}
+		}
+
+		problems := ValidateFlow(&flow)
+		if len(problems) > 0 {
+			result.Valid = false
+		}
+		result.Flows[i] = ImportedEntry{SourceID: exported.ID, Name: flow.Name, Problems: problems}
+		flows[i] = flow
+	}
+
+	dependsOn := make(map[string][]string, len(targetFlows)+len(flows))
+	for _, flow := range targetFlows {
+		dependsOn[flow.ID.Hex()] = flow.Opts.DependsOn
+	}
+	for _, flow := range flows {
+		dependsOn[flow.ID.Hex()] = flow.Opts.DependsOn
+	}
+	for i, flow := range flows {
+		if problems := dependencyCycleProblems(dependsOn, flow.ID.Hex()); problems != nil {
+			result.Valid = false
+			result.Flows[i].Problems = append(result.Flows[i].Problems, problems...)
+		}
+	}
+
+	branchings := make([]Branching, len(export.Branchings))
+	for i, exported := range export.Branchings {
+		branching := importBranching(exported)
+		problems := make([]ValidationProblem, 0)
+
+		// Variants of dropped flows are removed, they would route users to no flow
+		variants := make([]BranchingVariant, 0, len(branching.Variants))
+		for _, variant := range branching.Variants {
+			if variant.FlowID = resolve(exported.ID, variant.FlowID); variant.FlowID != "" {
+				variants = append(variants, variant)
+			}
+		}
+		if len(variants) == 0 && len(branching.Variants) > 0 {
+			problems = append(problems, ValidationProblem{Code: "invalid_branching", Message: "the flows of all variants were dropped"})
+		}
+		branching.Variants = variants
+
+		// An empty goal flow means the own variant, so a dropped goal flow can not be cleared
+		if branching.Experiment != nil && branching.Experiment.Goal.FlowID != "" {
+			goalFlowId := resolve(exported.ID, branching.Experiment.Goal.FlowID)
+			if goalFlowId == "" {
+				problems = append(problems, ValidationProblem{Code: "invalid_branching", Message: "the goal flow of the experiment was dropped"})
+			} else {
+				branching.Experiment.Goal.FlowID = goalFlowId
+			}
+		}
+
+		if err := ValidateBranching(branching); err != nil {
+			problems = append(problems, ValidationProblem{Code: "invalid_branching", Message: err.Error()})
+		}
+		result.Branchings[i] = ImportedEntry{SourceID: exported.ID, Name: branching.Name}
+		if len(problems) > 0 {
+			result.Valid = false
+			result.Branchings[i].Problems = problems
+		}
+		branchings[i] = branching
+	}
+
+	return &result, flows, branchings, nil
+}
+
+func (s TransferService) appendFlow(workspace string, flows []*Flow, id string) ([]*Flow, error) {
+	flow, err := s.FlowService.Get(workspace, id)
+	if err != nil {
+		return nil, err
+	}
+	if flow == nil {
+		return nil, errors.New("flow not found: " + id)
+	}
+
+	return append(flows, flow), nil
+}
+
+// appendReference records a flow outside the export by name, references to deleted flows keep an empty name
+func (s TransferService) appendReference(workspace string, references []FlowReference, flows []*Flow, id string) ([]FlowReference, error) {
+	if containsFlow(flows, id) || slices.ContainsFunc(references, func(reference FlowReference) bool { return reference.ID == id }) {
+		return references, nil
+	}
+
+	reference := FlowReference{ID: id}
+	flow, err := s.FlowService.Get(workspace, id)
+	if err != nil && !errors.Is(err, primitive.ErrInvalidHex) {
+		return nil, err
+	}
+	if flow != nil {
+		reference.Name = flow.Name
+	}
+
+	return append(references, reference), nil
+}
+
+func containsFlow(flows []*Flow, id string) bool {
+	return slices.ContainsFunc(flows, func(flow *Flow) bool { return flow.ID.Hex() == id })
+}
+
+func routesToFlows(branching *Branching, flows []*Flow) bool {
+	return slices.ContainsFunc(branching.Variants, func(variant BranchingVariant) bool { return containsFlow(flows, variant.FlowID) })
+}
+
+func exportFlow(flow *Flow) ExportedFlow {
+	media := make([]string, 0)
+	for _, segment := range flow.Segments {
+		if segment.IconURL != "" && !slices.Contains(media, segment.IconURL) {
+			media = append(media, segment.IconURL)
+		}
+	}
+	for _, step := range flow.Steps {
+		for _, block := range step.Data.Blocks {
+			isMedia := block.Type == StepBlockTypeImage || block.Type == StepBlockTypeVideo || block.Type == StepBlockTypeAvatar
+			if isMedia && block.Data != "" && !slices.Contains(media, block.Data) {
+				media = append(media, block.Data)
+			}
+		}
+	}
+
+	return ExportedFlow{
+		ID:           flow.ID.Hex(),
+		Name:         flow.Name,
+		Type:         flow.Type,
+		BaseURL:      flow.BaseURL,
+		Segments:     flow.Segments,
+		Steps:        flow.Steps,
+		Opts:         flow.Opts,
+		Translations: flow.Translations,
+		Media:        media,
+	}
+}
+
+func importFlow(exported ExportedFlow) Flow {
+	flow := Flow{
+		Name:         exported.Name,
+		Type:         exported.Type,
+		BaseURL:      exported.BaseURL,
+		Segments:     exported.Segments,
+		Steps:        exported.Steps,
+		Opts:         exported.Opts,
+		Translations: exported.Translations,
+	}
+	if flow.Steps == nil {
+		flow.Steps = []Step{}
+	}
+	if flow.Opts.Schedule != nil {
+		schedule := *flow.Opts.Schedule
+		schedule.ActivatedAt = 0
+		schedule.DeactivatedAt = 0
+		flow.Opts.Schedule = &schedule
+	}
+
+	return flow
+}
+
+func exportBranching(branching *Branching) ExportedBranching {
+	return ExportedBranching{
+		ID:         branching.ID.Hex(),
+		Name:       branching.Name,
+		Content:    branching.Content,
+		BaseURL:    branching.BaseURL,
+		TargetURL:  branching.TargetURL,
+		Variants:   branching.Variants,
+		Experiment: branching.Experiment,
+	}
+}
+
+func importBranching(exported ExportedBranching) Branching {
+	branching := Branching{
+		Name:      exported.Name,
+		Content:   exported.Content,
+		BaseURL:   exported.BaseURL,
+		TargetURL: exported.TargetURL,
+		Variants:  append([]BranchingVariant(nil), exported.Variants...),
+	}
+	if exported.Experiment != nil {
+		experiment := *exported.Experiment
+		branching.Experiment = &experiment
+	}
+
+	return branching
+}
+
+// WriteExport encodes the export. YAML documents use the same field names as JSON.
+func WriteExport(w io.Writer, format TransferFormat, export FlowExport) error {
+	if format != TransferFormatYAML {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	}
+
+	var content bytes.Buffer
+	err := json.NewEncoder(&content).Encode(export)
+	if err != nil {
+		return err
+	}
+	var document any
+	err = json.Unmarshal(content.Bytes(), &document)
+	if err != nil {
+		return err
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	err = encoder.Encode(document)
+	if err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+func ReadExport(r io.Reader, format TransferFormat) (*FlowExport, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == TransferFormatYAML {
+		var document any
+		err = yaml.Unmarshal(content, &document)
+		if err != nil {
+			return nil, err
+		}
+		content, err = json.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var export FlowExport
+	err = json.Unmarshal(content, &export)
+	if err != nil {
+		return nil, err
+	}
+
+	return &export, nil
+}
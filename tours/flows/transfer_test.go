@@ -0,0 +1,91 @@
+package flows
+
+import (
+	"bytes"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func TestTransfer(t *testing.T) {
+	existing := &Flow{ID: primitive.NewObjectID(), Name: "Setup"}
+	export := FlowExport{
+		Version: TransferVersion,
+		Flows: []ExportedFlow{
+			{ID: "first", Name: "First", Steps: []Step{{StepID: "a"}}},
+			{ID: "second", Name: "Second", Steps: []Step{{StepID: "a"}}, Opts: Opts{DependsOn: []string{"first", "elsewhere"}}},
+		},
+		Branchings: []ExportedBranching{
+			{ID: "branching", Name: "Split", Variants: []BranchingVariant{{VariantID: "v", FlowID: "first"}}},
+		},
+		References: []FlowReference{{ID: "elsewhere", Name: "Setup"}},
+	}
+
+	t.Run("the export survives a yaml round trip", func(t *testing.T) {
+		var content bytes.Buffer
+		if err := WriteExport(&content, TransferFormatYAML, export); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		read, err := ReadExport(&content, TransferFormatYAML)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if len(read.Flows) != 2 || read.Flows[1].Opts.DependsOn[0] != "first" || read.Branchings[0].Variants[0].FlowID != "first" {
+			t.Fatalf("Expected the export to be read back, got %+v", read)
+		}
+	})
+
+	t.Run("references between exported flows are remapped", func(t *testing.T) {
+		result, flows, branchings, err := planImport(export, []*Flow{existing}, ImportOpts{DependsOn: DependsOnPolicyMatchName})
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if !result.Valid {
+			t.Fatalf("Expected the import to be valid, got %+v", result)
+		}
+		firstId := flows[0].ID.Hex()
+		if flows[1].Opts.DependsOn[0] != firstId || branchings[0].Variants[0].FlowID != firstId {
+			t.Fatalf("Expected references to use the new id %s", firstId)
+		}
+		if flows[1].Opts.DependsOn[1] != existing.ID.Hex() || result.Conflicts[0].Resolution != ConflictResolutionMatched {
+			t.Fatalf("Expected the outside reference to be matched by name, got %+v", result.Conflicts)
+		}
+	})
+
+	t.Run("missing references follow the policy", func(t *testing.T) {
+		result, _, _, err := planImport(export, nil, ImportOpts{})
+		if err != nil || result.Valid || result.Conflicts[0].Resolution != ConflictResolutionMissing {
+			t.Fatalf("Expected the missing reference to make the import invalid, got %+v", result)
+		}
+
+		result, flows, _, err := planImport(export, nil, ImportOpts{DependsOn: DependsOnPolicyDrop})
+		if err != nil || !result.Valid || len(flows[1].Opts.DependsOn) != 1 {
+			t.Fatalf("Expected the missing reference to be dropped, got %+v", result)
+		}
+	})
+
+	t.Run("dropped variant and goal flows are not imported silently", func(t *testing.T) {
+		dropping := export
+		dropping.Branchings = []ExportedBranching{
+			{ID: "split", Variants: []BranchingVariant{{VariantID: "a", FlowID: "first"}, {VariantID: "b", FlowID: "gone"}}},
+			{ID: "experiment", Variants: []BranchingVariant{{VariantID: "a", FlowID: "first"}, {VariantID: "b", FlowID: "second"}},
+				Experiment: &Experiment{Goal: ExperimentGoal{Type: ExperimentGoalTypeFlowFinished, FlowID: "gone"}}},
+		}
+
+		result, _, branchings, err := planImport(dropping, []*Flow{existing}, ImportOpts{DependsOn: DependsOnPolicyDrop})
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if len(branchings[0].Variants) != 1 || len(result.Branchings[0].Problems) != 0 {
+			t.Fatalf("Expected the dropped variant to be removed, got %+v", branchings[0])
+		}
+		if result.Valid || len(result.Branchings[1].Problems) != 1 {
+			t.Fatalf("Expected the experiment without its goal flow to be invalid, got %+v", result.Branchings[1])
+		}
+	})
+
+	t.Run("unknown versions are rejected", func(t *testing.T) {
+		if _, _, _, err := planImport(FlowExport{Version: TransferVersion + 1}, nil, ImportOpts{}); err == nil {
+			t.Fatalf("Expected a newer export version to be rejected")
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package flows
+
+import (
+	"errors"
+	"github.com/mitchellh/mapstructure"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// EvaluateTrigger reports whether all trigger rules match the client context. Flows without rules always match.
+// Rules with conditions the server does not know, like the free form rules saved before the conditions were
+// defined, are left to the SDKs and skipped.
+func EvaluateTrigger(trigger Trigger, clientContext ClientContext) bool {
+	for _, rule := range trigger.Rules {
+		if !rule.Condition.IsKnown() {
+			continue
+		}
+		matched, err := evaluateTriggerRule(rule, clientContext)
+		if err != nil || !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ValidateTrigger checks the values of the rules with known conditions, unknown conditions are reported by
+// unknownTriggerConditions
+func ValidateTrigger(trigger Trigger) error {
+	for _, rule := range trigger.Rules {
+		if !rule.Condition.IsKnown() {
+			continue
+		}
+		_, err := evaluateTriggerRule(rule, ClientContext{})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c TriggerCondition) IsKnown() bool {
+	switch c {
+	case TriggerConditionURLMatch, TriggerConditionElementPresent, TriggerConditionCustomEvent,
+		TriggerConditionTimeOnPage, TriggerConditionNthVisit:
+		return true
+	}
+
+	return false
+}
+
+func unknownTriggerConditions(trigger Trigger) []ValidationProblem {
+	problems := make([]ValidationProblem, 0)
+	for _, rule := range trigger.Rules {
+		if !rule.Condition.IsKnown() {
+			problems = append(problems, ValidationProblem{
+				Code:    ValidationCodeUnknownTriggerCondition,
+				Message: "unknown trigger condition, the rule is not evaluated: " + string(rule.Condition),
+			})
+		}
+	}
+
+	return problems
+}
+
+func evaluateTriggerRule(rule TriggerRule, clientContext ClientContext) (bool, error) {
+	switch rule.Condition {
+	case TriggerConditionURLMatch:
+		var value TriggerValueURLMatch
+		if err := decodeTriggerValue(rule.Value, &value); err != nil || value.Pattern == "" {
+			return false, errors.New("url_match trigger requires a pattern")
+		}
+		return matchURL(value, clientContext.CurrentURL)
+	case TriggerConditionElementPresent:
+		var value TriggerValueElementPresent
+		if err := decodeTriggerValue(rule.Value, &value); err != nil || value.Selector == "" {
+			return false, errors.New("element_present trigger requires a selector")
+		}
+		return containsString(clientContext.PresentElements, value.Selector), nil
+	case TriggerConditionCustomEvent:
+		var value TriggerValueCustomEvent
+		if err := decodeTriggerValue(rule.Value, &value); err != nil || value.Event == "" {
+			return false, errors.New("custom_event trigger requires an event")
+		}
+		return containsString(clientContext.RecentEvents, value.Event), nil
+	case TriggerConditionTimeOnPage:
+		var value TriggerValueTimeOnPage
+		if err := decodeTriggerValue(rule.Value, &value); err != nil || value.Seconds < 0 {
+			return false, errors.New("time_on_page trigger requires seconds")
+		}
+		return clientContext.TimeOnPageSeconds >= value.Seconds, nil
+	case TriggerConditionNthVisit:
+		var value TriggerValueNthVisit
+		if err := decodeTriggerValue(rule.Value, &value); err != nil || value.Visit < 1 {
+			return false, errors.New("nth_visit trigger requires a visit number")
+		}
+		// Users that missed the exact visit still get the flow on their next ones
+		return clientContext.VisitCount >= value.Visit, nil
+	}
+
+	return false, errors.New("unknown trigger condition: " + string(rule.Condition))
+}
+
+func decodeTriggerValue(rawValue any, target any) error {
+	value, ok := asMap(rawValue)
+	if !ok {
+		return errors.New("invalid trigger value")
+	}
+
+	return mapstructure.WeakDecode(value, target)
+}
+
+// matchURL compares patterns starting with "/" with the path of the current url and other patterns with the
+// full url. Globs support "*" within a path segment and "**" across segments.
+func matchURL(value TriggerValueURLMatch, currentURL string) (bool, error) {
+	subject := currentURL
+	if strings.HasPrefix(value.Pattern, "/") {
+		if parsedURL, err := url.Parse(currentURL); err == nil {
+			subject = parsedURL.Path
+		}
+	}
+
+	switch value.Mode {
+	case URLMatchModeExact, "":
+		return subject == value.Pattern, nil
+	case URLMatchModePrefix:
+		return strings.HasPrefix(subject, value.Pattern), nil
+	case URLMatchModeGlob:
+		return regexp.MatchString(globToRegex(value.Pattern), subject)
+	case URLMatchModeRegex:
+		return regexp.MatchString(value.Pattern, subject)
+	}
+
+	return false, errors.New("unknown url match mode: " + string(value.Mode))
+}
+
+func globToRegex(pattern string) string {
+	var builder strings.Builder
+	builder.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			builder.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			builder.WriteString("[^/]*")
+		case pattern[i] == '?':
+			builder.WriteString("[^/]")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		}
+	}
+	builder.WriteString("$")
+
+	return builder.String()
+}
+
+func containsString(values []string, expected string) bool {
+	for _, value := range values {
+		if value == expected {
+			return true
+		}
+	}
+
+	return false
+}
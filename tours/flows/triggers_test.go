@@ -0,0 +1,80 @@
+package flows
+
+import (
+	"testing"
+)
+
+func TestEvaluateTrigger(t *testing.T) {
+	clientContext := ClientContext{
+		CurrentURL:        "https://app.acme.io/projects/42/settings?tab=billing",
+		PresentElements:   []string{"#upgrade-button"},
+		RecentEvents:      []string{"invoice_created"},
+		TimeOnPageSeconds: 30,
+		VisitCount:        3,
+	}
+
+	t.Run("url match modes", func(t *testing.T) {
+		matching := []TriggerValueURLMatch{
+			{Mode: URLMatchModeExact, Pattern: "/projects/42/settings"},
+			{Mode: URLMatchModePrefix, Pattern: "/projects"},
+			{Mode: URLMatchModeGlob, Pattern: "/projects/*/settings"},
+			{Mode: URLMatchModeGlob, Pattern: "https://app.acme.io/**"},
+			{Mode: URLMatchModeRegex, Pattern: "/projects/\\d+/settings$"},
+		}
+		for _, value := range matching {
+			trigger := Trigger{Rules: []TriggerRule{{Condition: TriggerConditionURLMatch, Value: map[string]any{"mode": value.Mode, "pattern": value.Pattern}}}}
+			if !EvaluateTrigger(trigger, clientContext) {
+				t.Fatalf("Expected %v to match", value)
+			}
+		}
+
+		trigger := Trigger{Rules: []TriggerRule{{Condition: TriggerConditionURLMatch, Value: map[string]any{"mode": "glob", "pattern": "/projects/*"}}}}
+		if EvaluateTrigger(trigger, clientContext) {
+			t.Fatalf("Expected single star not to match across path segments")
+		}
+	})
+
+	t.Run("all rules must match", func(t *testing.T) {
+		trigger := Trigger{Rules: []TriggerRule{
+			{Condition: TriggerConditionElementPresent, Value: map[string]any{"selector": "#upgrade-button"}},
+			{Condition: TriggerConditionCustomEvent, Value: map[string]any{"event": "invoice_created"}},
+			{Condition: TriggerConditionTimeOnPage, Value: map[string]any{"seconds": 10}},
+			{Condition: TriggerConditionNthVisit, Value: map[string]any{"visit": 3}},
+		}}
+		if !EvaluateTrigger(trigger, clientContext) {
+			t.Fatalf("Expected trigger to match")
+		}
+
+		trigger.Rules = append(trigger.Rules, TriggerRule{Condition: TriggerConditionNthVisit, Value: map[string]any{"visit": 4}})
+		if EvaluateTrigger(trigger, clientContext) {
+			t.Fatalf("Expected fourth visit trigger not to match")
+		}
+	})
+
+	t.Run("invalid rules are rejected", func(t *testing.T) {
+		invalid := []TriggerRule{
+			{Condition: TriggerConditionURLMatch, Value: map[string]any{"mode": "regex", "pattern": "("}},
+			{Condition: TriggerConditionCustomEvent, Value: "invoice_created"},
+			{Condition: TriggerConditionNthVisit, Value: map[string]any{"visit": 0}},
+		}
+		for _, rule := range invalid {
+			if ValidateTrigger(Trigger{Rules: []TriggerRule{rule}}) == nil {
+				t.Fatalf("Expected %v to be invalid", rule)
+			}
+		}
+	})
+
+	t.Run("unknown conditions are skipped and reported", func(t *testing.T) {
+		trigger := Trigger{Rules: []TriggerRule{
+			{Condition: "on_scroll", Value: "50%"},
+			{Condition: TriggerConditionNthVisit, Value: map[string]any{"visit": 2}},
+		}}
+		if !EvaluateTrigger(trigger, clientContext) || ValidateTrigger(trigger) != nil {
+			t.Fatalf("Expected the unknown condition to be skipped")
+		}
+		problems := unknownTriggerConditions(trigger)
+		if len(problems) != 1 || problems[0].Code != ValidationCodeUnknownTriggerCondition {
+			t.Fatalf("Expected the unknown condition to be reported, got %v", problems)
+		}
+	})
+}
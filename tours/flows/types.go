@@ -4,6 +4,7 @@ import "mime/multipart"
 
 type UpdateInput struct {
 	Name         *string                `json:"name,omitempty"`
+	Type         *FlowType              `json:"type,omitempty"`
 	BaseURL      *string                `json:"baseUrl,omitempty"`
 	Opts         *Opts                  `json:"opts,omitempty"`
 	UpdatedSteps []Step                 `json:"updatedSteps,omitempty"`
@@ -15,4 +16,31 @@ type UpdateInput struct {
 	FinishEffect *FinishEffect          `json:"finishEffect,omitempty"`
 	MediaFiles   []multipart.FileHeader `json:"mediaFiles,omitempty"`
 	DependsOn    []string               `json:"dependsOn,omitempty"`
+	Rollout      *RolloutInput          `json:"rollout,omitempty"`
+	Schedule     *Schedule              `json:"schedule,omitempty"`
+	Frequency    *FrequencyInput        `json:"frequency,omitempty"`
+	Goal         *GoalInput             `json:"goal,omitempty"`
+}
+
+// GoalInput without a goal removes the goal of the flow
+type GoalInput struct {
+	Goal *FlowGoal `json:"goal,omitempty"`
+}
+
+// FrequencyInput without settings removes the caps of the flow, only the workspace frequency settings apply
+type FrequencyInput struct {
+	Settings *FrequencySettings `json:"settings,omitempty"`
+}
+
+// RolloutInput replaces both percentages, a missing rollout percentage releases the flow to everyone
+type RolloutInput struct {
+	RolloutPercentage *int `json:"rolloutPercentage,omitempty"`
+	HoldoutPercentage int  `json:"holdoutPercentage"`
+}
+
+// SaveResult is returned by saves of the draft. Drafts are saved with their problems, only publishing rejects
+// them, so a graph can be fixed one step at a time.
+type SaveResult struct {
+	Version  int64               `json:"version"`
+	Problems []ValidationProblem `json:"problems"`
 }
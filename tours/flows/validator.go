@@ -0,0 +1,338 @@
+package flows
+
+type ValidationCode string
+
+const (
+	ValidationCodeMissingStepID   ValidationCode = "missing_step_id"
+	ValidationCodeDuplicateStepID ValidationCode = "duplicate_step_id"
+	ValidationCodeNoRoot          ValidationCode = "no_root"
+	ValidationCodeMultipleRoots   ValidationCode = "multiple_roots"
+	ValidationCodeDanglingParent  ValidationCode = "dangling_parent"
+	ValidationCodeCycle           ValidationCode = "cycle"
+	ValidationCodeOrphanedStep    ValidationCode = "orphaned_step"
+	ValidationCodeUnknownSegment  ValidationCode = "unknown_segment"
+	ValidationCodeNoSteps         ValidationCode = "no_steps"
+
+	ValidationCodeInvalidBranchingOption ValidationCode = "invalid_branching_option"
+	ValidationCodeInvalidTargeting       ValidationCode = "invalid_targeting"
+	ValidationCodeInvalidTrigger         ValidationCode = "invalid_trigger"
+	ValidationCodeInvalidRollout         ValidationCode = "invalid_rollout"
+	ValidationCodeInvalidSchedule        ValidationCode = "invalid_schedule"
+	ValidationCodeInvalidFrequency       ValidationCode = "invalid_frequency"
+	ValidationCodeInvalidType            ValidationCode = "invalid_type"
+	ValidationCodeInvalidSurveyBlock     ValidationCode = "invalid_survey_block"
+	ValidationCodeInvalidTemplate        ValidationCode = "invalid_template"
+	ValidationCodeDependencyCycle        ValidationCode = "dependency_cycle"
+	ValidationCodeInvalidGoal            ValidationCode = "invalid_goal"
+
+	// ValidationCodeUnknownTriggerCondition marks free form rules saved before the trigger conditions existed
+	ValidationCodeUnknownTriggerCondition ValidationCode = "unknown_trigger_condition"
+)
+
+type ValidationProblem struct {
+	StepID  string         `json:"stepId,omitempty"`
+	Code    ValidationCode `json:"code"`
+	Message string         `json:"message"`
+}
+
+type ValidationResult struct {
+	Valid    bool                `json:"valid"`
+	Problems []ValidationProblem `json:"problems"`
+}
+
+// ValidateFlow checks the structure of the step graph. Steps are linked through ParentNodeId, so every
+// step must eventually lead back to a single root without loops or references to missing steps.
+func ValidateFlow(flow *Flow) []ValidationProblem {
+	problems := make([]ValidationProblem, 0)
+
+	stepsById := make(map[string]*Step, len(flow.Steps))
+	for i := range flow.Steps {
+		step := &flow.Steps[i]
+		if step.StepID == "" {
+			problems = append(problems, ValidationProblem{
+				Code:    ValidationCodeMissingStepID,
+				Message: "step without id",
+			})
+			continue
+		}
+		if _, exists := stepsById[step.StepID]; exists {
+			problems = append(problems, ValidationProblem{
+				StepID:  step.StepID,
+				Code:    ValidationCodeDuplicateStepID,
+				Message: "step id is used more than once",
+			})
+			continue
+		}
+		stepsById[step.StepID] = step
+	}
+
+	if !flow.Type.IsValid() {
+		problems = append(problems, ValidationProblem{
+			Code:    ValidationCodeInvalidType,
+			Message: "unknown flow type: " + string(flow.Type),
+		})
+	}
+
+	problems = append(problems, validateRoots(flow, stepsById)...)
+	problems = append(problems, validateParentChain(flow, stepsById)...)
+	problems = append(problems, validateSegments(flow)...)
+	problems = append(problems, validateBranchingOptions(flow)...)
+	problems = append(problems, validateSurveyBlocks(flow)...)
+	problems = append(problems, validatePersonalization(flow)...)
+
+	if flow.Opts.Targeting.Expression != nil {
+		if err := ValidateTargeting(*flow.Opts.Targeting.Expression); err != nil {
+			problems = append(problems, ValidationProblem{
+				Code:    ValidationCodeInvalidTargeting,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if err := ValidateTrigger(flow.Opts.Trigger); err != nil {
+		problems = append(problems, ValidationProblem{
+			Code:    ValidationCodeInvalidTrigger,
+			Message: err.Error(),
+		})
+	}
+	problems = append(problems, unknownTriggerConditions(flow.Opts.Trigger)...)
+
+	if err := ValidateRollout(flow.Opts); err != nil {
+		problems = append(problems, ValidationProblem{
+			Code:    ValidationCodeInvalidRollout,
+			Message: err.Error(),
+		})
+	}
+
+	if flow.Opts.Schedule != nil {
+		if err := ValidateSchedule(*flow.Opts.Schedule); err != nil {
+			problems = append(problems, ValidationProblem{
+				Code:    ValidationCodeInvalidSchedule,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if flow.Opts.Frequency != nil {
+		if err := ValidateFrequency(*flow.Opts.Frequency); err != nil {
+			problems = append(problems, ValidationProblem{
+				Code:    ValidationCodeInvalidFrequency,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	problems = append(problems, goalProblems(flow)...)
+
+	return problems
+}
+
+// ValidateFlowForPublish runs the structural validation plus the checks that only matter for end users.
+func ValidateFlowForPublish(flow *Flow) []ValidationProblem {
+	problems := ValidateFlow(flow)
+	if len(flow.Steps) == 0 {
+		problems = append(problems, ValidationProblem{
+			Code:    ValidationCodeNoSteps,
+			Message: "flow has no steps",
+		})
+	}
+
+	return problems
+}
+
+func validateRoots(flow *Flow, stepsById map[string]*Step) []ValidationProblem {
+	problems := make([]ValidationProblem, 0)
+	if len(stepsById) == 0 {
+		return problems
+	}
+
+	roots := make([]string, 0)
+	for _, step := range flow.Steps {
+		if step.StepID != "" && step.ParentNodeId == "" {
+			roots = append(roots, step.StepID)
+		}
+	}
+
+	if len(roots) == 0 {
+		problems = append(problems, ValidationProblem{
+			Code:    ValidationCodeNoRoot,
+			Message: "flow has no root step",
+		})
+	}
+	if len(roots) > 1 {
+		for _, root := range roots {
+			problems = append(problems, ValidationProblem{
+				StepID:  root,
+				Code:    ValidationCodeMultipleRoots,
+				Message: "flow has more than one root step",
+			})
+		}
+	}
+
+	return problems
+}
+
+// validateParentChain walks up the parents of every step. Each chain ends either in a root, in a parent that
+// does not exist or in a loop; steps hanging below a missing parent or a loop can never be reached.
+func validateParentChain(flow *Flow, stepsById map[string]*Step) []ValidationProblem {
+	problems := make([]ValidationProblem, 0)
+
+	inCycle := make(map[string]bool)
+	for _, step := range flow.Steps {
+		if step.StepID == "" || inCycle[step.StepID] {
+			continue
+		}
+
+		positionInPath := make(map[string]int)
+		path := make([]string, 0)
+		currentId := step.StepID
+		for currentId != "" {
+			if position, seen := positionInPath[currentId]; seen {
+				for _, cycleStepId := range path[position:] {
+					inCycle[cycleStepId] = true
+				}
+				break
+			}
+			current, exists := stepsById[currentId]
+			if !exists || inCycle[currentId] {
+				break
+			}
+			positionInPath[currentId] = len(path)
+			path = append(path, currentId)
+			currentId = current.ParentNodeId
+		}
+	}
+
+	for _, step := range flow.Steps {
+		if step.StepID == "" {
+			continue
+		}
+
+		if inCycle[step.StepID] {
+			problems = append(problems, ValidationProblem{
+				StepID:  step.StepID,
+				Code:    ValidationCodeCycle,
+				Message: "step is part of a cycle",
+			})
+			continue
+		}
+
+		if _, exists := stepsById[step.ParentNodeId]; step.ParentNodeId != "" && !exists {
+			problems = append(problems, ValidationProblem{
+				StepID:  step.StepID,
+				Code:    ValidationCodeDanglingParent,
+				Message: "parent step " + step.ParentNodeId + " does not exist",
+			})
+			continue
+		}
+
+		if !leadsToRoot(step.StepID, stepsById, inCycle) {
+			problems = append(problems, ValidationProblem{
+				StepID:  step.StepID,
+				Code:    ValidationCodeOrphanedStep,
+				Message: "step cannot be reached from the root step",
+			})
+		}
+	}
+
+	return problems
+}
+
+func leadsToRoot(stepId string, stepsById map[string]*Step, inCycle map[string]bool) bool {
+	currentId := stepId
+	for {
+		current, exists := stepsById[currentId]
+		if !exists || inCycle[currentId] {
+			return false
+		}
+		if current.ParentNodeId == "" {
+			return true
+		}
+		currentId = current.ParentNodeId
+	}
+}
+
+func validateSegments(flow *Flow) []ValidationProblem {
+	problems := make([]ValidationProblem, 0)
+
+	segmentIds := make(map[string]bool, len(flow.Segments))
+	for _, segment := range flow.Segments {
+		segmentIds[segment.SegmentID] = true
+	}
+
+	for _, step := range flow.Steps {
+		if step.Opts.SegmentID != "" && !segmentIds[step.Opts.SegmentID] {
+			problems = append(problems, ValidationProblem{
+				StepID:  step.StepID,
+				Code:    ValidationCodeUnknownSegment,
+				Message: "segment " + step.Opts.SegmentID + " is not defined on the flow",
+			})
+		}
+	}
+
+	return problems
+}
+
+func validateBranchingOptions(flow *Flow) []ValidationProblem {
+	problems := make([]ValidationProblem, 0)
+
+	for _, step := range flow.Steps {
+		if len(step.Data.BranchingOptions) == 0 {
+			continue
+		}
+		if step.Data.ElementType != StepElementTypeBranching {
+			problems = append(problems, ValidationProblem{
+				StepID:  step.StepID,
+				Code:    ValidationCodeInvalidBranchingOption,
+				Message: "only branching steps can have branching options",
+			})
+			continue
+		}
+
+		children := childSteps(flow, step.StepID)
+		seenOptionIds := make(map[string]bool)
+		for _, option := range step.Data.BranchingOptions {
+			if option.OptionID == "" || seenOptionIds[option.OptionID] {
+				problems = append(problems, ValidationProblem{
+					StepID:  step.StepID,
+					Code:    ValidationCodeInvalidBranchingOption,
+					Message: "branching option ids must be set and unique",
+				})
+				continue
+			}
+			seenOptionIds[option.OptionID] = true
+
+			leadsToChild := false
+			for _, child := range children {
+				if (option.NextStepID != "" && child.StepID == option.NextStepID) ||
+					(option.NextStepID == "" && option.SegmentID != "" && child.Opts.SegmentID == option.SegmentID) {
+					leadsToChild = true
+					break
+				}
+			}
+			if !leadsToChild {
+				problems = append(problems, ValidationProblem{
+					StepID:  step.StepID,
+					Code:    ValidationCodeInvalidBranchingOption,
+					Message: "branching option " + option.OptionID + " does not lead to a child step",
+				})
+			}
+		}
+	}
+
+	return problems
+}
+
+func goalProblems(flow *Flow) []ValidationProblem {
+	if flow.Opts.Goal == nil {
+		return nil
+	}
+	if err := ValidateGoal(flow, *flow.Opts.Goal); err != nil {
+		return []ValidationProblem{{
+			Code:    ValidationCodeInvalidGoal,
+			Message: err.Error(),
+		}}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package flows
+
+import (
+	"testing"
+)
+
+func TestValidateFlow(t *testing.T) {
+	hasProblem := func(problems []ValidationProblem, stepId string, code ValidationCode) bool {
+		for _, problem := range problems {
+			if problem.StepID == stepId && problem.Code == code {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("valid linked list", func(t *testing.T) {
+		flow := Flow{
+			Segments: []Segment{{SegmentID: "segment_1"}},
+			Steps: []Step{
+				{StepID: "step_1"},
+				{StepID: "step_2", ParentNodeId: "step_1"},
+				{StepID: "step_3", ParentNodeId: "step_2", Opts: StepOpts{SegmentID: "segment_1"}},
+			},
+		}
+
+		problems := ValidateFlow(&flow)
+		if len(problems) != 0 {
+			t.Fatalf("Expected no problems, got %v", problems)
+		}
+	})
+
+	t.Run("cycle and steps below it", func(t *testing.T) {
+		flow := Flow{
+			Steps: []Step{
+				{StepID: "step_1"},
+				{StepID: "step_2", ParentNodeId: "step_3"},
+				{StepID: "step_3", ParentNodeId: "step_2"},
+				{StepID: "step_4", ParentNodeId: "step_3"},
+			},
+		}
+
+		problems := ValidateFlow(&flow)
+		if !hasProblem(problems, "step_2", ValidationCodeCycle) || !hasProblem(problems, "step_3", ValidationCodeCycle) {
+			t.Fatalf("Expected cycle problems, got %v", problems)
+		}
+		if !hasProblem(problems, "step_4", ValidationCodeOrphanedStep) {
+			t.Fatalf("Expected orphaned step_4, got %v", problems)
+		}
+		if hasProblem(problems, "step_1", ValidationCodeCycle) {
+			t.Fatalf("Root must not be reported as cycle, got %v", problems)
+		}
+	})
+
+	t.Run("roots, dangling parents, duplicates and segments", func(t *testing.T) {
+		flow := Flow{
+			Steps: []Step{
+				{StepID: "step_1"},
+				{StepID: "step_2"},
+				{StepID: "step_3", ParentNodeId: "missing"},
+				{StepID: "step_4", ParentNodeId: "step_3"},
+				{StepID: "step_4", ParentNodeId: "step_1"},
+				{StepID: "step_5", ParentNodeId: "step_1", Opts: StepOpts{SegmentID: "unknown"}},
+			},
+		}
+
+		problems := ValidateFlow(&flow)
+		if !hasProblem(problems, "step_1", ValidationCodeMultipleRoots) || !hasProblem(problems, "step_2", ValidationCodeMultipleRoots) {
+			t.Fatalf("Expected multiple roots, got %v", problems)
+		}
+		if !hasProblem(problems, "step_3", ValidationCodeDanglingParent) {
+			t.Fatalf("Expected dangling parent, got %v", problems)
+		}
+		if !hasProblem(problems, "step_4", ValidationCodeOrphanedStep) {
+			t.Fatalf("Expected orphaned step, got %v", problems)
+		}
+		if !hasProblem(problems, "step_4", ValidationCodeDuplicateStepID) {
+			t.Fatalf("Expected duplicate step id, got %v", problems)
+		}
+		if !hasProblem(problems, "step_5", ValidationCodeUnknownSegment) {
+			t.Fatalf("Expected unknown segment, got %v", problems)
+		}
+	})
+
+	t.Run("publish requires steps", func(t *testing.T) {
+		problems := ValidateFlowForPublish(&Flow{})
+		if !hasProblem(problems, "", ValidationCodeNoSteps) {
+			t.Fatalf("Expected no steps problem, got %v", problems)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"milestone_core/shared/versioning"
+	"milestone_core/tours/audit"
+	"milestone_core/tours/personalization"
+	"milestone_core/tours/translations"
+	"strings"
+	"time"
+)
+
+// TranslationEntries lists the translatable content of the helper, keyed like "blocks/block_1" and "actionText"
+func TranslationEntries(helper *Helper) []translations.Entry {
+	entries := make([]translations.Entry, 0)
+	for _, block := range helper.Data.Blocks {
+		if block.Type == HelperBlockTypeText && block.Data != "" {
+			entries = append(entries, translations.Entry{Key: "blocks/" + block.BlockID, Source: block.Data})
+		}
+	}
+	if helper.Data.ActionText != "" {
+		entries = append(entries, translations.Entry{Key: "actionText", Source: helper.Data.ActionText})
+	}
+
+	return entries
+}
+
+// LocalizeHelper returns a copy of the helper with the content in the locale, without the translations
+func LocalizeHelper(helper Helper, locale string) Helper {
+	resolver := helper.Translations.Resolver(locale)
+	helper.Translations = nil
+	if resolver.IsEmpty() {
+		return helper
+	}
+
+	blocks := make([]HelperBlock, len(helper.Data.Blocks))
+	for i, block := range helper.Data.Blocks {
+		block.Data = resolver.Translate("blocks/"+block.BlockID, block.Data)
+		blocks[i] = block
+	}
+	helper.Data.Blocks = blocks
+	helper.Data.ActionText = resolver.Translate("actionText", helper.Data.ActionText)
+
+	return helper
+}
+
+func (s Service) ExportTranslations(publicId string, workspaceId string, locale string, sourceLocale string) (*translations.Bundle, error) {
+	helper, err := s.Get(publicId, workspaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	locale = translations.NormalizeLocale(locale)
+	if locale == "" {
+		return nil, errors.New("locale is required")
+	}
+
+	return &translations.Bundle{
+		Original:     publicId,
+		SourceLocale: sourceLocale,
+		Locale:       locale,
+		Entries:      helper.Translations.WithTargets(locale, TranslationEntries(helper)),
+	}, nil
+}
+
+func (s Service) ImportTranslations(publicId string, workspaceId string, version int64, bundle translations.Bundle, actor audit.Actor) error {
+	helper, err := s.Get(publicId, workspaceId)
+	if err != nil {
+		return err
+	}
+	err = versioning.Check(version, helper.Version, helper)
+	if err != nil {
+		return err
+	}
+
+	merged, unknownKeys := helper.Translations.Merge(bundle.Locale, bundle.Entries, TranslationEntries(helper))
+	if len(unknownKeys) > 0 {
+		return errors.New("unknown translation keys: " + strings.Join(unknownKeys, ", "))
+	}
+	for _, entry := range bundle.Entries {
+		if err := personalization.Validate(entry.Target); err != nil {
+			return errors.New(entry.Key + ": " + err.Error())
+		}
+	}
+
+	filter := bson.M{"publicId": publicId, "workspaceId": workspaceId, "version": versioning.Filter(helper.Version)}
+	res, err := s.Collection.UpdateOne(context.Background(), filter, bson.M{"$set": bson.M{"translations": merged, "updated": time.Now().Unix()}, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return s.versionConflict(publicId, workspaceId)
+	}
+
+	return s.recordChange(workspaceId, actor, publicId, audit.ActionUpdated, helper)
+}
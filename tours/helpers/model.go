@@ -1,6 +1,9 @@
 package helpers
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"milestone_core/tours/translations"
+)
 
 type Helper struct {
 	ID           primitive.ObjectID `json:"-" bson:"_id,omitempty"`
@@ -13,6 +16,10 @@ type Helper struct {
 	Created      int64              `json:"created" bson:"created"`
 	Updated      int64              `json:"updated" bson:"updated"`
 	PublishedAt  int64              `json:"publishedAt" bson:"publishedAt"`
+	// Translations of the content by locale, see TranslationEntries for the keys
+	Translations translations.Translations `json:"translations,omitempty" bson:"translations,omitempty"`
+	// Version is increased on every change of the helper, writes with an older version are rejected
+	Version int64 `json:"version" bson:"version"`
 }
 
 type HelperData struct {
@@ -0,0 +1,66 @@
+package helpers
+
+import (
+	"encoding/json"
+	"milestone_core/tours/personalization"
+)
+
+// PersonalizeHelper renders the templates of the text blocks and the action text with the values
+func PersonalizeHelper(helper Helper, values personalization.Values) Helper {
+	blocks := make([]HelperBlock, len(helper.Data.Blocks))
+	for i, block := range helper.Data.Blocks {
+		if block.Type == HelperBlockTypeText {
+			block.Data = personalization.Render(block.Data, values)
+		}
+		blocks[i] = block
+	}
+	helper.Data.Blocks = blocks
+	helper.Data.ActionText = personalization.Render(helper.Data.ActionText, values)
+
+	return helper
+}
+
+// PersonalizationNamespaces returns the variable namespaces used by the content of the helpers
+func PersonalizationNamespaces(helpers []Helper) map[string]bool {
+	contents := make([]string, 0)
+	for i := range helpers {
+		for _, entry := range TranslationEntries(&helpers[i]) {
+			contents = append(contents, entry.Source)
+		}
+	}
+
+	return personalization.Namespaces(contents...)
+}
+
+func validateContent(data HelperData) error {
+	for _, block := range data.Blocks {
+		if block.Type != HelperBlockTypeText {
+			continue
+		}
+		if err := personalization.Validate(block.Data); err != nil {
+			return err
+		}
+	}
+
+	return personalization.Validate(data.ActionText)
+}
+
+// validateContentUpdate checks the templates of the data of a partial update
+func validateContentUpdate(update map[string]interface{}) error {
+	rawData, ok := update["data"]
+	if !ok {
+		return nil
+	}
+
+	encodedData, err := json.Marshal(rawData)
+	if err != nil {
+		return err
+	}
+	var data HelperData
+	err = json.Unmarshal(encodedData, &data)
+	if err != nil {
+		return err
+	}
+
+	return validateContent(data)
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"github.com/go-chi/chi/v5"
 	"milestone_core/shared/server"
+	"milestone_core/shared/versioning"
+	"milestone_core/tours/audit"
+	"milestone_core/tours/translations"
 	"net/http"
 )
 
@@ -23,6 +26,8 @@ func (rs Resource) Routes() chi.Router {
 		r.Delete("/", rs.Delete)
 		r.Post("/publish", rs.Publish)
 		r.Post("/unpublish", rs.Unpublish)
+		r.Get("/translations", rs.ExportTranslations)
+		r.Put("/translations", rs.ImportTranslations)
 	})
 
 	return r
@@ -49,6 +54,7 @@ func (rs Resource) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	versioning.SetETag(w, helper.Version)
 	server.SendJson(w, helper)
 }
 
@@ -62,7 +68,7 @@ func (rs Resource) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
-	createdHelper, err := rs.Service.Create(workspaceId, helper)
+	createdHelper, err := rs.Service.Create(workspaceId, helper, audit.ActorFromContext(r.Context()))
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
@@ -80,10 +86,17 @@ func (rs Resource) UpdateMulti(w http.ResponseWriter, r *http.Request) {
 		server.SendBadRequestErrorJson(w, err)
 		return
 	}
+	// A single If-Match header can not cover several helpers, each helper carries the version it was read at
 	for _, helper := range helpers {
-		err = rs.Service.Update(helper["publicId"].(string), workspaceId, helper)
+		version, ok := helper["version"].(float64)
+		if !ok {
+			sendError(w, versioning.ErrVersionRequired)
+			return
+		}
+		publicId, _ := helper["publicId"].(string)
+		_, err = rs.Service.Update(publicId, workspaceId, helper, int64(version), audit.ActorFromContext(r.Context()))
 		if err != nil {
-			server.SendBadRequestErrorJson(w, err)
+			sendError(w, err)
 			return
 		}
 	}
@@ -94,20 +107,26 @@ func (rs Resource) UpdateMulti(w http.ResponseWriter, r *http.Request) {
 func (rs Resource) Update(w http.ResponseWriter, r *http.Request) {
 	publicId := chi.URLParam(r, "publicId")
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	version, err := versioning.IfMatch(r)
+	if err != nil {
+		sendError(w, err)
+		return
+	}
 
 	var helper map[string]interface{}
-	err := json.NewDecoder(r.Body).Decode(&helper)
+	err = json.NewDecoder(r.Body).Decode(&helper)
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
 	}
 
-	err = rs.Service.Update(publicId, workspaceId, helper)
+	version, err = rs.Service.Update(publicId, workspaceId, helper, version, audit.ActorFromContext(r.Context()))
 	if err != nil {
-		server.SendBadRequestErrorJson(w, err)
+		sendError(w, err)
 		return
 	}
 
+	versioning.SetETag(w, version)
 	server.SendJson(w, map[string]string{"message": "updated helper with publicId: " + publicId})
 }
 
@@ -115,7 +134,7 @@ func (rs Resource) Delete(w http.ResponseWriter, r *http.Request) {
 	publicId := chi.URLParam(r, "publicId")
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
 
-	err := rs.Service.Delete(publicId, workspaceId)
+	err := rs.Service.Delete(publicId, workspaceId, audit.ActorFromContext(r.Context()))
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
@@ -128,7 +147,7 @@ func (rs Resource) Publish(w http.ResponseWriter, r *http.Request) {
 	publicId := chi.URLParam(r, "publicId")
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
 
-	err := rs.Service.Publish(publicId, workspaceId)
+	err := rs.Service.Publish(publicId, workspaceId, audit.ActorFromContext(r.Context()))
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
@@ -141,7 +160,7 @@ func (rs Resource) Unpublish(w http.ResponseWriter, r *http.Request) {
 	publicId := chi.URLParam(r, "publicId")
 	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
 
-	err := rs.Service.Unpublish(publicId, workspaceId)
+	err := rs.Service.Unpublish(publicId, workspaceId, audit.ActorFromContext(r.Context()))
 	if err != nil {
 		server.SendBadRequestErrorJson(w, err)
 		return
@@ -149,3 +168,59 @@ func (rs Resource) Unpublish(w http.ResponseWriter, r *http.Request) {
 
 	server.SendJson(w, "unpublished helper with publicId: "+publicId)
 }
+
+func (rs Resource) ExportTranslations(w http.ResponseWriter, r *http.Request) {
+	publicId := chi.URLParam(r, "publicId")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	format, err := translations.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	bundle, err := rs.Service.ExportTranslations(publicId, workspaceId, r.URL.Query().Get("locale"), r.URL.Query().Get("sourceLocale"))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	translations.SendBundle(w, format, *bundle)
+}
+
+func (rs Resource) ImportTranslations(w http.ResponseWriter, r *http.Request) {
+	publicId := chi.URLParam(r, "publicId")
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	format, err := translations.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+	version, err := versioning.OptionalIfMatch(r)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	bundle, err := translations.ReadBundle(r.Body, format)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	err = rs.Service.ImportTranslations(publicId, workspaceId, version, *bundle, audit.ActorFromContext(r.Context()))
+	if err != nil {
+		sendError(w, err)
+		return
+	}
+
+	server.SendJson(w, "imported "+bundle.Locale+" translations for helper with publicId: "+publicId)
+}
+
+// sendError answers version conflicts with the current helper so the editor can merge
+func sendError(w http.ResponseWriter, err error) {
+	if versioning.SendError(w, err) {
+		return
+	}
+
+	server.SendBadRequestErrorJson(w, err)
+}
@@ -6,11 +6,14 @@ import (
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"milestone_core/shared/versioning"
+	"milestone_core/tours/audit"
 	"time"
 )
 
 type Service struct {
 	Collection *mongo.Collection
+	AuditLog   audit.Log
 }
 
 func (s Service) Get(publicId string, workspaceId string) (*Helper, error) {
@@ -68,34 +71,71 @@ func (s Service) ListPublished(workspaceId string) ([]Helper, error) {
 	return helpers, nil
 }
 
-func (s Service) Create(workspaceId string, inputHelper Helper) (*Helper, error) {
+func (s Service) Create(workspaceId string, inputHelper Helper, actor audit.Actor) (*Helper, error) {
 	newHelper := s.createNewHelper(workspaceId, inputHelper)
+	err := validateContent(newHelper.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.Collection.InsertOne(context.Background(), newHelper)
+	if err != nil {
+		return nil, err
+	}
 
-	_, err := s.Collection.InsertOne(context.Background(), newHelper)
+	err = s.AuditLog.Record(workspaceId, actor, audit.EntityTypeHelper, newHelper.PublicID, audit.ActionCreated, nil, newHelper)
 	return newHelper, err
 }
 
-func (s Service) Update(publicId string, workspaceId string, helper map[string]interface{}) error {
-	_, err := s.Get(publicId, workspaceId)
+// Update sets the given fields of the helper when it is still at the version the editor read, it returns the
+// new version
+func (s Service) Update(publicId string, workspaceId string, helper map[string]interface{}, version int64, actor audit.Actor) (int64, error) {
+	before, err := s.Get(publicId, workspaceId)
 	if errors.Is(err, mongo.ErrNoDocuments) {
-		return errors.New("helper not found")
+		return 0, errors.New("helper not found")
 	}
 	if err != nil {
-		return err
+		return 0, err
+	}
+	err = versioning.Check(version, before.Version, before)
+	if err != nil {
+		return 0, err
+	}
+	err = validateContentUpdate(helper)
+	if err != nil {
+		return 0, err
 	}
 
+	delete(helper, "version")
 	helper["updated"] = time.Now().Unix()
-	_, err = s.Collection.UpdateOne(context.Background(), bson.M{"publicId": publicId, "workspaceId": workspaceId}, bson.M{"$set": helper})
-	return err
+	filter := bson.M{"publicId": publicId, "workspaceId": workspaceId, "version": versioning.Filter(before.Version)}
+	res, err := s.Collection.UpdateOne(context.Background(), filter, bson.M{"$set": helper, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return 0, err
+	}
+	if res.MatchedCount == 0 {
+		return 0, s.versionConflict(publicId, workspaceId)
+	}
+
+	return before.Version + 1, s.recordChange(workspaceId, actor, publicId, audit.ActionUpdated, before)
 }
 
-func (s Service) Delete(publicId string, workspaceId string) error {
-	_, err := s.Collection.DeleteOne(context.Background(), bson.M{"publicId": publicId, "workspaceId": workspaceId})
-	return err
+func (s Service) Delete(publicId string, workspaceId string, actor audit.Actor) error {
+	helper, err := s.Get(publicId, workspaceId)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Collection.DeleteOne(context.Background(), bson.M{"publicId": publicId, "workspaceId": workspaceId})
+	if err != nil {
+		return err
+	}
+
+	return s.AuditLog.Record(workspaceId, actor, audit.EntityTypeHelper, publicId, audit.ActionDeleted, helper, nil)
 }
 
-func (s Service) Publish(publicId string, workspaceId string) error {
-	_, err := s.Get(publicId, workspaceId)
+func (s Service) Publish(publicId string, workspaceId string, actor audit.Actor) error {
+	before, err := s.Get(publicId, workspaceId)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return errors.New("helper not found")
 	}
@@ -103,12 +143,16 @@ func (s Service) Publish(publicId string, workspaceId string) error {
 		return err
 	}
 
-	_, err = s.Collection.UpdateOne(context.Background(), bson.M{"publicId": publicId, "workspaceId": workspaceId}, bson.M{"$set": bson.M{"published": true, "publishedAt": time.Now().Unix()}})
-	return err
+	_, err = s.Collection.UpdateOne(context.Background(), bson.M{"publicId": publicId, "workspaceId": workspaceId}, bson.M{"$set": bson.M{"published": true, "publishedAt": time.Now().Unix()}, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return err
+	}
+
+	return s.recordChange(workspaceId, actor, publicId, audit.ActionPublished, before)
 }
 
-func (s Service) Unpublish(publicId string, workspaceId string) error {
-	_, err := s.Get(publicId, workspaceId)
+func (s Service) Unpublish(publicId string, workspaceId string, actor audit.Actor) error {
+	before, err := s.Get(publicId, workspaceId)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return errors.New("helper not found")
 	}
@@ -116,8 +160,31 @@ func (s Service) Unpublish(publicId string, workspaceId string) error {
 		return err
 	}
 
-	_, err = s.Collection.UpdateOne(context.Background(), bson.M{"publicId": publicId, "workspaceId": workspaceId}, bson.M{"$set": bson.M{"published": false}, "$unset": bson.M{"publishedAt": true}})
-	return err
+	_, err = s.Collection.UpdateOne(context.Background(), bson.M{"publicId": publicId, "workspaceId": workspaceId}, bson.M{"$set": bson.M{"published": false}, "$unset": bson.M{"publishedAt": true}, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return err
+	}
+
+	return s.recordChange(workspaceId, actor, publicId, audit.ActionUnpublished, before)
+}
+
+func (s Service) versionConflict(publicId string, workspaceId string) error {
+	current, err := s.Get(publicId, workspaceId)
+	if err != nil {
+		return err
+	}
+
+	return &versioning.ConflictError{Version: current.Version, Current: current}
+}
+
+// recordChange logs the difference between the helper before a partial update and the stored helper
+func (s Service) recordChange(workspaceId string, actor audit.Actor, publicId string, action audit.Action, before *Helper) error {
+	after, err := s.Get(publicId, workspaceId)
+	if err != nil {
+		return err
+	}
+
+	return s.AuditLog.Record(workspaceId, actor, audit.EntityTypeHelper, publicId, action, before, after)
 }
 
 func (s Service) createNewHelper(workspaceId string, override Helper) *Helper {
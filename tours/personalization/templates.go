@@ -0,0 +1,141 @@
+package personalization
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	NamespaceUser       = "user"
+	NamespaceAttributes = "attributes"
+	NamespaceWallet     = "wallet"
+	NamespaceRewards    = "rewards"
+)
+
+// knownVariables are the fixed variables, custom attributes can be any "attributes.<name>"
+var knownVariables = map[string]bool{
+	"user.externalId":      true,
+	"user.email":           true,
+	"user.name":            true,
+	"user.segment":         true,
+	"user.locale":          true,
+	"user.created":         true,
+	"user.signUpTimestamp": true,
+	"user.daysSinceSignUp": true,
+	"wallet.balance":       true,
+	"rewards.count":        true,
+	"rewards.badges":       true,
+	"rewards.latest":       true,
+}
+
+var (
+	tokenPattern      = regexp.MustCompile(`\{\{(.*?)\}\}`)
+	expressionPattern = regexp.MustCompile(`^\s*([A-Za-z_]\w*(?:\.[\w-]+)+)\s*(?:\|\s*default\s*:\s*("(?:[^"\\]|\\.)*")\s*)?$`)
+)
+
+// Values are the variables of one user by their full name, like "user.name" or "wallet.balance"
+type Values map[string]any
+
+type token struct {
+	variable     string
+	defaultValue string
+}
+
+func parseToken(expression string) (*token, error) {
+	matches := expressionPattern.FindStringSubmatch(expression)
+	if matches == nil {
+		return nil, errors.New("invalid template: {{" + expression + "}}")
+	}
+
+	parsed := &token{variable: matches[1]}
+	if matches[2] != "" {
+		defaultValue, err := strconv.Unquote(matches[2])
+		if err != nil {
+			return nil, errors.New("invalid default value in {{" + expression + "}}")
+		}
+		parsed.defaultValue = defaultValue
+	}
+
+	return parsed, nil
+}
+
+func isKnownVariable(variable string) bool {
+	if knownVariables[variable] {
+		return true
+	}
+
+	namespace, name, _ := strings.Cut(variable, ".")
+	return namespace == NamespaceAttributes && name != "" && !strings.Contains(name, ".")
+}
+
+// Validate checks the syntax of every template of the content and that all variables exist
+func Validate(content string) error {
+	for _, match := range tokenPattern.FindAllStringSubmatch(content, -1) {
+		parsed, err := parseToken(match[1])
+		if err != nil {
+			return err
+		}
+		if !isKnownVariable(parsed.variable) {
+			return errors.New("unknown variable: " + parsed.variable)
+		}
+	}
+
+	return nil
+}
+
+// Namespaces returns the namespaces used by the templates of the contents, so values that need a lookup are only
+// loaded when used
+func Namespaces(contents ...string) map[string]bool {
+	namespaces := make(map[string]bool)
+	for _, content := range contents {
+		for _, match := range tokenPattern.FindAllStringSubmatch(content, -1) {
+			if parsed, err := parseToken(match[1]); err == nil {
+				namespace, _, _ := strings.Cut(parsed.variable, ".")
+				namespaces[namespace] = true
+			}
+		}
+	}
+
+	return namespaces
+}
+
+// Render replaces the templates with the HTML escaped values, falling back to the default value when the
+// variable has no value. Invalid templates are left as they are.
+func Render(content string, values Values) string {
+	if !strings.Contains(content, "{{") {
+		return content
+	}
+
+	return tokenPattern.ReplaceAllStringFunc(content, func(match string) string {
+		parsed, err := parseToken(match[2 : len(match)-2])
+		if err != nil {
+			return match
+		}
+
+		value := formatValue(values[parsed.variable])
+		if value == "" {
+			value = parsed.defaultValue
+		}
+
+		return html.EscapeString(value)
+	})
+}
+
+func formatValue(value any) string {
+	switch typedValue := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return typedValue
+	case float64:
+		return strconv.FormatFloat(typedValue, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(typedValue), 'f', -1, 32)
+	}
+
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,54 @@
+package personalization
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	t.Run("accepts known variables and custom attributes", func(t *testing.T) {
+		content := `Welcome {{ user.name | default:"there" }}, you have {{wallet.balance}} points on {{attributes.plan}}`
+		if err := Validate(content); err != nil {
+			t.Fatalf("Expected the content to be valid, got %v", err)
+		}
+	})
+
+	t.Run("rejects unknown variables and invalid syntax", func(t *testing.T) {
+		for _, content := range []string{"Hi {{user.nickname}}", "Hi {{user.name | upper}}", "Hi {{name}}", `Hi {{user.name | default:there}}`} {
+			if err := Validate(content); err == nil {
+				t.Fatalf("Expected %q to be invalid", content)
+			}
+		}
+	})
+}
+
+func TestRender(t *testing.T) {
+	values := Values{
+		"user.name":       "<b>Ada</b>",
+		"wallet.balance":  1250,
+		"attributes.seat": float64(3),
+	}
+
+	t.Run("renders escaped values", func(t *testing.T) {
+		rendered := Render("Welcome {{user.name}}, you have {{ wallet.balance }} points and {{attributes.seat}} seats", values)
+		if rendered != "Welcome &lt;b&gt;Ada&lt;/b&gt;, you have 1250 points and 3 seats" {
+			t.Fatalf("Unexpected rendered content: %s", rendered)
+		}
+	})
+
+	t.Run("falls back to the default value", func(t *testing.T) {
+		rendered := Render(`Your plan: {{attributes.plan | default:"\"free\""}}`, values)
+		if rendered != "Your plan: &#34;free&#34;" {
+			t.Fatalf("Unexpected rendered content: %s", rendered)
+		}
+		if rendered = Render("Hi {{user.email}}!", values); rendered != "Hi !" {
+			t.Fatalf("Expected missing values without a default to be empty, got %s", rendered)
+		}
+	})
+
+	t.Run("lists the namespaces in use", func(t *testing.T) {
+		namespaces := Namespaces("{{wallet.balance}}", "{{rewards.latest | default:\"none\"}}")
+		if !namespaces[NamespaceWallet] || !namespaces[NamespaceRewards] || namespaces[NamespaceUser] {
+			t.Fatalf("Unexpected namespaces: %v", namespaces)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package preview
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// Header carries the preview token on requests of the SDK
+const Header = "X-Preview-Token"
+
+const (
+	DefaultTTL = 30 * time.Minute
+	MaxTTL     = 24 * time.Hour
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid preview token")
+	ErrExpiredToken = errors.New("preview token expired")
+)
+
+// Claims bind a preview session to one flow of a workspace
+type Claims struct {
+	WorkspaceID string `json:"workspaceId"`
+	FlowID      string `json:"flowId"`
+	SessionID   string `json:"sessionId"`
+	ExpiresAt   int64  `json:"expiresAt"`
+}
+
+// Tokens mints and verifies preview tokens, a token is the encoded claims and their HMAC-SHA256 signature
+type Tokens struct {
+	Secret []byte
+	now    func() time.Time
+}
+
+func (t Tokens) Mint(workspaceId string, flowId string, ttl time.Duration) (string, *Claims, error) {
+	if len(t.Secret) == 0 {
+		return "", nil, errors.New("preview tokens are not configured")
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if ttl > MaxTTL {
+		return "", nil, errors.New("preview tokens can be valid for at most 24 hours")
+	}
+
+	claims := &Claims{
+		WorkspaceID: workspaceId,
+		FlowID:      flowId,
+		SessionID:   uuid.New().String(),
+		ExpiresAt:   t.currentTime().Add(ttl).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + t.sign(encoded), claims, nil
+}
+
+func (t Tokens) Verify(token string) (*Claims, error) {
+	if len(t.Secret) == 0 {
+		return nil, ErrInvalidToken
+	}
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(t.sign(encoded))) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.ExpiresAt <= t.currentTime().Unix() {
+		return nil, ErrExpiredToken
+	}
+
+	return &claims, nil
+}
+
+// VerifyFor verifies the token and checks that it was minted for the workspace and, when given, the flow
+func (t Tokens) VerifyFor(token string, workspaceId string, flowId string) (*Claims, error) {
+	claims, err := t.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.WorkspaceID != workspaceId || (flowId != "" && claims.FlowID != flowId) {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func (t Tokens) sign(encoded string) string {
+	mac := hmac.New(sha256.New, t.Secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (t Tokens) currentTime() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+
+	return time.Now()
+}
@@ -0,0 +1,53 @@
+package preview
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tokens := Tokens{Secret: []byte("secret"), now: func() time.Time { return now }}
+
+	token, minted, err := tokens.Mint("workspace", "flow", 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	t.Run("a minted token verifies for its flow", func(t *testing.T) {
+		claims, err := tokens.VerifyFor(token, "workspace", "flow")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if claims.SessionID != minted.SessionID || claims.ExpiresAt != now.Add(DefaultTTL).Unix() {
+			t.Fatalf("Expected the minted claims, got %+v", claims)
+		}
+	})
+
+	t.Run("tokens of other flows, workspaces or secrets are rejected", func(t *testing.T) {
+		if _, err := tokens.VerifyFor(token, "workspace", "other"); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("Expected an invalid token for another flow, got %v", err)
+		}
+		if _, err := tokens.VerifyFor(token, "other", ""); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("Expected an invalid token for another workspace, got %v", err)
+		}
+		other := Tokens{Secret: []byte("other"), now: tokens.now}
+		if _, err := other.Verify(token); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("Expected an invalid token for another secret, got %v", err)
+		}
+	})
+
+	t.Run("expired tokens are rejected", func(t *testing.T) {
+		later := Tokens{Secret: tokens.Secret, now: func() time.Time { return now.Add(DefaultTTL) }}
+		if _, err := later.Verify(token); !errors.Is(err, ErrExpiredToken) {
+			t.Fatalf("Expected an expired token, got %v", err)
+		}
+	})
+
+	t.Run("ttl is capped", func(t *testing.T) {
+		if _, _, err := tokens.Mint("workspace", "flow", MaxTTL+time.Second); err == nil {
+			t.Fatalf("Expected an error for a ttl over the maximum")
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package surveys
+
+import (
+	"errors"
+	"milestone_core/tours/flows"
+	"strconv"
+)
+
+const maxTextAnswerLength = 5000
+
+// validateAnswers checks the answers against the survey blocks of the step and sets the block type on them
+func validateAnswers(step *flows.Step, answers []Answer) error {
+	blocksById := make(map[string]flows.StepBlock)
+	for _, block := range step.Data.Blocks {
+		if block.Type.IsSurvey() && block.Survey != nil {
+			blocksById[block.BlockID] = block
+		}
+	}
+
+	answered := make(map[string]bool, len(answers))
+	for i := range answers {
+		block, ok := blocksById[answers[i].BlockID]
+		if !ok {
+			return errors.New("survey block not found: " + answers[i].BlockID)
+		}
+		if answered[block.BlockID] {
+			return errors.New("block is answered more than once: " + block.BlockID)
+		}
+		answered[block.BlockID] = true
+
+		answers[i].Type = block.Type
+		if err := validateAnswer(block, answers[i]); err != nil {
+			return errors.New(block.BlockID + ": " + err.Error())
+		}
+		answers[i] = keepAnswerValue(answers[i])
+	}
+
+	for blockId, block := range blocksById {
+		if block.Survey.Required && !answered[blockId] {
+			return errors.New("required block is not answered: " + blockId)
+		}
+	}
+
+	return nil
+}
+
+func validateAnswer(block flows.StepBlock, answer Answer) error {
+	switch block.Type {
+	case flows.StepBlockTypeNPS:
+		if answer.Score == nil || *answer.Score < 0 || *answer.Score > 10 {
+			return errors.New("nps score must be between 0 and 10")
+		}
+	case flows.StepBlockTypeRating:
+		scale := block.Survey.RatingScale()
+		if answer.Score == nil || *answer.Score < 1 || *answer.Score > scale {
+			return errors.New("rating must be between 1 and " + strconv.Itoa(scale))
+		}
+	case flows.StepBlockTypeSingleChoice, flows.StepBlockTypeMultiChoice:
+		if len(answer.ChoiceIDs) == 0 {
+			return errors.New("no choice selected")
+		}
+		if block.Type == flows.StepBlockTypeSingleChoice && len(answer.ChoiceIDs) > 1 {
+			return errors.New("only one choice can be selected")
+		}
+		selected := make(map[string]bool, len(answer.ChoiceIDs))
+		for _, choiceId := range answer.ChoiceIDs {
+			if !block.Survey.HasChoice(choiceId) {
+				return errors.New("unknown choice: " + choiceId)
+			}
+			if selected[choiceId] {
+				return errors.New("choice is selected more than once: " + choiceId)
+			}
+			selected[choiceId] = true
+		}
+	case flows.StepBlockTypeFreeText:
+		if answer.Text == "" {
+			return errors.New("answer text is empty")
+		}
+		if len(answer.Text) > maxTextAnswerLength {
+			return errors.New("answer text is longer than " + strconv.Itoa(maxTextAnswerLength) + " characters")
+		}
+	}
+
+	return nil
+}
+
+// keepAnswerValue drops the values that do not belong to the block type, so they are not stored
+func keepAnswerValue(answer Answer) Answer {
+	value := Answer{BlockID: answer.BlockID, Type: answer.Type}
+	switch answer.Type {
+	case flows.StepBlockTypeNPS, flows.StepBlockTypeRating:
+		value.Score = answer.Score
+	case flows.StepBlockTypeSingleChoice, flows.StepBlockTypeMultiChoice:
+		value.ChoiceIDs = answer.ChoiceIDs
+	case flows.StepBlockTypeFreeText:
+		value.Text = answer.Text
+	}
+
+	return value
+}
@@ -0,0 +1,70 @@
+package surveys
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"milestone_core/tours/flows"
+)
+
+// SurveyResponse holds the answers of an enrolled user to the survey blocks of a step. A user has one response
+// per step, submitting again replaces the answers.
+type SurveyResponse struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	WorkspaceID    string             `json:"-" bson:"workspaceId"`
+	FlowID         string             `json:"flowId" bson:"flowId"`
+	StepID         string             `json:"stepId" bson:"stepId"`
+	ExternalUserID string             `json:"externalUserId" bson:"externalUserId"`
+	Answers        []Answer           `json:"answers" bson:"answers"`
+	SubmittedAt    int64              `json:"submittedAt" bson:"submittedAt"`
+}
+
+type Answer struct {
+	BlockID   string              `json:"blockId" bson:"blockId"`
+	Type      flows.StepBlockType `json:"type" bson:"type"`
+	Score     *int                `json:"score,omitempty" bson:"score,omitempty"`
+	ChoiceIDs []string            `json:"choiceIds,omitempty" bson:"choiceIds,omitempty"`
+	Text      string              `json:"text,omitempty" bson:"text,omitempty"`
+}
+
+type SubmitRequest struct {
+	FlowID  string   `json:"flowId"`
+	StepID  string   `json:"stepId"`
+	Answers []Answer `json:"answers"`
+}
+
+type SurveyResults struct {
+	FlowID    string         `json:"flowId"`
+	Responses int            `json:"responses"`
+	Blocks    []BlockResults `json:"blocks"`
+}
+
+type BlockResults struct {
+	StepID    string              `json:"stepId"`
+	BlockID   string              `json:"blockId"`
+	Type      flows.StepBlockType `json:"type"`
+	Question  string              `json:"question"`
+	Responses int                 `json:"responses"`
+	NPS       *NPSResults         `json:"nps,omitempty"`
+	Rating    *RatingResults      `json:"rating,omitempty"`
+	Choices   []ChoiceResults     `json:"choices,omitempty"`
+}
+
+// NPSResults splits the scores into promoters (9-10), passives (7-8) and detractors (0-6). Score is the share of
+// promoters minus the share of detractors, from -100 to 100.
+type NPSResults struct {
+	Score      float64 `json:"score"`
+	Promoters  int     `json:"promoters"`
+	Passives   int     `json:"passives"`
+	Detractors int     `json:"detractors"`
+}
+
+type RatingResults struct {
+	Average      float64     `json:"average"`
+	Distribution map[int]int `json:"distribution"`
+}
+
+type ChoiceResults struct {
+	ChoiceID string  `json:"choiceId"`
+	Label    string  `json:"label"`
+	Count    int     `json:"count"`
+	Share    float64 `json:"share"`
+}
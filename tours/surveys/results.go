@@ -0,0 +1,148 @@
+package surveys
+
+import (
+	"milestone_core/tours/flows"
+	"strconv"
+)
+
+// aggregateResults summarizes the answers for the survey blocks of the flow, in step and block order. Answers to
+// blocks that were removed from the flow are left out.
+func aggregateResults(flow *flows.Flow, responses []SurveyResponse) SurveyResults {
+	answersByBlockId := make(map[string][]Answer)
+	for _, response := range responses {
+		for _, answer := range response.Answers {
+			key := response.StepID + "/" + answer.BlockID
+			answersByBlockId[key] = append(answersByBlockId[key], answer)
+		}
+	}
+
+	results := SurveyResults{
+		FlowID:    flow.ID.Hex(),
+		Responses: len(responses),
+		Blocks:    make([]BlockResults, 0),
+	}
+	for _, step := range flow.Steps {
+		for _, block := range step.Data.Blocks {
+			if !block.Type.IsSurvey() || block.Survey == nil {
+				continue
+			}
+			answers := answersByBlockId[step.StepID+"/"+block.BlockID]
+			results.Blocks = append(results.Blocks, aggregateBlock(step.StepID, block, answers))
+		}
+	}
+
+	return results
+}
+
+func aggregateBlock(stepId string, block flows.StepBlock, answers []Answer) BlockResults {
+	results := BlockResults{
+		StepID:    stepId,
+		BlockID:   block.BlockID,
+		Type:      block.Type,
+		Question:  block.Survey.Question,
+		Responses: len(answers),
+	}
+
+	switch block.Type {
+	case flows.StepBlockTypeNPS:
+		results.NPS = aggregateNPS(answers)
+	case flows.StepBlockTypeRating:
+		results.Rating = aggregateRating(answers)
+	case flows.StepBlockTypeSingleChoice, flows.StepBlockTypeMultiChoice:
+		results.Choices = aggregateChoices(block.Survey.Choices, answers)
+	}
+
+	return results
+}
+
+func aggregateNPS(answers []Answer) *NPSResults {
+	nps := &NPSResults{}
+	for _, answer := range answers {
+		if answer.Score == nil {
+			continue
+		}
+		switch {
+		case *answer.Score >= 9:
+			nps.Promoters++
+		case *answer.Score >= 7:
+			nps.Passives++
+		default:
+			nps.Detractors++
+		}
+	}
+
+	total := nps.Promoters + nps.Passives + nps.Detractors
+	if total > 0 {
+		nps.Score = float64(nps.Promoters-nps.Detractors) / float64(total) * 100
+	}
+
+	return nps
+}
+
+func aggregateRating(answers []Answer) *RatingResults {
+	rating := &RatingResults{Distribution: make(map[int]int)}
+	sum, count := 0, 0
+	for _, answer := range answers {
+		if answer.Score == nil {
+			continue
+		}
+		rating.Distribution[*answer.Score]++
+		sum += *answer.Score
+		count++
+	}
+	if count > 0 {
+		rating.Average = float64(sum) / float64(count)
+	}
+
+	return rating
+}
+
+// aggregateChoices counts how often each choice was selected, shares are relative to the answers of the block
+// so they add up to more than 1 for multi choice blocks
+func aggregateChoices(choices []flows.SurveyChoice, answers []Answer) []ChoiceResults {
+	counts := make(map[string]int)
+	for _, answer := range answers {
+		for _, choiceId := range answer.ChoiceIDs {
+			counts[choiceId]++
+		}
+	}
+
+	results := make([]ChoiceResults, 0, len(choices))
+	for _, choice := range choices {
+		choiceResults := ChoiceResults{ChoiceID: choice.ChoiceID, Label: choice.Label, Count: counts[choice.ChoiceID]}
+		if len(answers) > 0 {
+			choiceResults.Share = float64(choiceResults.Count) / float64(len(answers))
+		}
+		results = append(results, choiceResults)
+	}
+
+	return results
+}
+
+// answerValue formats the answer for the export, choices are joined by their labels
+func answerValue(block *flows.StepBlock, answer Answer) string {
+	if answer.Score != nil {
+		return strconv.Itoa(*answer.Score)
+	}
+	if answer.Text != "" {
+		return answer.Text
+	}
+
+	value := ""
+	for i, choiceId := range answer.ChoiceIDs {
+		if i > 0 {
+			value += "; "
+		}
+		label := choiceId
+		if block != nil && block.Survey != nil {
+			for _, choice := range block.Survey.Choices {
+				if choice.ChoiceID == choiceId {
+					label = choice.Label
+				}
+			}
+		}
+		value += label
+	}
+
+	return value
+}
@@ -0,0 +1,52 @@
+package surveys
+
+import (
+	"bytes"
+	"github.com/go-chi/chi/v5"
+	"milestone_core/shared/server"
+	"net/http"
+)
+
+type Resource struct {
+	Service Service
+}
+
+func (rs Resource) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Route("/{flowId}", func(r chi.Router) {
+		r.Get("/results", rs.GetResults)
+		r.Get("/export", rs.Export)
+	})
+
+	return r
+}
+
+func (rs Resource) GetResults(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	flowId := chi.URLParam(r, "flowId")
+
+	results, err := rs.Service.GetResults(workspaceId, flowId)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	server.SendJson(w, results)
+}
+
+func (rs Resource) Export(w http.ResponseWriter, r *http.Request) {
+	workspaceId := server.GetWorkspaceIdFromContext(r.Context())
+	flowId := chi.URLParam(r, "flowId")
+
+	var export bytes.Buffer
+	err := rs.Service.Export(workspaceId, flowId, &export)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"survey-"+flowId+".csv\"")
+	_, _ = w.Write(export.Bytes())
+}
@@ -0,0 +1,190 @@
+package surveys
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"milestone_core/public/enrolledusers"
+	"milestone_core/tours/flows"
+	"milestone_core/tours/preview"
+	"time"
+)
+
+type Service struct {
+	Collection          *mongo.Collection
+	FlowService         flows.Service
+	FlowEnroller        flows.Enroller
+	EnrolledUserService enrolledusers.Service
+	PreviewTokens       preview.Tokens
+}
+
+// Submit stores the answers of the user to the survey blocks of a step of a live flow. With a preview token the
+// answers are checked against the draft of the flow and not stored.
+func (s Service) Submit(workspaceId string, externalUserId string, request SubmitRequest, previewToken string) error {
+	if previewToken != "" {
+		return s.checkPreviewAnswers(workspaceId, request, previewToken)
+	}
+
+	user, err := s.EnrolledUserService.Get(workspaceId, externalUserId)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+
+	flow, err := s.FlowEnroller.GetFlow(workspaceId, flows.EnrollmentOpts{CurrentEnrollmentId: request.FlowID})
+	if err != nil {
+		return err
+	}
+	if flow == nil {
+		return errors.New("flow not found")
+	}
+
+	err = s.checkAnswers(workspaceId, flow, request)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"workspaceId": workspaceId, "flowId": request.FlowID, "stepId": request.StepID, "externalUserId": externalUserId}
+	update := bson.M{"$set": bson.M{"answers": request.Answers, "submittedAt": time.Now().Unix()}}
+	_, err = s.Collection.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
+
+	return err
+}
+
+func (s Service) checkPreviewAnswers(workspaceId string, request SubmitRequest, previewToken string) error {
+	claims, err := s.PreviewTokens.VerifyFor(previewToken, workspaceId, request.FlowID)
+	if err != nil {
+		return err
+	}
+
+	flow, err := s.FlowEnroller.GetPreviewFlow(workspaceId, claims.FlowID)
+	if err != nil {
+		return err
+	}
+
+	return s.checkAnswers(workspaceId, flow, request)
+}
+
+// checkAnswers validates the answers against the survey blocks of the step
+func (s Service) checkAnswers(workspaceId string, flow *flows.Flow, request SubmitRequest) error {
+	step := s.FlowService.GetStep(workspaceId, flow, request.StepID)
+	if step == nil {
+		return errors.New("step not found")
+	}
+
+	err := validateAnswers(step, request.Answers)
+	if err != nil {
+		return err
+	}
+	if len(request.Answers) == 0 {
+		return errors.New("no answers")
+	}
+
+	return nil
+}
+
+func (s Service) GetResults(workspaceId string, flowId string) (*SurveyResults, error) {
+	flow, err := s.getFlow(workspaceId, flowId)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := s.listResponses(workspaceId, flowId)
+	if err != nil {
+		return nil, err
+	}
+
+	results := aggregateResults(flow, responses)
+	return &results, nil
+}
+
+// Export writes one csv row per answer, oldest responses first
+func (s Service) Export(workspaceId string, flowId string, w io.Writer) error {
+	flow, err := s.getFlow(workspaceId, flowId)
+	if err != nil {
+		return err
+	}
+
+	responses, err := s.listResponses(workspaceId, flowId)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	err = writer.Write([]string{"submittedAt", "externalUserId", "stepId", "blockId", "type", "question", "answer"})
+	if err != nil {
+		return err
+	}
+	for _, response := range responses {
+		step := s.FlowService.GetStep(workspaceId, flow, response.StepID)
+		for _, answer := range response.Answers {
+			block := findBlock(step, answer.BlockID)
+			question := ""
+			if block != nil && block.Survey != nil {
+				question = block.Survey.Question
+			}
+
+			err = writer.Write([]string{
+				time.Unix(response.SubmittedAt, 0).UTC().Format(time.RFC3339),
+				response.ExternalUserID,
+				response.StepID,
+				answer.BlockID,
+				string(answer.Type),
+				question,
+				answerValue(block, answer),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func (s Service) getFlow(workspaceId string, flowId string) (*flows.Flow, error) {
+	flow, err := s.FlowService.Get(workspaceId, flowId)
+	if err != nil {
+		return nil, err
+	}
+	if flow == nil {
+		return nil, errors.New("flow not found")
+	}
+
+	return flow, nil
+}
+
+func (s Service) listResponses(workspaceId string, flowId string) ([]SurveyResponse, error) {
+	findOpts := options.Find().SetSort(bson.D{{Key: "submittedAt", Value: 1}})
+	cursor, err := s.Collection.Find(context.Background(), bson.M{"workspaceId": workspaceId, "flowId": flowId}, findOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]SurveyResponse, 0)
+	if err = cursor.All(context.Background(), &responses); err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+func findBlock(step *flows.Step, blockId string) *flows.StepBlock {
+	if step == nil {
+		return nil
+	}
+	for i := range step.Data.Blocks {
+		if step.Data.Blocks[i].BlockID == blockId {
+			return &step.Data.Blocks[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package surveys
+
+import (
+	"milestone_core/tours/flows"
+	"testing"
+)
+
+func surveyStep() *flows.Step {
+	return &flows.Step{StepID: "step_1", Data: flows.StepData{Blocks: []flows.StepBlock{
+		{BlockID: "nps", Type: flows.StepBlockTypeNPS, Survey: &flows.SurveyBlock{Question: "How likely are you to recommend us?", Required: true}},
+		{BlockID: "rating", Type: flows.StepBlockTypeRating, Survey: &flows.SurveyBlock{Question: "Rate the onboarding", Scale: 5}},
+		{BlockID: "role", Type: flows.StepBlockTypeSingleChoice, Survey: &flows.SurveyBlock{Question: "Your role", Choices: []flows.SurveyChoice{
+			{ChoiceID: "dev", Label: "Developer"},
+			{ChoiceID: "pm", Label: "Product manager"},
+		}}},
+		{BlockID: "comment", Type: flows.StepBlockTypeFreeText, Survey: &flows.SurveyBlock{Question: "Anything else?"}},
+	}}}
+}
+
+func score(value int) *int {
+	return &value
+}
+
+func TestValidateAnswers(t *testing.T) {
+	t.Run("accepts valid answers and sets their type", func(t *testing.T) {
+		answers := []Answer{
+			{BlockID: "nps", Score: score(9), Text: "ignored"},
+			{BlockID: "role", ChoiceIDs: []string{"pm"}},
+		}
+		if err := validateAnswers(surveyStep(), answers); err != nil {
+			t.Fatalf("Expected the answers to be valid, got %v", err)
+		}
+		if answers[0].Type != flows.StepBlockTypeNPS || answers[0].Text != "" {
+			t.Fatalf("Expected the nps answer to only keep its score, got %+v", answers[0])
+		}
+	})
+
+	t.Run("rejects invalid answers", func(t *testing.T) {
+		cases := map[string][]Answer{
+			"nps out of range":      {{BlockID: "nps", Score: score(11)}},
+			"rating out of scale":   {{BlockID: "nps", Score: score(5)}, {BlockID: "rating", Score: score(6)}},
+			"unknown choice":        {{BlockID: "nps", Score: score(5)}, {BlockID: "role", ChoiceIDs: []string{"cto"}}},
+			"two single choices":    {{BlockID: "nps", Score: score(5)}, {BlockID: "role", ChoiceIDs: []string{"dev", "pm"}}},
+			"required not answered": {{BlockID: "comment", Text: "Great"}},
+			"unknown block":         {{BlockID: "nps", Score: score(5)}, {BlockID: "other", Text: "Great"}},
+		}
+		for name, answers := range cases {
+			if err := validateAnswers(surveyStep(), answers); err == nil {
+				t.Fatalf("Expected %s to be invalid", name)
+			}
+		}
+	})
+}
+
+func TestAggregateResults(t *testing.T) {
+	flow := &flows.Flow{Steps: []flows.Step{*surveyStep()}}
+	responses := make([]SurveyResponse, 0)
+	for _, value := range []int{10, 9, 8, 3} {
+		responses = append(responses, SurveyResponse{StepID: "step_1", Answers: []Answer{
+			{BlockID: "nps", Type: flows.StepBlockTypeNPS, Score: score(value)},
+			{BlockID: "role", Type: flows.StepBlockTypeSingleChoice, ChoiceIDs: []string{"dev"}},
+		}})
+	}
+
+	results := aggregateResults(flow, responses)
+	nps := results.Blocks[0].NPS
+	if nps.Promoters != 2 || nps.Passives != 1 || nps.Detractors != 1 || nps.Score != 25 {
+		t.Fatalf("Expected an nps score of 25, got %+v", nps)
+	}
+	choices := results.Blocks[2].Choices
+	if choices[0].Count != 4 || choices[0].Share != 1 || choices[1].Count != 0 {
+		t.Fatalf("Expected every response to choose developer, got %+v", choices)
+	}
+}
@@ -10,6 +10,8 @@ type EventTrack struct {
 	EventType      EventType          `json:"eventType" bson:"eventType"`
 	Timestamp      int64              `json:"timestamp" bson:"timestamp"`
 	Metadata       map[string]string  `json:"metadata" bson:"metadata"`
+	// Preview marks events of preview sessions, they are kept out of the analytics
+	Preview bool `json:"-" bson:"preview,omitempty"`
 }
 
 type EventType string
@@ -22,4 +24,8 @@ const (
 	EventTypeFlowStepFinish EventType = "flow_step_finished"
 	EventTypeFlowSkipped    EventType = "flow_skipped"
 	EventTypeFlowFinished   EventType = "flow_finished"
+	// EventTypePageView is tracked by the SDK with the visited url in the "url" metadata
+	EventTypePageView EventType = "page_view"
+
+	EventTypeChecklistItemCompleted EventType = "checklist_item_completed"
 )
@@ -2,7 +2,9 @@ package tracker
 
 import (
 	"context"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Tracker struct {
@@ -25,8 +27,9 @@ func (t Tracker) TrackEvents(workspaceId string, externalUserId string, events [
 	return nil
 }
 
+// FetchTrackDataForFlow returns the events of real users for the flow, preview sessions are left out
 func (t Tracker) FetchTrackDataForFlow(flowID string) ([]EventTrack, error) {
-	cursor, err := t.Collection.Find(context.Background(), map[string]string{"entityId": flowID})
+	cursor, err := t.Collection.Find(context.Background(), bson.M{"entityId": flowID, "preview": bson.M{"$ne": true}})
 	if err != nil {
 		return nil, err
 	}
@@ -38,3 +41,49 @@ func (t Tracker) FetchTrackDataForFlow(flowID string) ([]EventTrack, error) {
 
 	return events, nil
 }
+
+// userEventsBatchSize keeps the $in lists of FetchUserEvents small
+const userEventsBatchSize = 1000
+
+// FetchUserEvents returns the events of a type tracked for the users between from and to, unix seconds. Only the
+// user, the time and the metadata of the events are loaded.
+func (t Tracker) FetchUserEvents(workspaceId string, eventType EventType, externalUserIds []string, from int64, to int64) ([]EventTrack, error) {
+	events := make([]EventTrack, 0)
+	findOpts := options.Find().SetProjection(bson.M{"externalUserId": 1, "timestamp": 1, "metadata": 1})
+	for start := 0; start < len(externalUserIds); start += userEventsBatchSize {
+		batch := externalUserIds[start:min(start+userEventsBatchSize, len(externalUserIds))]
+		filter := bson.M{
+			"workspaceId":    workspaceId,
+			"eventType":      eventType,
+			"externalUserId": bson.M{"$in": batch},
+			"timestamp":      bson.M{"$gte": from, "$lte": to},
+			"preview":        bson.M{"$ne": true},
+		}
+		cursor, err := t.Collection.Find(context.Background(), filter, findOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		batchEvents := make([]EventTrack, 0)
+		if err = cursor.All(context.Background(), &batchEvents); err != nil {
+			return nil, err
+		}
+		events = append(events, batchEvents...)
+	}
+
+	return events, nil
+}
+
+func (t Tracker) FetchFlowEvents(flowID string, eventType EventType) ([]EventTrack, error) {
+	cursor, err := t.Collection.Find(context.Background(), bson.M{"entityId": flowID, "eventType": eventType, "preview": bson.M{"$ne": true}})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]EventTrack, 0)
+	if err = cursor.All(context.Background(), &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
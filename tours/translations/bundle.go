@@ -0,0 +1,149 @@
+package translations
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"milestone_core/shared/server"
+	"net/http"
+)
+
+type Format string
+
+const (
+	FormatJSON  Format = "json"
+	FormatXLIFF Format = "xliff"
+)
+
+// Bundle is the unit exchanged with translators, the content of one flow or helper in one locale
+type Bundle struct {
+	Original     string  `json:"original"`
+	SourceLocale string  `json:"sourceLocale"`
+	Locale       string  `json:"locale"`
+	Entries      []Entry `json:"entries"`
+}
+
+func ParseFormat(format string) (Format, error) {
+	switch Format(format) {
+	case FormatJSON, "":
+		return FormatJSON, nil
+	case FormatXLIFF:
+		return FormatXLIFF, nil
+	}
+
+	return "", errors.New("unknown translation format: " + format)
+}
+
+func (f Format) ContentType() string {
+	if f == FormatXLIFF {
+		return "application/x-xliff+xml"
+	}
+
+	return "application/json"
+}
+
+func WriteBundle(w io.Writer, format Format, bundle Bundle) error {
+	if format == FormatXLIFF {
+		_, err := io.WriteString(w, xml.Header)
+		if err != nil {
+			return err
+		}
+		encoder := xml.NewEncoder(w)
+		encoder.Indent("", "  ")
+		return encoder.Encode(toXLIFF(bundle))
+	}
+
+	return json.NewEncoder(w).Encode(bundle)
+}
+
+func ReadBundle(r io.Reader, format Format) (*Bundle, error) {
+	var bundle Bundle
+	if format == FormatXLIFF {
+		var document xliffDocument
+		err := xml.NewDecoder(r).Decode(&document)
+		if err != nil {
+			return nil, err
+		}
+		bundle = fromXLIFF(document)
+	} else {
+		err := json.NewDecoder(r).Decode(&bundle)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bundle.Locale = NormalizeLocale(bundle.Locale)
+	if bundle.Locale == "" {
+		return nil, errors.New("translation bundle requires a locale")
+	}
+
+	return &bundle, nil
+}
+
+// xliffDocument is the subset of XLIFF 1.2 used for the bundles, one file with a trans-unit per entry
+type xliffDocument struct {
+	XMLName xml.Name  `xml:"urn:oasis:names:tc:xliff:document:1.2 xliff"`
+	Version string    `xml:"version,attr"`
+	File    xliffFile `xml:"file"`
+}
+
+type xliffFile struct {
+	Original       string           `xml:"original,attr"`
+	SourceLanguage string           `xml:"source-language,attr"`
+	TargetLanguage string           `xml:"target-language,attr"`
+	Datatype       string           `xml:"datatype,attr"`
+	Units          []xliffTransUnit `xml:"body>trans-unit"`
+}
+
+type xliffTransUnit struct {
+	ID     string `xml:"id,attr"`
+	Source string `xml:"source"`
+	Target string `xml:"target"`
+}
+
+func toXLIFF(bundle Bundle) xliffDocument {
+	units := make([]xliffTransUnit, len(bundle.Entries))
+	for i, entry := range bundle.Entries {
+		units[i] = xliffTransUnit{ID: entry.Key, Source: entry.Source, Target: entry.Target}
+	}
+
+	return xliffDocument{
+		Version: "1.2",
+		File: xliffFile{
+			Original:       bundle.Original,
+			SourceLanguage: bundle.SourceLocale,
+			TargetLanguage: bundle.Locale,
+			Datatype:       "plaintext",
+			Units:          units,
+		},
+	}
+}
+
+func fromXLIFF(document xliffDocument) Bundle {
+	entries := make([]Entry, len(document.File.Units))
+	for i, unit := range document.File.Units {
+		entries[i] = Entry{Key: unit.ID, Source: unit.Source, Target: unit.Target}
+	}
+
+	return Bundle{
+		Original:     document.File.Original,
+		SourceLocale: document.File.SourceLanguage,
+		Locale:       document.File.TargetLanguage,
+		Entries:      entries,
+	}
+}
+
+// SendBundle writes the bundle as the response, in the requested format
+func SendBundle(w http.ResponseWriter, format Format, bundle Bundle) {
+	var content bytes.Buffer
+	err := WriteBundle(&content, format, bundle)
+	if err != nil {
+		server.SendBadRequestErrorJson(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	_, _ = w.Write(content.Bytes())
+}
@@ -0,0 +1,125 @@
+package translations
+
+import (
+	"strings"
+)
+
+// Translations holds the translated content of a flow or helper by locale and content key
+type Translations map[string]map[string]string
+
+// Entry is a translatable string, Source is the default content and Target its translation
+type Entry struct {
+	Key    string `json:"key"`
+	Source string `json:"source"`
+	Target string `json:"target,omitempty"`
+}
+
+// NormalizeLocale formats locales as a lowercase language and an uppercase region, "pt_br" becomes "pt-BR"
+func NormalizeLocale(locale string) string {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"), "-")
+	if parts[0] == "" {
+		return ""
+	}
+
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		if len(parts[i]) == 2 {
+			parts[i] = strings.ToUpper(parts[i])
+		}
+	}
+
+	return strings.Join(parts, "-")
+}
+
+// FallbackChain lists the locales to look up in order, "pt-BR" falls back to "pt". The default content is used
+// when no locale of the chain has a translation.
+func FallbackChain(locale string) []string {
+	locale = NormalizeLocale(locale)
+	chain := make([]string, 0)
+	for locale != "" {
+		chain = append(chain, locale)
+		separator := strings.LastIndex(locale, "-")
+		if separator < 0 {
+			break
+		}
+		locale = locale[:separator]
+	}
+
+	return chain
+}
+
+// Resolver looks up the translations of one locale, following the fallback chain
+type Resolver struct {
+	chain        []string
+	translations Translations
+}
+
+func (t Translations) Resolver(locale string) Resolver {
+	return Resolver{chain: FallbackChain(locale), translations: t}
+}
+
+// Translate returns the translation of the key or the default content
+func (r Resolver) Translate(key string, defaultContent string) string {
+	for _, locale := range r.chain {
+		if value, ok := r.translations[locale][key]; ok && value != "" {
+			return value
+		}
+	}
+
+	return defaultContent
+}
+
+func (r Resolver) IsEmpty() bool {
+	return len(r.chain) == 0 || len(r.translations) == 0
+}
+
+// Merge sets the translations of the entries for the locale. Entries without a target are removed, keys that are
+// not part of the content are rejected.
+func (t Translations) Merge(locale string, entries []Entry, sourceEntries []Entry) (Translations, []string) {
+	knownKeys := make(map[string]bool, len(sourceEntries))
+	for _, entry := range sourceEntries {
+		knownKeys[entry.Key] = true
+	}
+
+	merged := make(Translations, len(t)+1)
+	for existingLocale, values := range t {
+		merged[existingLocale] = values
+	}
+
+	values := make(map[string]string, len(entries))
+	for key, value := range t[locale] {
+		values[key] = value
+	}
+
+	unknownKeys := make([]string, 0)
+	for _, entry := range entries {
+		if !knownKeys[entry.Key] {
+			unknownKeys = append(unknownKeys, entry.Key)
+			continue
+		}
+		if entry.Target == "" {
+			delete(values, entry.Key)
+			continue
+		}
+		values[entry.Key] = entry.Target
+	}
+
+	if len(values) == 0 {
+		delete(merged, locale)
+	} else {
+		merged[locale] = values
+	}
+
+	return merged, unknownKeys
+}
+
+// WithTargets fills the targets of the source entries with the translations of the locale, without fallbacks
+func (t Translations) WithTargets(locale string, sourceEntries []Entry) []Entry {
+	entries := make([]Entry, len(sourceEntries))
+	for i, entry := range sourceEntries {
+		entry.Target = t[locale][entry.Key]
+		entries[i] = entry
+	}
+
+	return entries
+}
@@ -0,0 +1,76 @@
+package translations
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestFallbackChain(t *testing.T) {
+	t.Run("regional locales fall back to their language", func(t *testing.T) {
+		if chain := FallbackChain("pt_br"); !slices.Equal(chain, []string{"pt-BR", "pt"}) {
+			t.Fatalf("Expected pt-BR to fall back to pt, got %v", chain)
+		}
+		if chain := FallbackChain(""); len(chain) != 0 {
+			t.Fatalf("Expected no locales without a locale, got %v", chain)
+		}
+	})
+
+	t.Run("translations follow the chain to the default content", func(t *testing.T) {
+		resolver := Translations{
+			"pt":    {"title": "Bem-vindo", "body": "Vamos começar"},
+			"pt-BR": {"title": "Boas-vindas"},
+		}.Resolver("pt-BR")
+
+		if value := resolver.Translate("title", "Welcome"); value != "Boas-vindas" {
+			t.Fatalf("Expected the regional translation, got %s", value)
+		}
+		if value := resolver.Translate("body", "Let's start"); value != "Vamos começar" {
+			t.Fatalf("Expected the language translation, got %s", value)
+		}
+		if value := resolver.Translate("footer", "Skip"); value != "Skip" {
+			t.Fatalf("Expected the default content, got %s", value)
+		}
+	})
+}
+
+func TestMerge(t *testing.T) {
+	source := []Entry{{Key: "title", Source: "Welcome"}, {Key: "body", Source: "Let's start"}}
+	existing := Translations{"de": {"title": "Willkommen", "body": "Los geht's"}}
+
+	merged, unknownKeys := existing.Merge("de", []Entry{{Key: "title", Target: "Hallo"}, {Key: "body"}, {Key: "footer", Target: "Weiter"}}, source)
+	if !slices.Equal(unknownKeys, []string{"footer"}) {
+		t.Fatalf("Expected footer to be an unknown key, got %v", unknownKeys)
+	}
+	if merged["de"]["title"] != "Hallo" {
+		t.Fatalf("Expected the title to be replaced, got %v", merged["de"])
+	}
+	if _, ok := merged["de"]["body"]; ok {
+		t.Fatalf("Expected an empty target to remove the translation, got %v", merged["de"])
+	}
+	if existing["de"]["title"] != "Willkommen" {
+		t.Fatalf("Expected the existing translations not to be changed")
+	}
+}
+
+func TestXLIFFBundle(t *testing.T) {
+	bundle := Bundle{
+		Original:     "flow_1",
+		SourceLocale: "en",
+		Locale:       "pt-BR",
+		Entries:      []Entry{{Key: "steps/step_1/actionText", Source: "Next <b>step</b>", Target: "Próximo"}},
+	}
+
+	var content bytes.Buffer
+	if err := WriteBundle(&content, FormatXLIFF, bundle); err != nil {
+		t.Fatalf("Expected the bundle to be written, got %v", err)
+	}
+
+	parsed, err := ReadBundle(&content, FormatXLIFF)
+	if err != nil {
+		t.Fatalf("Expected the bundle to be read, got %v", err)
+	}
+	if parsed.Locale != "pt-BR" || parsed.Original != "flow_1" || !slices.Equal(parsed.Entries, bundle.Entries) {
+		t.Fatalf("Expected the bundle to survive a round trip, got %+v", parsed)
+	}
+}